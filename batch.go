@@ -0,0 +1,328 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["batch"] = command{
+		run:   runBatch,
+		usage: "batch -o OUTDIR [-j N] [-summary csv|json] ROMS: disassemble every ROM of a directory, zip and gzip files included",
+	}
+}
+
+// romExtensions are the extensions of the ROMs processed by a batch.
+var romExtensions = map[string]bool{".nes": true, ".unf": true, ".unif": true}
+
+// batchJob is a ROM to disassemble: a file, or an entry of a zip archive.
+type batchJob struct {
+	path    string // Relative to the batch directory, e.g. "set.zip/XXX.nes"
+	output  string // Listing, relative to the output directory, e.g. "set.zip/XXX.nes.s"
+	project string // Project file, if any
+	read    func() ([]byte, error)
+	done    func() // Called once the job has run, if not nil
+	err     error  // Set if the ROM cannot be processed, e.g. an unsafe zip entry
+}
+
+// batchArchive is a zip archive shared by the jobs of its entries.
+// It is opened by the first job reading its ROM, and closed once
+// every job is done, so that the archive is read once.
+type batchArchive struct {
+	path    string
+	mutex   sync.Mutex
+	reader  *zip.ReadCloser
+	err     error
+	pending int // Jobs not done yet
+}
+
+// read returns the content of the entry `index` of the archive.
+func (archive *batchArchive) read(index int) ([]byte, error) {
+	archive.mutex.Lock()
+	if archive.reader == nil && archive.err == nil {
+		archive.reader, archive.err = zip.OpenReader(archive.path)
+	}
+	reader, err := archive.reader, archive.err
+	archive.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if index >= len(reader.File) {
+		return nil, fmt.Errorf("'%s' has changed", archive.path)
+	}
+	file, err := reader.File[index].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// done closes the archive after its last job.
+func (archive *batchArchive) done() {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+	archive.pending--
+	if archive.pending == 0 && archive.reader != nil {
+		archive.reader.Close()
+		archive.reader = nil
+	}
+}
+
+// batchResult is a line of the batch summary.
+type batchResult struct {
+	Path     string  `json:"path"`
+	Format   string  `json:"format,omitempty"`
+	Mapper   int     `json:"mapper"`
+	Board    string  `json:"board,omitempty"`
+	PrgSize  int     `json:"prgSize"`
+	ChrSize  int     `json:"chrSize"`
+	Coverage float64 `json:"coverage"` // Percentage of the PRG ROM decoded as code
+	Error    string  `json:"error,omitempty"`
+}
+
+// findBatchJobs walks a directory for ROMs, gzip files
+// and zip archives of ROMs. Files which cannot be read, and
+// ROMs whose listing would overwrite another one, are jobs
+// carrying their error, so that they are reported in the
+// summary without stopping the batch.
+func findBatchJobs(root string) ([]batchJob, error) {
+	var jobs []batchJob
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		rel, relErr := filepath.Rel(root, filePath)
+		if relErr != nil {
+			rel = filePath
+		}
+		if err != nil {
+			if filePath == root {
+				return err
+			}
+			jobs = append(jobs, batchJob{path: rel, err: err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		extension := strings.ToLower(filepath.Ext(filePath))
+		uncompressed := rel
+		if extension == ".gz" {
+			// XXX.nes.gz
+			uncompressed = strings.TrimSuffix(rel, filepath.Ext(rel))
+			extension = strings.ToLower(filepath.Ext(uncompressed))
+		}
+		if romExtensions[extension] {
+			jobs = append(jobs, batchJob{
+				path:    rel,
+				output:  uncompressed + ".s",
+				project: projectPath(filepath.Join(root, uncompressed)),
+				read:    func() ([]byte, error) { return loadFile(filePath, "") },
+			})
+		}
+		if extension != ".zip" {
+			return nil
+		}
+		archive, err := zip.OpenReader(filePath)
+		if err != nil {
+			jobs = append(jobs, batchJob{path: rel, err: fmt.Errorf("failed to read '%s': %s", rel, err)})
+			return nil
+		}
+		defer archive.Close()
+		shared := &batchArchive{path: filePath}
+		for i, file := range archive.File {
+			if file.FileInfo().IsDir() || !romExtensions[strings.ToLower(filepath.Ext(file.Name))] {
+				continue
+			}
+			name := file.Name
+			// Zip entries use slashes, whatever the OS
+			cleaned := path.Clean(strings.Replace(name, "\\", "/", -1))
+			if path.IsAbs(cleaned) || filepath.VolumeName(cleaned) != "" || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+				jobs = append(jobs, batchJob{
+					path: rel + "/" + name,
+					err:  fmt.Errorf("unsafe entry name '%s'", name),
+				})
+				continue
+			}
+			index := i
+			shared.pending++
+			jobs = append(jobs, batchJob{
+				path:   filepath.Join(rel, filepath.FromSlash(cleaned)),
+				output: filepath.Join(rel, filepath.FromSlash(cleaned)+".s"),
+				read:   func() ([]byte, error) { return shared.read(index) },
+				done:   shared.done,
+			})
+		}
+		return nil
+	})
+	// e.g. XXX.nes and XXX.nes.gz, compared without case
+	// for case-insensitive file systems
+	outputs := map[string]string{}
+	for i, job := range jobs {
+		if job.err != nil {
+			continue
+		}
+		key := strings.ToLower(job.output)
+		if other, ok := outputs[key]; ok {
+			jobs[i].err = fmt.Errorf("same listing as '%s'", other)
+			continue
+		}
+		outputs[key] = job.path
+	}
+	return jobs, err
+}
+
+// run disassembles the ROM of a job into the output directory.
+// Panics are reported as errors, so that a ROM cannot stop the batch.
+func (job batchJob) run(outdir string) (result batchResult) {
+	result.Path = job.path
+	if job.done != nil {
+		defer job.done()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result.Error = fmt.Sprintf("internal error: %v", r)
+		}
+	}()
+	if err := job.disassemble(outdir, &result); err != nil {
+		result.Error = err.Error()
+	}
+	return
+}
+
+func (job batchJob) disassemble(outdir string, result *batchResult) error {
+	if job.err != nil {
+		return job.err
+	}
+	output := filepath.Join(outdir, job.output)
+	if rel, err := filepath.Rel(outdir, output); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output '%s' is outside '%s'", output, outdir)
+	}
+	data, err := job.read()
+	if err != nil {
+		return err
+	}
+	rom, err := nes.ReadRom(data)
+	if err != nil {
+		return err
+	}
+	result.Format = rom.Format.String()
+	result.Mapper, result.Board = rom.Mapper, rom.Board
+	result.PrgSize, result.ChrSize = len(rom.Prg), len(rom.Chr)
+
+	disassembly := rom.PrgRomReader().Disassembly()
+	if job.project != "" {
+		if err := applyProject(job.project, false, disassembly); err != nil {
+			return err
+		}
+	}
+	disassembly.AddVectors()
+	disassembly.Analyze()
+	result.Coverage = math.Round(disassembly.Coverage()*1000) / 10
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+	return writeOutput(output, disassembly.String())
+}
+
+// runBatchJobs runs the jobs on `workers` goroutines,
+// and returns their results in the same order.
+func runBatchJobs(jobs []batchJob, outdir string, workers int) []batchResult {
+	results := make([]batchResult, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = jobs[index].run(outdir)
+			}
+		}()
+	}
+	for index := range jobs {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// batchSummary returns the summary of a batch, in CSV or JSON.
+func batchSummary(results []batchResult, format string) (string, error) {
+	if format == "json" {
+		content, err := json.MarshalIndent(results, "", "  ")
+		return string(content), err
+	}
+	var builder strings.Builder
+	writer := csv.NewWriter(&builder)
+	writer.Write([]string{"path", "format", "mapper", "board", "prg_size", "chr_size", "coverage", "error"})
+	for _, result := range results {
+		writer.Write([]string{
+			result.Path, result.Format, strconv.Itoa(result.Mapper), result.Board,
+			strconv.Itoa(result.PrgSize), strconv.Itoa(result.ChrSize),
+			strconv.FormatFloat(result.Coverage, 'f', 1, 64), result.Error,
+		})
+	}
+	writer.Flush()
+	return builder.String(), writer.Error()
+}
+
+func runBatch(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	outdir := flags.String("o", "", "Output directory")
+	workers := flags.Int("j", runtime.NumCPU(), "Number of ROMs processed at the same time")
+	summaryFormat := flags.String("summary", "csv", "Summary format: csv or json")
+	flags.Parse(args)
+	if flags.NArg() != 1 || *outdir == "" || *workers < 1 {
+		return errors.New("usage: batch -o OUTDIR [-j N] [-summary csv|json] ROMS")
+	}
+	if *summaryFormat != "csv" && *summaryFormat != "json" {
+		return fmt.Errorf("unknown summary format '%s'", *summaryFormat)
+	}
+
+	jobs, err := findBatchJobs(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("no ROM found in '%s'", flags.Arg(0))
+	}
+	if err := os.MkdirAll(*outdir, 0755); err != nil {
+		return err
+	}
+	results := runBatchJobs(jobs, *outdir, *workers)
+	summary, err := batchSummary(results, *summaryFormat)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(*outdir, "summary."+*summaryFormat)
+	if err := writeOutput(path, summary); err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	fmt.Println(fmt.Sprintf("%d ROMs, %d errors, summary written to %s", len(results), failed, path))
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+// writeTestZip writes a zip archive holding `files`,
+// sorted by name.
+func writeTestZip(t *testing.T, path string, files map[string][]byte) {
+	file, err := os.Create(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writer := zip.NewWriter(file)
+	for _, name := range names {
+		entry, err := writer.Create(name)
+		assert.NoError(t, err)
+		entry.Write(files[name])
+	}
+	assert.NoError(t, writer.Close())
+}
+
+func TestBatchUnsafeZipEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	roms := filepath.Join(dir, "roms")
+	outdir := filepath.Join(dir, "a", "b", "out")
+	assert.NoError(t, os.MkdirAll(roms, 0755))
+	writeTestZip(t, filepath.Join(roms, "set.zip"), map[string][]byte{
+		"../../../escaped.nes": testrom.INes(),
+		"/absolute.nes":        testrom.INes(),
+		"sub/game.nes":         testrom.INes(),
+	})
+
+	jobs, err := findBatchJobs(roms)
+	assert.NoError(t, err)
+	results := map[string]batchResult{}
+	for _, result := range runBatchJobs(jobs, outdir, 2) {
+		results[result.Path] = result
+	}
+	assert.Len(t, results, 3)
+	assert.Equal(t, "unsafe entry name '../../../escaped.nes'", results["set.zip/../../../escaped.nes"].Error)
+	assert.Equal(t, "unsafe entry name '/absolute.nes'", results["set.zip//absolute.nes"].Error)
+	assert.Empty(t, results[filepath.Join("set.zip", "sub", "game.nes")].Error)
+
+	assert.FileExists(t, filepath.Join(outdir, "set.zip", "sub", "game.nes.s"))
+	_, err = os.Stat(filepath.Join(dir, "a", "escaped.nes.s"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBatchCorruptZip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	roms := filepath.Join(dir, "roms")
+	assert.NoError(t, os.MkdirAll(roms, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(roms, "bad.zip"), []byte("PK\x03\x04garbage"), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(roms, "good.nes"), testrom.INes(), 0644))
+
+	jobs, err := findBatchJobs(roms)
+	assert.NoError(t, err)
+	results := runBatchJobs(jobs, filepath.Join(dir, "out"), 1)
+	assert.Len(t, results, 2)
+	assert.Equal(t, "bad.zip", results[0].Path)
+	assert.Contains(t, results[0].Error, "failed to read 'bad.zip'")
+	assert.Equal(t, "good.nes", results[1].Path)
+	assert.Empty(t, results[1].Error)
+
+	_, err = findBatchJobs(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestBatchSameOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	roms := filepath.Join(dir, "roms")
+	outdir := filepath.Join(dir, "out")
+	assert.NoError(t, os.MkdirAll(filepath.Join(roms, "set"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(roms, "set", "a.nes"), testrom.INes(), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(roms, "set", "a.unf"), testrom.INes(), 0644))
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write(testrom.INes())
+	writer.Close()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(roms, "set", "A.nes.gz"), compressed.Bytes(), 0644))
+	writeTestZip(t, filepath.Join(roms, "set.zip"), map[string][]byte{"a.nes": testrom.INes()})
+
+	jobs, err := findBatchJobs(roms)
+	assert.NoError(t, err)
+	results := map[string]batchResult{}
+	for _, result := range runBatchJobs(jobs, outdir, 2) {
+		results[result.Path] = result
+	}
+	assert.Len(t, results, 4)
+	assert.Empty(t, results[filepath.Join("set", "A.nes.gz")].Error)
+	assert.Empty(t, results[filepath.Join("set", "a.unf")].Error)
+	assert.Empty(t, results[filepath.Join("set.zip", "a.nes")].Error)
+	assert.Equal(t, "same listing as '"+filepath.Join("set", "A.nes.gz")+"'", results[filepath.Join("set", "a.nes")].Error)
+	assert.FileExists(t, filepath.Join(outdir, "set", "a.unf.s"))
+	assert.FileExists(t, filepath.Join(outdir, "set.zip", "a.nes.s"))
+}
+
+func TestBatchArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "set.zip")
+	writeTestZip(t, path, map[string][]byte{"a.nes": []byte("a"), "b.nes": []byte("b")})
+
+	archive := &batchArchive{path: path, pending: 2}
+	data, err := archive.read(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", string(data))
+	reader := archive.reader
+	data, err = archive.read(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+	assert.True(t, reader == archive.reader, "The archive is opened once")
+	archive.done()
+	assert.NotNil(t, archive.reader)
+	archive.done()
+	assert.Nil(t, archive.reader)
+}
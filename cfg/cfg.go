@@ -0,0 +1,269 @@
+// Package cfg builds the control-flow graphs
+// of the subroutines of a disassembly.
+package cfg
+
+import (
+	"sort"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// EdgeKind tells how the control flows from a block to another.
+type EdgeKind int
+
+const (
+	Fallthrough EdgeKind = iota // Next instruction
+	Branch                      // Conditional branch taken
+	Jump                        // JMP absolute
+	Return                      // Back from a JSR
+)
+
+func (kind EdgeKind) String() string {
+	switch kind {
+	case Fallthrough:
+		return "fallthrough"
+	case Branch:
+		return "branch"
+	case Jump:
+		return "jump"
+	case Return:
+		return "return"
+	}
+	return "unknown"
+}
+
+// ExitKind tells how a block without successors leaves its subroutine.
+type ExitKind int
+
+const (
+	NoExit       ExitKind = iota // The block has successors
+	ReturnExit                   // RTS or RTI
+	IndirectExit                 // JMP (indirect)
+	TailCallExit                 // JMP to another subroutine
+	BreakExit                    // BRK
+	UnknownExit                  // End of the decoded code
+)
+
+// Edge links two blocks.
+type Edge struct {
+	From *Block
+	To   *Block
+	Kind EdgeKind
+}
+
+// Block is a basic block: a sequence of instructions
+// only entered by its first one and only left by its last one.
+type Block struct {
+	Offset       int // PRG ROM offset of the first instruction
+	Instructions []nes.Instruction
+	Successors   []*Edge
+	Predecessors []*Edge
+	Calls        []int // PRG ROM offsets of the subroutines called by a JSR
+	Exit         ExitKind
+}
+
+// Address returns the CPU address of the block.
+func (block *Block) Address() uint16 {
+	return block.Instructions[0].Address
+}
+
+// Last returns the last instruction of the block.
+func (block *Block) Last() nes.Instruction {
+	return block.Instructions[len(block.Instructions)-1]
+}
+
+// End returns the PRG ROM offset following the block.
+func (block *Block) End() int {
+	last := block.Last()
+	return last.Offset + last.Size()
+}
+
+// Successor returns the successor reached through an edge kind.
+func (block *Block) Successor(kind EdgeKind) (*Block, bool) {
+	for _, edge := range block.Successors {
+		if edge.Kind == kind {
+			return edge.To, true
+		}
+	}
+	return nil, false
+}
+
+// Function is the control-flow graph of a subroutine.
+type Function struct {
+	Name    string
+	Offset  int
+	Entry   *Block
+	Blocks  []*Block // Sorted by offset
+	Callees []int    // PRG ROM offsets of the called subroutines
+	blocks  map[int]*Block
+}
+
+// Block returns the block starting at a PRG ROM offset.
+func (function *Function) Block(offset int) (*Block, bool) {
+	block, ok := function.blocks[offset]
+	return block, ok
+}
+
+// Graph gathers the control-flow graphs of every subroutine.
+type Graph struct {
+	Disassembly *nes.Disassembly
+	Functions   []*Function // Sorted by offset
+	functions   map[int]*Function
+}
+
+// Function returns the subroutine starting at a PRG ROM offset.
+func (graph *Graph) Function(offset int) (*Function, bool) {
+	function, ok := graph.functions[offset]
+	return function, ok
+}
+
+// FunctionByName returns the subroutine having a given label.
+func (graph *Graph) FunctionByName(name string) (*Function, bool) {
+	for _, function := range graph.Functions {
+		if function.Name == name {
+			return function, true
+		}
+	}
+	return nil, false
+}
+
+// Build returns the control-flow graphs of the subroutines
+// of an analyzed disassembly.
+func Build(d *nes.Disassembly) *Graph {
+	graph := &Graph{Disassembly: d, functions: map[int]*Function{}}
+	for _, offset := range d.Subroutines() {
+		function := BuildFunction(d, offset)
+		graph.Functions = append(graph.Functions, function)
+		graph.functions[offset] = function
+	}
+	return graph
+}
+
+// successor is a control transfer found while walking the code.
+type successor struct {
+	offset int
+	kind   EdgeKind
+}
+
+// successors returns the intra-procedural successors of an instruction.
+// Jumps to other subroutines are tail calls and are not followed.
+func successors(d *nes.Disassembly, entry int, inst nes.Instruction) ([]successor, ExitKind) {
+	bank := d.BankAt(inst.Offset).Index
+	next := inst.Offset + inst.Size()
+	switch {
+	case inst.IsBranch():
+		target, _ := inst.Target()
+		result := []successor{{next, Fallthrough}}
+		if offset, ok := d.Resolve(bank, target); ok {
+			result = append(result, successor{offset, Branch})
+		}
+		return result, NoExit
+	case inst.IsCall():
+		return []successor{{next, Return}}, NoExit
+	case inst.Code == nes.JmpAbsolute:
+		offset, ok := d.Resolve(bank, inst.Operand)
+		if !ok || (offset != entry && d.IsSubroutine(offset)) {
+			return nil, TailCallExit
+		}
+		return []successor{{offset, Jump}}, NoExit
+	case inst.Code == nes.JmpIndirect:
+		return nil, IndirectExit
+	case inst.Code == nes.RtsImplied, inst.Code == nes.RtiImplied:
+		return nil, ReturnExit
+	case inst.Code == nes.Brk:
+		return nil, BreakExit
+	}
+	return []successor{{next, Fallthrough}}, NoExit
+}
+
+// BuildFunction returns the control-flow graph
+// of the subroutine starting at `entry`.
+func BuildFunction(d *nes.Disassembly, entry int) *Function {
+	function := &Function{Offset: entry, blocks: map[int]*Block{}}
+	function.Name, _ = d.Label(entry)
+
+	// Find the reachable instructions and the block leaders
+	reached := map[int]nes.Instruction{}
+	leaders := map[int]bool{entry: true}
+	stack := []int{entry}
+	for len(stack) > 0 {
+		offset := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if _, ok := reached[offset]; ok {
+			continue
+		}
+		inst, ok := d.Instruction(offset)
+		if !ok || d.Kind(offset) != nes.CodeByte {
+			continue
+		}
+		reached[offset] = inst
+		next, _ := successors(d, entry, inst)
+		for _, succ := range next {
+			if succ.kind != Fallthrough || inst.IsBranch() {
+				leaders[succ.offset] = true
+			}
+			stack = append(stack, succ.offset)
+		}
+		if len(next) == 0 || inst.IsCall() {
+			leaders[inst.Offset+inst.Size()] = true
+		}
+	}
+
+	// Split the instructions into blocks
+	offsets := make([]int, 0, len(reached))
+	for offset := range reached {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	var current *Block
+	for _, offset := range offsets {
+		inst := reached[offset]
+		if current == nil || leaders[offset] || current.End() != offset {
+			current = &Block{Offset: offset}
+			function.Blocks = append(function.Blocks, current)
+			function.blocks[offset] = current
+		}
+		current.Instructions = append(current.Instructions, inst)
+	}
+	function.Entry = function.blocks[entry]
+
+	// Link the blocks
+	callees := map[int]bool{}
+	for _, block := range function.Blocks {
+		last := block.Last()
+		next, exit := successors(d, entry, last)
+		block.Exit = exit
+		for _, succ := range next {
+			to, ok := function.blocks[succ.offset]
+			if !ok {
+				continue
+			}
+			edge := &Edge{From: block, To: to, Kind: succ.kind}
+			block.Successors = append(block.Successors, edge)
+			to.Predecessors = append(to.Predecessors, edge)
+		}
+		if len(block.Successors) == 0 && exit == NoExit {
+			block.Exit = UnknownExit
+		}
+		for _, inst := range block.Instructions {
+			if !inst.IsCall() {
+				continue
+			}
+			target, _ := inst.Target()
+			if offset, ok := d.Resolve(d.BankAt(inst.Offset).Index, target); ok {
+				block.Calls = append(block.Calls, offset)
+				if !callees[offset] {
+					callees[offset] = true
+					function.Callees = append(function.Callees, offset)
+				}
+			}
+		}
+		if exit == TailCallExit && last.Code == nes.JmpAbsolute {
+			if offset, ok := d.Resolve(d.BankAt(last.Offset).Index, last.Operand); ok && !callees[offset] {
+				callees[offset] = true
+				function.Callees = append(function.Callees, offset)
+			}
+		}
+	}
+	return function
+}
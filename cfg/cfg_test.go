@@ -0,0 +1,74 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// newTestDisassembly analyzes a 16 KiB PRG ROM mapped
+// at $C000 with `code` at $C000 as the reset handler.
+func newTestDisassembly(code ...byte) *nes.Disassembly {
+	d := nes.NewDisassembly(testrom.Prg(code...))
+	d.AddVectors()
+	d.Analyze()
+	return d
+}
+
+func TestBuildFunction(t *testing.T) {
+	d := newTestDisassembly(
+		0xA2, 0x05, // C000: LDX #$05
+		0xCA,       // C002: DEX
+		0xD0, 0xFD, // C003: BNE $C002
+		0x20, 0x0B, 0xC0, // C005: JSR $C00B
+		0x4C, 0x00, 0xC0, // C008: JMP $C000
+		0x60, // C00B: RTS
+	)
+	function := BuildFunction(d, 0)
+	assert.Equal(t, "nmi", function.Name)
+	assert.Len(t, function.Blocks, 4)
+
+	entry := function.Entry
+	assert.Len(t, entry.Instructions, 1)
+	loop, ok := entry.Successor(Fallthrough)
+	assert.True(t, ok)
+	assert.Equal(t, 0x02, loop.Offset)
+
+	taken, ok := loop.Successor(Branch)
+	assert.True(t, ok)
+	assert.Equal(t, loop, taken)
+	call, ok := loop.Successor(Fallthrough)
+	assert.True(t, ok)
+	assert.Equal(t, []int{0x0B}, call.Calls)
+	assert.Len(t, loop.Predecessors, 2)
+
+	jump, ok := call.Successor(Return)
+	assert.True(t, ok)
+	back, ok := jump.Successor(Jump)
+	assert.True(t, ok)
+	assert.Equal(t, entry, back)
+	assert.Equal(t, []int{0x0B}, function.Callees)
+}
+
+func TestBuild(t *testing.T) {
+	d := newTestDisassembly(
+		0x20, 0x04, 0xC0, // C000: JSR $C004
+		0x40,       // C003: RTI
+		0xB0, 0x01, // C004: BCS $C007
+		0x60,             // C006: RTS
+		0x4C, 0x00, 0xC0, // C007: JMP $C000
+	)
+	graph := Build(d)
+	assert.Len(t, graph.Functions, 2)
+
+	function, ok := graph.FunctionByName("sub_C004")
+	assert.True(t, ok)
+	assert.Len(t, function.Blocks, 3)
+	exit, _ := function.Block(0x06)
+	assert.Equal(t, ReturnExit, exit.Exit)
+	tail, _ := function.Block(0x07)
+	assert.Equal(t, TailCallExit, tail.Exit)
+	assert.Equal(t, []int{0x00}, function.Callees)
+}
@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// CycleRange returns the duration of a block, from all its
+// instructions at their best to all at their worst. Subroutine
+// calls only count the JSR.
+func (block *Block) CycleRange() nes.CycleRange {
+	var r nes.CycleRange
+	for _, inst := range block.Instructions {
+		r = r.Add(inst.CycleRange())
+	}
+	return r
+}
+
+// AnnotateCycles annotates the listing of the disassembly
+// with the duration of each instruction and block.
+//  LDA $0300,X                  ; block: 6-7 cycles; 4 cycles
+func (graph *Graph) AnnotateCycles() {
+	d := graph.Disassembly
+	d.ShowCycles()
+	annotated := map[int]bool{}
+	for _, function := range graph.Functions {
+		for _, block := range function.Blocks {
+			if !annotated[block.Offset] {
+				annotated[block.Offset] = true
+				d.AddComment(block.Offset, fmt.Sprintf("block: %s cycles", block.CycleRange()))
+			}
+		}
+	}
+}
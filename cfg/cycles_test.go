@@ -0,0 +1,30 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func TestCycleRange(t *testing.T) {
+	d := newTestDisassembly(
+		0xA2, 0x05, // C000: LDX #$05
+		0xBD, 0xF0, 0x03, // C002: LDA $03F0,X
+		0xCA,       // C005: DEX
+		0xD0, 0xFA, // C006: BNE $C002
+		0x60, // C008: RTS
+	)
+	graph := Build(d)
+	function, ok := graph.Function(0)
+	assert.True(t, ok)
+	loop, ok := function.Block(0x02)
+	assert.True(t, ok)
+	assert.Equal(t, nes.CycleRange{Min: 8, Max: 10}, loop.CycleRange())
+
+	graph.AnnotateCycles()
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDX #$05                     ; block: 2 cycles; 2 cycles\n"))
+	assert.True(t, strings.Contains(asm, "; block: 8-10 cycles; 4-5 cycles (page cross)\n"))
+}
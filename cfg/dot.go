@@ -0,0 +1,89 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bankColors are the fill colors of the banks in call graphs.
+var bankColors = []string{
+	"#fbb4ae", "#b3cde3", "#ccebc5", "#decbe4",
+	"#fed9a6", "#ffffcc", "#e5d8bd", "#fddaec",
+}
+
+// fixedBankColor is the fill color of the fixed bank(s).
+const fixedBankColor = "#f2f2f2"
+
+// escapeDot escapes a string for a double-quoted DOT identifier.
+func escapeDot(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// nodeName returns the DOT identifier of a PRG ROM offset.
+func nodeName(offset int) string {
+	return fmt.Sprintf("n%05X", offset)
+}
+
+// Dot returns the control-flow graph of a subroutine in Graphviz
+// DOT language, with the instructions of each block inside its node.
+func (graph *Graph) Dot(function *Function) string {
+	d := graph.Disassembly
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("digraph \"%s\" {\n", escapeDot(function.Name)))
+	builder.WriteString("    node [shape=box, fontname=\"monospace\"];\n")
+	for _, block := range function.Blocks {
+		var text strings.Builder
+		if label, ok := d.Label(block.Offset); ok {
+			text.WriteString(label + ":\\l")
+		}
+		for _, inst := range block.Instructions {
+			line := fmt.Sprintf("%04X  %s", inst.Address, d.FormatInstruction(inst))
+			text.WriteString(escapeDot(line) + "\\l")
+		}
+		builder.WriteString(fmt.Sprintf("    %s [label=\"%s\"];\n", nodeName(block.Offset), text.String()))
+	}
+	for _, block := range function.Blocks {
+		for _, edge := range block.Successors {
+			style := ""
+			switch edge.Kind {
+			case Branch:
+				style = " [color=\"darkgreen\", label=\"taken\"]"
+			case Jump:
+				style = " [style=\"bold\"]"
+			case Return:
+				style = " [style=\"dashed\"]"
+			}
+			builder.WriteString(fmt.Sprintf("    %s -> %s%s;\n", nodeName(edge.From.Offset), nodeName(edge.To.Offset), style))
+		}
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
+
+// CallGraphDot returns the JSR graph of the whole ROM in Graphviz
+// DOT language. Subroutines are colored according to their bank.
+func (graph *Graph) CallGraphDot() string {
+	d := graph.Disassembly
+	var builder strings.Builder
+	builder.WriteString("digraph callgraph {\n")
+	builder.WriteString("    node [shape=box, style=filled, fontname=\"monospace\"];\n")
+	for _, function := range graph.Functions {
+		bank := d.BankAt(function.Offset)
+		color := fixedBankColor
+		if !bank.Fixed {
+			color = bankColors[bank.Index%len(bankColors)]
+		}
+		builder.WriteString(fmt.Sprintf("    %s [label=\"%s\\nbank %d\", fillcolor=\"%s\"];\n",
+			nodeName(function.Offset), escapeDot(function.Name), bank.Index, color))
+	}
+	for _, function := range graph.Functions {
+		for _, callee := range function.Callees {
+			if _, ok := graph.functions[callee]; ok {
+				builder.WriteString(fmt.Sprintf("    %s -> %s;\n", nodeName(function.Offset), nodeName(callee)))
+			}
+		}
+	}
+	builder.WriteString("}\n")
+	return builder.String()
+}
@@ -0,0 +1,29 @@
+package cfg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDot(t *testing.T) {
+	d := newTestDisassembly(
+		0xCA,       // C000: DEX
+		0xD0, 0xFD, // C001: BNE $C000
+		0x20, 0x07, 0xC0, // C003: JSR $C007
+		0x40, // C006: RTI
+		0x60, // C007: RTS
+	)
+	graph := Build(d)
+	function, _ := graph.FunctionByName("nmi")
+	dot := graph.Dot(function)
+	assert.True(t, strings.HasPrefix(dot, "digraph \"nmi\" {\n"))
+	assert.True(t, strings.Contains(dot, "n00000 [label=\"nmi:\\lC000  DEX\\lC001  BNE nmi\\l\"];\n"))
+	assert.True(t, strings.Contains(dot, "n00000 -> n00000 [color=\"darkgreen\", label=\"taken\"];\n"))
+	assert.True(t, strings.Contains(dot, "n00003 -> n00006 [style=\"dashed\"];\n"))
+
+	callGraph := graph.CallGraphDot()
+	assert.True(t, strings.Contains(callGraph, "n00000 [label=\"nmi\\nbank 0\", fillcolor=\"#f2f2f2\"];\n"))
+	assert.True(t, strings.Contains(callGraph, "n00000 -> n00007;\n"))
+}
@@ -0,0 +1,163 @@
+package cfg
+
+import (
+	"sort"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+const (
+	InterruptCycles = 7   // Pushing PC and P, then reading the vector
+	oamDmaCycles    = 514 // Writing $4014, at worst
+)
+
+// Timing is the worst-case duration of a subroutine, in CPU cycles,
+// including its callees. Loops are counted as a single iteration.
+type Timing struct {
+	Total         int
+	PPUWrite      int // Up to the end of the last PPU write, -1 without any
+	PPUWriteAt    int // PRG ROM offset of the last PPU write, or of the call leading to it
+	Loops         []int
+	Indirect      []int // Offsets of the indirect jumps, not followed
+	DMA           bool  // The subroutine starts an OAM DMA
+	inProgress    bool
+	unknownCallee bool
+}
+
+// writesPPU returns true for the stores to the PPU registers
+// ($2000-$3FFF) and to OAMDMA ($4014).
+func writesPPU(inst nes.Instruction) bool {
+	switch inst.Mnemonic {
+	case "STA", "STX", "STY":
+	default:
+		return false
+	}
+	address, ok := inst.OperandAddress()
+	return ok && (address >= 0x2000 && address < 0x4000 || address == 0x4014)
+}
+
+// timer computes the timings of the subroutines of a graph.
+type timer struct {
+	graph   *Graph
+	timings map[int]*Timing
+}
+
+// WorstCase returns the worst-case timing of a subroutine.
+func (graph *Graph) WorstCase(function *Function) Timing {
+	t := &timer{graph: graph, timings: map[int]*Timing{}}
+	return *t.timing(function)
+}
+
+// callee returns the timing of the subroutine at `offset`.
+func (t *timer) callee(offset int) *Timing {
+	if timing, ok := t.timings[offset]; ok {
+		return timing
+	}
+	function, ok := t.graph.Function(offset)
+	if !ok {
+		function = BuildFunction(t.graph.Disassembly, offset)
+	}
+	return t.timing(function)
+}
+
+func (t *timer) timing(function *Function) *Timing {
+	timing := &Timing{PPUWrite: -1, inProgress: true}
+	t.timings[function.Offset] = timing
+	if function.Entry == nil {
+		timing.inProgress = false
+		return timing
+	}
+
+	// Drop the back edges, found by a depth-first search
+	var postorder []*Block
+	state := map[*Block]int{} // 1: on the stack, 2: done
+	back := map[*Edge]bool{}
+	var visit func(block *Block)
+	visit = func(block *Block) {
+		state[block] = 1
+		for _, edge := range block.Successors {
+			switch state[edge.To] {
+			case 0:
+				visit(edge.To)
+			case 1:
+				back[edge] = true
+				timing.Loops = append(timing.Loops, edge.To.Offset)
+			}
+		}
+		state[block] = 2
+		postorder = append(postorder, block)
+	}
+	visit(function.Entry)
+
+	// Longest paths, from the exits up to the entry
+	type path struct {
+		total, write, writeAt int
+	}
+	paths := map[*Block]path{}
+	for _, block := range postorder {
+		p := path{write: -1}
+		for _, inst := range block.Instructions {
+			p.total += inst.CycleRange().Max
+			if writesPPU(inst) {
+				if address, _ := inst.OperandAddress(); address == 0x4014 {
+					p.total += oamDmaCycles
+					timing.DMA = true
+				}
+				p.write, p.writeAt = p.total, inst.Offset
+			}
+			var callee *Timing
+			if inst.IsCall() || inst.Code == nes.JmpAbsolute && block.Exit == TailCallExit {
+				if offset, ok := t.graph.Disassembly.Resolve(t.graph.Disassembly.BankAt(inst.Offset).Index, inst.Operand); ok {
+					callee = t.callee(offset)
+				} else {
+					timing.unknownCallee = true
+				}
+			}
+			if inst.Code == nes.JmpIndirect {
+				timing.Indirect = append(timing.Indirect, inst.Offset)
+			}
+			if callee != nil {
+				if callee.inProgress || !callee.IsBounded() {
+					timing.unknownCallee = true
+				}
+				timing.Loops = append(timing.Loops, callee.Loops...)
+				p.total += callee.Total
+				if callee.PPUWrite >= 0 {
+					p.write, p.writeAt = p.total-callee.Total+callee.PPUWrite, inst.Offset
+				}
+				timing.DMA = timing.DMA || callee.DMA
+			}
+		}
+		cost := p.total
+		for _, edge := range block.Successors {
+			if back[edge] {
+				continue
+			}
+			next := paths[edge.To]
+			if cost+next.total > p.total {
+				p.total = cost + next.total
+			}
+			if next.write >= 0 && cost+next.write > p.write {
+				p.write, p.writeAt = cost+next.write, next.writeAt
+			}
+		}
+		paths[block] = p
+	}
+	entry := paths[function.Entry]
+	timing.Total, timing.PPUWrite, timing.PPUWriteAt = entry.total, entry.write, entry.writeAt
+	sort.Ints(timing.Loops)
+	for i := 1; i < len(timing.Loops); i++ {
+		if timing.Loops[i] == timing.Loops[i-1] {
+			timing.Loops = append(timing.Loops[:i], timing.Loops[i+1:]...)
+			i--
+		}
+	}
+	timing.inProgress = false
+	return timing
+}
+
+// IsBounded returns false if the timing misses some code: indirect
+// jumps, recursive calls or calls to undecoded subroutines.
+func (timing Timing) IsBounded() bool {
+	return len(timing.Indirect) == 0 && !timing.unknownCallee
+}
@@ -0,0 +1,40 @@
+package cfg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorstCase(t *testing.T) {
+	d := newTestDisassembly(
+		0xA9, 0x00, // C000: LDA #$00
+		0x8D, 0x01, 0x20, // C002: STA $2001
+		0xA9, 0x02, // C005: LDA #$02
+		0x8D, 0x14, 0x40, // C007: STA $4014
+		0x20, 0x10, 0xC0, // C00A: JSR $C010
+		0x40, // C00D: RTI
+		0xFF, 0xFF,
+		0xA2, 0x04, // C010: LDX #$04
+		0xCA,       // C012: DEX
+		0xD0, 0xFD, // C013: BNE $C012
+		0xAD, 0x02, 0x20, // C015: LDA $2002
+		0x60, // C018: RTS
+	)
+	graph := Build(d)
+	nmi, ok := graph.Function(0)
+	assert.True(t, ok)
+	timing := graph.WorstCase(nmi)
+	assert.Equal(t, 2+4+2+4+514+6+17+6, timing.Total)
+	assert.Equal(t, 2+4+2+4+514, timing.PPUWrite)
+	assert.Equal(t, 0x07, timing.PPUWriteAt)
+	assert.Equal(t, []int{0x12}, timing.Loops)
+	assert.True(t, timing.DMA)
+	assert.True(t, timing.IsBounded())
+
+	sub, ok := graph.Function(0x10)
+	assert.True(t, ok)
+	timing = graph.WorstCase(sub)
+	assert.Equal(t, 17, timing.Total)
+	assert.Equal(t, -1, timing.PPUWrite)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["db"] = command{
+		run:   runDb,
+		usage: "db -db XXX.xml [-fix -o YYY.nes [-force]] XXX.nes: identify a ROM in a No-Intro or NesCartDB database and check its header",
+	}
+}
+
+func readRomDb(path string) (*nes.RomDb, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	db, err := nes.ReadRomDb(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return db, nil
+}
+
+// dbReport returns the database entry of a ROM
+// and the header fields to fix, one per line.
+func dbReport(db *nes.RomDb, rom *nes.Rom) ([]string, *nes.DbEntry) {
+	entry, ok := db.Lookup(rom)
+	if !ok {
+		return []string{"Unknown ROM"}, nil
+	}
+	title := entry.Title
+	if entry.Region != "" {
+		title += fmt.Sprintf(" (%s)", entry.Region)
+	}
+	lines := []string{fmt.Sprintf("Title: %s", title)}
+	if entry.Header == nil {
+		return lines, entry
+	}
+	lines = append(lines, fmt.Sprintf("Board: %s, mapper %d", entry.Header.Board, entry.Header.Mapper))
+	for _, mismatch := range entry.Check(rom) {
+		lines = append(lines, fmt.Sprintf("Header: %s", mismatch))
+	}
+	return lines, entry
+}
+
+func runDb(args []string) error {
+	flags := flag.NewFlagSet("db", flag.ExitOnError)
+	dbPath := flags.String("db", "", "No-Intro DAT or NesCartDB XML file")
+	fix := flags.Bool("fix", false, "Write the ROM with the NES 2.0 header of the database")
+	output := flags.String("o", "", "Fixed ROM (*.nes)")
+	force := flags.Bool("force", false, "Fix even if some fields cannot be written")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || *dbPath == "" || *fix != (*output != "") {
+		return errors.New("usage: db -db XXX.xml [-fix -o YYY.nes [-force]] XXX.nes")
+	}
+
+	rom, err := readRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	db, err := readRomDb(*dbPath)
+	if err != nil {
+		return err
+	}
+	lines, entry := dbReport(db, rom)
+	if !*fix {
+		if entry != nil && len(entry.Check(rom)) > 0 {
+			lines = append(lines, fmt.Sprintf("Fix: ./decompiler db -db %s -fix -o YYY.nes %s", *dbPath, flags.Arg(0)))
+		}
+		return writeOutput("", strings.Join(lines, "\n"))
+	}
+	if entry == nil || entry.Header == nil {
+		return errors.New("no known header for this ROM")
+	}
+	fixed, losses, err := entry.Fix(rom).Encode(nes.Nes2)
+	if err != nil {
+		return err
+	}
+	if len(losses) > 0 && !*force {
+		return fmt.Errorf("%s cannot hold the %s (use -force to fix anyway)", nes.Nes2, strings.Join(losses, ", "))
+	}
+	return ioutil.WriteFile(*output, fixed, 0644)
+}
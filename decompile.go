@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/pseudoc"
+)
+
+func init() {
+	commands["decompile"] = command{
+		run:   runDecompile,
+		usage: "decompile [-sub NAME] [-o XXX.c] XXX.nes: decompile subroutines to pseudo-C",
+	}
+}
+
+func runDecompile(args []string) error {
+	flags := flag.NewFlagSet("decompile", flag.ExitOnError)
+	sub := flags.String("sub", "", "Subroutine to decompile (label or $address), all if empty")
+	output := flags.String("o", "", "Output file (*.c)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: decompile [-sub NAME] [-o XXX.c] XXX.nes")
+	}
+
+	d, err := analyzeRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	graph := cfg.Build(d)
+	functions := graph.Functions
+	if *sub != "" {
+		function, err := findFunction(graph, *sub)
+		if err != nil {
+			return err
+		}
+		functions = []*cfg.Function{function}
+	}
+	sources := make([]string, len(functions))
+	for i, function := range functions {
+		sources[i] = pseudoc.Decompile(graph, function)
+	}
+	return writeOutput(*output, strings.Join(sources, "\n"))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["diff"] = command{
+		run:   runDiff,
+		usage: "diff [-o XXX.txt] XXX.nes YYY.nes: compare two ROMs instruction by instruction, bank by bank",
+	}
+}
+
+func runDiff(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: diff [-o XXX.txt] XXX.nes YYY.nes")
+	}
+
+	old, err := analyzeRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	hack, err := analyzeRom(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	diff := nes.Diff(old, hack)
+	if len(diff.Hunks) == 0 && len(diff.Relocations) == 0 {
+		return writeOutput(*output, "; No difference")
+	}
+	return writeOutput(*output, diff.String())
+}
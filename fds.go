@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["fds"] = command{
+		run:   runFds,
+		usage: "fds [-o XXX.txt] XXX.fds: list the sides and files of a Famicom Disk System image",
+	}
+}
+
+// readFds returns the FDS image at `path`.
+func readFds(path string) (*nes.FdsDisk, error) {
+	data, err := loadFile(path, *entryName)
+	if err != nil {
+		return nil, err
+	}
+	if !nes.IsFdsFile(data) {
+		return nil, fmt.Errorf("'%s' is not an FDS image", path)
+	}
+	disk, err := nes.ReadFds(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return disk, nil
+}
+
+// sideName returns the name of a disk side, e.g. "disk 1 side B".
+func sideName(side nes.FdsSide) string {
+	return fmt.Sprintf("disk %d side %c", side.Disk+1, 'A'+side.Side)
+}
+
+// writeFds disassembles every PRG file of an FDS image.
+func writeFds(disk *nes.FdsDisk) error {
+	var listings []string
+	for _, side := range disk.Sides {
+		for _, file := range side.Files {
+			if file.Kind != nes.FdsPrg {
+				continue
+			}
+			disassembly := side.Disassembly(file)
+			if err := setVariableNames(disassembly); err != nil {
+				return err
+			}
+			if err := setTextTable(disassembly); err != nil {
+				return err
+			}
+			disassembly.Analyze()
+			if *showCycles {
+				cfg.Build(disassembly).AnnotateCycles()
+			}
+			listings = append(listings, fmt.Sprintf("; %s, file %d '%s' ($%04X, %d bytes)\n%s",
+				sideName(side), file.Number, file.Name, file.Address, len(file.Data), disassembly.String()))
+		}
+	}
+	return writeOutput(*outputFile, strings.Join(listings, "\n\n"))
+}
+
+func runFds(args []string) error {
+	flags := flag.NewFlagSet("fds", flag.ExitOnError)
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: fds [-o XXX.txt] XXX.fds")
+	}
+
+	disk, err := readFds(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, side := range disk.Sides {
+		lines = append(lines, fmt.Sprintf("%s: %s, boot files up to ID %d", sideName(side), side.GameName, side.BootFileID))
+		for _, file := range side.Files {
+			lines = append(lines, fmt.Sprintf("  %02X %02X %-8s %-4s $%04X %5d bytes",
+				file.Number, file.ID, file.Name, file.Kind, file.Address, len(file.Data)))
+		}
+	}
+	return writeOutput(*output, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["graph"] = command{
+		run:   runGraph,
+		usage: "graph (-sub NAME | -callgraph) [-o XXX.dot] XXX.nes: export a subroutine CFG or the call graph (Graphviz)",
+	}
+}
+
+// parseAddress parses "$C012", "0xC012" or "C012".
+func parseAddress(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "$"), "0x")
+	address, err := strconv.ParseUint(s, 16, 16)
+	return uint16(address), err
+}
+
+// findFunction returns the subroutine named `name`,
+// or starting at `name` if it is an address.
+func findFunction(graph *cfg.Graph, name string) (*cfg.Function, error) {
+	if function, ok := graph.FunctionByName(name); ok {
+		return function, nil
+	}
+	d := graph.Disassembly
+	if address, err := parseAddress(name); err == nil {
+		if offset, ok := d.Resolve(-1, address); ok && d.Kind(offset) == nes.CodeByte {
+			if function, ok := graph.Function(offset); ok {
+				return function, nil
+			}
+			return cfg.BuildFunction(d, offset), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown subroutine '%s'", name)
+}
+
+func runGraph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ExitOnError)
+	sub := flags.String("sub", "", "Subroutine to export (label or $address)")
+	callGraph := flags.Bool("callgraph", false, "Export the JSR graph of the whole ROM")
+	output := flags.String("o", "", "Output file (*.dot)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*sub == "") == !*callGraph {
+		return errors.New("usage: graph (-sub NAME | -callgraph) [-o XXX.dot] XXX.nes")
+	}
+
+	d, err := analyzeRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	graph := cfg.Build(d)
+	if *callGraph {
+		return writeOutput(*output, graph.CallGraphDot())
+	}
+	function, err := findFunction(graph, *sub)
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, graph.Dot(function))
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["header"] = command{
+		run:   runHeader,
+		usage: "header [-to ines|nes2 -o YYY.nes] [-force] [-ask] [-submapper N] [-prgram N] ... XXX.nes: show or convert the header of an iNES, NES 2.0 or UNIF ROM",
+	}
+}
+
+// headerInfo returns the header of a ROM, one field per line.
+func headerInfo(rom *nes.Rom) []string {
+	board := fmt.Sprintf("%d", rom.Mapper)
+	if rom.Board != "" {
+		board = fmt.Sprintf("%d (%s)", rom.Mapper, rom.Board)
+	}
+	lines := []string{
+		fmt.Sprintf("Format: %s", rom.Format),
+		fmt.Sprintf("Mapper: %s, submapper %d", board, rom.Submapper),
+		fmt.Sprintf("PRG ROM: %d KiB, CHR ROM: %d KiB", len(rom.Prg)/1024, len(rom.Chr)/1024),
+		fmt.Sprintf("PRG RAM: %d bytes, NVRAM: %d bytes", rom.PrgRamSize, rom.PrgNvramSize),
+		fmt.Sprintf("CHR RAM: %d bytes, NVRAM: %d bytes", rom.ChrRamSize, rom.ChrNvramSize),
+		fmt.Sprintf("Mirroring: %s", rom.Mirroring),
+		fmt.Sprintf("Battery: %t, trainer: %t", rom.Battery, rom.Trainer != nil),
+		fmt.Sprintf("Region: %s", rom.Region),
+	}
+	if rom.Name != "" {
+		lines = append(lines, fmt.Sprintf("Name: %s", rom.Name))
+	}
+	return lines
+}
+
+// headerFields are the header fields which can be set by a flag
+// or asked, when upgrading to NES 2.0.
+var headerFields = []struct {
+	name  string
+	usage string
+	value func(header *nes.Header) string
+	set   func(header *nes.Header, value string) error
+}{
+	{"mapper", "iNES mapper number",
+		func(header *nes.Header) string { return strconv.Itoa(header.Mapper) },
+		func(header *nes.Header, value string) (err error) {
+			header.Mapper, err = parseHeaderCount(value)
+			return
+		}},
+	{"submapper", "NES 2.0 submapper number",
+		func(header *nes.Header) string { return strconv.Itoa(header.Submapper) },
+		func(header *nes.Header, value string) (err error) {
+			header.Submapper, err = parseHeaderCount(value)
+			return
+		}},
+	{"prgram", "PRG RAM size, in bytes",
+		func(header *nes.Header) string { return strconv.Itoa(header.PrgRamSize) },
+		func(header *nes.Header, value string) (err error) {
+			header.PrgRamSize, err = parseHeaderCount(value)
+			return
+		}},
+	{"prgnvram", "Battery-backed PRG RAM size, in bytes",
+		func(header *nes.Header) string { return strconv.Itoa(header.PrgNvramSize) },
+		func(header *nes.Header, value string) (err error) {
+			header.PrgNvramSize, err = parseHeaderCount(value)
+			return
+		}},
+	{"chrram", "CHR RAM size, in bytes",
+		func(header *nes.Header) string { return strconv.Itoa(header.ChrRamSize) },
+		func(header *nes.Header, value string) (err error) {
+			header.ChrRamSize, err = parseHeaderCount(value)
+			return
+		}},
+	{"chrnvram", "Battery-backed CHR RAM size, in bytes",
+		func(header *nes.Header) string { return strconv.Itoa(header.ChrNvramSize) },
+		func(header *nes.Header, value string) (err error) {
+			header.ChrNvramSize, err = parseHeaderCount(value)
+			return
+		}},
+	{"region", "ntsc, pal, multi-region or dendy",
+		func(header *nes.Header) string { return header.Region.String() },
+		func(header *nes.Header, value string) (err error) {
+			header.Region, err = nes.ParseRegion(value)
+			return
+		}},
+}
+
+// parseHeaderCount parses a mapper number or a size,
+// which cannot be negative.
+func parseHeaderCount(value string) (int, error) {
+	count, err := strconv.Atoi(value)
+	if err == nil && count < 0 {
+		return 0, fmt.Errorf("%d is negative", count)
+	}
+	return count, err
+}
+
+// askHeaderFields asks the value of the header fields
+// which were not set by a flag, keeping the current
+// value on empty answers.
+func askHeaderFields(header *nes.Header, set map[string]bool) error {
+	scanner := bufio.NewScanner(os.Stdin)
+	for _, field := range headerFields {
+		if set[field.name] {
+			continue
+		}
+		fmt.Printf("%s [%s]: ", field.usage, field.value(header))
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		if answer := strings.TrimSpace(scanner.Text()); answer != "" {
+			if err := field.set(header, answer); err != nil {
+				return fmt.Errorf("invalid %s: %s", field.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func runHeader(args []string) error {
+	flags := flag.NewFlagSet("header", flag.ExitOnError)
+	to := flags.String("to", "", "Convert to ines or nes2")
+	output := flags.String("o", "", "Converted ROM (*.nes)")
+	force := flags.Bool("force", false, "Convert even if some fields cannot be written")
+	ask := flags.Bool("ask", false, "Ask the fields not given by a flag")
+	values := map[string]*string{}
+	for _, field := range headerFields {
+		values[field.name] = flags.String(field.name, "", field.usage)
+	}
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 || (*to == "") != (*output == "") {
+		return errors.New("usage: header [-to ines|nes2 -o YYY.nes] [-force] [-ask] [-submapper N] [-prgram N] ... XXX.nes")
+	}
+
+	data, err := loadFile(flags.Arg(0), *entryName)
+	if err != nil {
+		return err
+	}
+	rom, err := nes.ReadRom(data)
+	if err != nil {
+		return err
+	}
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, field := range headerFields {
+		if !set[field.name] {
+			continue
+		}
+		if err := field.set(&rom.Header, *values[field.name]); err != nil {
+			return fmt.Errorf("invalid %s: %s", field.name, err)
+		}
+	}
+	if *to == "" {
+		lines := headerInfo(rom)
+		if nes.HasHeaderGarbage(data) {
+			lines = append(lines, "Garbage in bytes 7-15 (ignored)")
+		}
+		return writeOutput("", strings.Join(lines, "\n"))
+	}
+
+	var format nes.RomFormat
+	switch strings.ToLower(*to) {
+	case "ines":
+		format = nes.INes
+	case "nes2":
+		format = nes.Nes2
+	default:
+		return fmt.Errorf("unknown header format '%s'", *to)
+	}
+	if *ask {
+		if err := askHeaderFields(&rom.Header, set); err != nil {
+			return err
+		}
+	}
+	converted, losses, err := rom.Encode(format)
+	if err != nil {
+		return err
+	}
+	if len(losses) > 0 && !*force {
+		return fmt.Errorf("%s cannot hold the %s (use -force to convert anyway)", format, strings.Join(losses, ", "))
+	}
+	return ioutil.WriteFile(*output, converted, 0644)
+}
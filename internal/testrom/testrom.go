@@ -0,0 +1,23 @@
+// Package testrom builds the small ROMs used by the tests.
+package testrom
+
+// Prg returns a 16 KiB PRG ROM mapped at $C000, with `code`
+// at $C000 and the NMI, reset and IRQ vectors pointing to it.
+// The other bytes are $FF.
+func Prg(code ...byte) []byte {
+	prg := make([]byte, 0x4000)
+	for i := range prg {
+		prg[i] = 0xFF
+	}
+	copy(prg, code)
+	copy(prg[0x3FFA:], []byte{0x00, 0xC0, 0x00, 0xC0, 0x00, 0xC0})
+	return prg
+}
+
+// INes returns an NROM-128 iNES file holding Prg(code...)
+// and 8 KiB of CHR ROM.
+func INes(code ...byte) []byte {
+	rom := append([]byte("NES\x1A\x01\x01"), make([]byte, 10)...)
+	rom = append(rom, Prg(code...)...)
+	return append(rom, make([]byte, 0x2000)...)
+}
@@ -0,0 +1,93 @@
+package ir
+
+import "github.com/vpenando/nes-rom-decompiler/nes"
+
+// operand16 returns the value of a 16-bit idiom operand.
+func operand16(op nes.Operand16) Expr {
+	address := Const{int(op.Value)}
+	switch {
+	case op.Immediate:
+		return Const{int(op.Value)}
+	case op.Byte:
+		return Load{address}
+	}
+	return Word{address, false}
+}
+
+// LiftIdiom returns the statements executing a 16-bit idiom as a
+// whole. The registers and flags end up as after its instructions,
+// except that the high byte of a word is always written back.
+//  CLC; LDA $10; ADC #$20; STA $10; LDA $11; ADC #$00; STA $11 ->
+//  t = word($10) + $20; ...; Store16{$10, t & $FFFF}; A = t >> 8 & $FF
+func (l *Lifter) LiftIdiom(idiom *nes.Idiom) []Stmt {
+	var stmts []Stmt
+	emit := func(s ...Stmt) {
+		stmts = append(stmts, s...)
+	}
+	setNZ := func(value Expr) {
+		emit(Assign{Z, Bin(Eq, value, Const{0})}, Assign{N, Bin(Ge, value, Const{0x80})})
+	}
+	// value reads an operand once
+	value := func(op nes.Operand16) Expr {
+		e := operand16(op)
+		if _, ok := e.(Const); ok {
+			return e
+		}
+		t := l.temp()
+		emit(Assign{t, e})
+		return Reg{t}
+	}
+	dest := Const{int(idiom.Dest.Value)}
+	high := func(e Expr) Expr {
+		return Bin(And, Bin(Shr, e, Const{8}), Const{0xFF})
+	}
+
+	switch idiom.Kind {
+	case nes.Add16:
+		left, right := value(idiom.Left), value(idiom.Right)
+		t := l.temp()
+		emit(Assign{t, Bin(Add, left, right)})
+		emit(Assign{V, Bin(Ne, Bin(And, Bin(And,
+			Bin(Xor, Bin(Shr, left, Const{8}), high(Reg{t})),
+			Bin(Xor, Bin(Shr, right, Const{8}), high(Reg{t}))), Const{0x80}), Const{0})})
+		emit(Assign{C, Bin(Gt, Reg{t}, Const{0xFFFF})})
+		emit(Store16{dest, Bin(And, Reg{t}, Const{0xFFFF})})
+		emit(Assign{A, high(Reg{t})})
+		setNZ(Reg{A})
+	case nes.Sub16, nes.Compare16:
+		left, right := value(idiom.Left), value(idiom.Right)
+		t := l.temp()
+		emit(Assign{t, Bin(Sub, left, right)})
+		emit(Assign{V, Bin(Ne, Bin(And, Bin(And,
+			Bin(Xor, Bin(Shr, left, Const{8}), Bin(Shr, right, Const{8})),
+			Bin(Xor, Bin(Shr, left, Const{8}), high(Reg{t}))), Const{0x80}), Const{0})})
+		emit(Assign{C, Bin(Ge, Reg{t}, Const{0})})
+		if idiom.Kind == nes.Sub16 {
+			emit(Store16{dest, Bin(And, Reg{t}, Const{0xFFFF})})
+		}
+		emit(Assign{A, high(Reg{t})})
+		setNZ(Reg{A})
+	case nes.Increment16:
+		t := l.temp()
+		emit(Assign{t, Bin(And, Bin(Add, Word{dest, false}, Const{1}), Const{0xFFFF})})
+		emit(Store16{dest, Reg{t}})
+		// The flags come from the high byte only if the low byte wrapped
+		lo := Bin(And, Reg{t}, Const{0xFF})
+		emit(Assign{Z, Bin(Eq, Reg{t}, Const{0})})
+		emit(Assign{N, Bin(Or, Bin(Ge, lo, Const{0x80}),
+			Bin(And, Bin(Eq, lo, Const{0}), Bin(Ge, Reg{t}, Const{0x8000})))})
+	case nes.Decrement16:
+		emit(Assign{A, Load{dest}})
+		t := l.temp()
+		emit(Assign{t, Bin(And, Bin(Sub, Word{dest, false}, Const{1}), Const{0xFFFF})})
+		emit(Store16{dest, Reg{t}})
+		setNZ(Bin(And, Reg{t}, Const{0xFF}))
+	case nes.PointerSetup:
+		first, second := idiom.Instructions[0], idiom.Instructions[2]
+		emit(Store16{dest, operand16(idiom.Right)})
+		emit(Assign{loadRegisters[first.Mnemonic], Const{int(first.Operand)}})
+		emit(Assign{loadRegisters[second.Mnemonic], Const{int(second.Operand)}})
+		setNZ(Const{int(second.Operand)})
+	}
+	return stmts
+}
@@ -0,0 +1,109 @@
+package ir
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// TestLiftIdiom executes 16-bit idioms from random states, both
+// instruction by instruction and as the statements of LiftIdiom.
+func TestLiftIdiom(t *testing.T) {
+	idioms := map[nes.IdiomKind][]byte{
+		nes.Add16: {
+			0x18,       // CLC
+			0xA5, 0x10, // LDA $10
+			0x6D, 0x00, 0x03, // ADC $0300
+			0x85, 0x10, // STA $10
+			0xA5, 0x11, // LDA $11
+			0x6D, 0x01, 0x03, // ADC $0301
+			0x85, 0x11, // STA $11
+		},
+		nes.Sub16: {
+			0xA5, 0x10, // LDA $10
+			0x38,       // SEC
+			0xE9, 0x40, // SBC #$40
+			0x85, 0x12, // STA $12
+			0xA5, 0x11, // LDA $11
+			0xE9, 0x01, // SBC #$01
+			0x85, 0x13, // STA $13
+		},
+		nes.Compare16: {
+			0xA5, 0x10, // LDA $10
+			0xCD, 0x00, 0x03, // CMP $0300
+			0xA5, 0x11, // LDA $11
+			0xED, 0x01, 0x03, // SBC $0301
+		},
+		nes.Increment16: {
+			0xE6, 0x10, // INC $10
+			0xD0, 0x02, // BNE +2
+			0xE6, 0x11, // INC $11
+		},
+		nes.Decrement16: {
+			0xA5, 0x10, // LDA $10
+			0xD0, 0x02, // BNE +2
+			0xC6, 0x11, // DEC $11
+			0xC6, 0x10, // DEC $10
+		},
+		nes.PointerSetup: {
+			0xA2, 0x34, // LDX #$34
+			0x86, 0x10, // STX $10
+			0xA0, 0x12, // LDY #$12
+			0x84, 0x11, // STY $11
+		},
+	}
+	random := rand.New(rand.NewSource(16))
+	for kind, code := range idioms {
+		prg := testrom.Prg(code...)
+		prg[len(code)] = 0x60 // RTS
+		d := nes.NewDisassembly(prg)
+		d.AddVectors()
+		d.Analyze()
+		idiom, ok := d.Idiom(0)
+		if !assert.True(t, ok, "idiom %d", kind) || !assert.Equal(t, kind, idiom.Kind) {
+			continue
+		}
+		end := uint16(0xC000 + len(code))
+
+		for i := 0; i < 256; i++ {
+			var steps, lifted flatMemory
+			random.Read(steps[:0x0400])
+			if i%4 == 0 {
+				// Carries and borrows across the bytes
+				steps[0x10], steps[0x0300] = 0xFF, 0xFF
+			}
+			copy(steps[0xC000:], prg)
+			lifted = steps
+			a, p := random.Intn(256), byte(random.Intn(256))&^flagB|flagU
+
+			machines := []*Machine{NewMachine(&steps), NewMachine(&lifted)}
+			for _, m := range machines {
+				m.Set(A, a)
+				m.Set(X, 0x55)
+				m.Set(Y, 0xAA)
+				m.SetStatus(p)
+			}
+
+			m := machines[0]
+			for m.PC = 0xC000; m.PC != end; {
+				assert.NoError(t, m.Step())
+			}
+			lifter := &Lifter{}
+			machines[1].Run(lifter.LiftIdiom(idiom), end)
+
+			for _, r := range []Register{A, X, Y} {
+				assert.Equal(t, m.Get(r), machines[1].Get(r), "%s after %s", r, idiom)
+			}
+			assert.Equal(t, m.Status(), machines[1].Status(), "P after %s", idiom)
+			if steps != lifted {
+				t.Errorf("memory differs after %s", idiom)
+			}
+			if t.Failed() {
+				t.Fatalf("%s from P=%02X", idiom, p)
+			}
+		}
+	}
+}
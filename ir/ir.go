@@ -0,0 +1,565 @@
+// Package ir defines an intermediate representation of 6502 code
+// where registers, status flags and memory accesses are explicit.
+package ir
+
+import "fmt"
+
+// Register is a CPU register, a status flag or a temporary value.
+// Flags hold 0 or 1.
+type Register int
+
+const (
+	A Register = iota
+	X
+	Y
+	S
+	C // Carry
+	Z // Zero
+	I // Interrupt disable
+	D // Decimal
+	V // Overflow
+	N // Negative
+	firstTemp
+)
+
+// Registers lists the CPU registers and flags.
+var Registers = []Register{A, X, Y, S, C, Z, I, D, V, N}
+
+// Temp returns the n-th temporary register.
+func Temp(n int) Register {
+	return firstTemp + Register(n)
+}
+
+// IsTemp returns true for temporary registers.
+func (r Register) IsTemp() bool {
+	return r >= firstTemp
+}
+
+// IsFlag returns true for status flags.
+func (r Register) IsFlag() bool {
+	return r >= C && r <= N
+}
+
+func (r Register) String() string {
+	if r.IsTemp() {
+		return fmt.Sprintf("t%d", r-firstTemp)
+	}
+	return [...]string{"A", "X", "Y", "S", "C", "Z", "I", "D", "V", "N"}[r]
+}
+
+// Op is a binary operator.
+type Op int
+
+const (
+	Add Op = iota
+	Sub
+	And
+	Or
+	Xor
+	Shl
+	Shr
+	Eq // Comparisons evaluate to 0 or 1
+	Ne
+	Lt
+	Le
+	Gt
+	Ge
+)
+
+func (op Op) String() string {
+	return [...]string{"+", "-", "&", "|", "^", "<<", ">>", "==", "!=", "<", "<=", ">", ">="}[op]
+}
+
+// IsComparison returns true for comparison operators.
+func (op Op) IsComparison() bool {
+	return op >= Eq
+}
+
+// Negate returns the opposite comparison, e.g. >= for <.
+func (op Op) Negate() Op {
+	return [...]Op{Eq: Ne, Ne: Eq, Lt: Ge, Le: Gt, Gt: Le, Ge: Lt}[op]
+}
+
+// Expr is an expression. Expressions are evaluated
+// on unbounded integers and never wrap implicitly.
+type Expr interface {
+	exprNode()
+}
+
+// Const is a constant value.
+type Const struct {
+	Value int
+}
+
+// Reg reads a register.
+type Reg struct {
+	Register Register
+}
+
+// Load reads a byte from memory.
+type Load struct {
+	Address Expr
+}
+
+// Word reads a little endian word from memory. If Wrap is true,
+// the high byte is read from the same page as the low byte, as
+// zero page pointers and JMP ($xxFF) do.
+type Word struct {
+	Address Expr
+	Wrap    bool
+}
+
+// Pull pops a byte from the stack.
+type Pull struct{}
+
+// Binary applies an operator to two values.
+type Binary struct {
+	Op    Op
+	Left  Expr
+	Right Expr
+}
+
+// Not is the logical negation: 1 if Value is 0, 0 otherwise.
+type Not struct {
+	Value Expr
+}
+
+func (Const) exprNode()  {}
+func (Reg) exprNode()    {}
+func (Load) exprNode()   {}
+func (Word) exprNode()   {}
+func (Pull) exprNode()   {}
+func (Binary) exprNode() {}
+func (Not) exprNode()    {}
+
+// Stmt is a statement.
+type Stmt interface {
+	stmtNode()
+}
+
+// Assign writes a register.
+type Assign struct {
+	Register Register
+	Value    Expr
+}
+
+// Store writes a byte to memory.
+type Store struct {
+	Address Expr
+	Value   Expr
+}
+
+// Store16 writes a little endian word to memory.
+type Store16 struct {
+	Address Expr
+	Value   Expr
+}
+
+// Eval evaluates an expression for its side effects,
+// e.g. reading a PPU register.
+type Eval struct {
+	Value Expr
+}
+
+// Push pushes a byte on the stack.
+type Push struct {
+	Value Expr
+}
+
+// Branch jumps to Target if Cond is not 0.
+type Branch struct {
+	Cond   Expr
+	Target uint16
+}
+
+// Jump jumps to Target.
+type Jump struct {
+	Target uint16
+}
+
+// JumpIndirect jumps to a computed address.
+type JumpIndirect struct {
+	Target Expr
+}
+
+// Call calls the subroutine at Target.
+type Call struct {
+	Target uint16
+}
+
+// Return returns from a subroutine (RTS) or an interrupt (RTI).
+type Return struct {
+	Interrupt bool
+}
+
+// Break triggers a software interrupt (BRK).
+type Break struct{}
+
+func (Assign) stmtNode()       {}
+func (Store) stmtNode()        {}
+func (Store16) stmtNode()      {}
+func (Eval) stmtNode()         {}
+func (Push) stmtNode()         {}
+func (Branch) stmtNode()       {}
+func (Jump) stmtNode()         {}
+func (JumpIndirect) stmtNode() {}
+func (Call) stmtNode()         {}
+func (Return) stmtNode()       {}
+func (Break) stmtNode()        {}
+
+// Bin returns a simplified binary expression.
+func Bin(op Op, left, right Expr) Expr {
+	return Simplify(Binary{op, left, right})
+}
+
+// Negate returns the simplified logical negation of an expression.
+func Negate(e Expr) Expr {
+	return Simplify(Not{e})
+}
+
+// Simplify folds constants and removes neutral operations.
+func Simplify(e Expr) Expr {
+	switch e := e.(type) {
+	case Binary:
+		left, right := Simplify(e.Left), Simplify(e.Right)
+		l, lConst := left.(Const)
+		r, rConst := right.(Const)
+		if lConst && rConst {
+			return Const{evalOp(e.Op, l.Value, r.Value)}
+		}
+		if rConst && r.Value == 0 {
+			switch e.Op {
+			case Add, Sub, Or, Xor, Shl, Shr:
+				return left
+			case And:
+				return Const{0}
+			}
+		}
+		if lConst && l.Value == 0 {
+			switch e.Op {
+			case Add, Or, Xor:
+				return right
+			case And, Shl, Shr:
+				return Const{0}
+			}
+		}
+		if inner, ok := left.(Binary); ok && rConst {
+			if c, ok := inner.Right.(Const); ok {
+				if isAdditive(e.Op) && isAdditive(inner.Op) {
+					// (x - 1) + 3 == x + 2
+					return addConst(inner.Left, signed(inner.Op, c.Value)+signed(e.Op, r.Value))
+				}
+				if inner.Op == e.Op && (e.Op == Or || e.Op == Xor || e.Op == And) {
+					return Simplify(Binary{e.Op, inner.Left, Const{evalOp(e.Op, c.Value, r.Value)}})
+				}
+			}
+		}
+		if inner, ok := left.(Binary); ok && inner.Op == Sub && e.Op.IsComparison() && rConst && r.Value == 0 {
+			// x - y < 0 == x < y
+			return Binary{e.Op, inner.Left, inner.Right}
+		}
+		if e.Op == And && rConst && r.Value >= 0xFF && MaxValue(left) >= 0 && MaxValue(left) <= r.Value {
+			// The mask has no effect
+			return left
+		}
+		return Binary{e.Op, left, right}
+	case Not:
+		value := Simplify(e.Value)
+		switch v := value.(type) {
+		case Const:
+			if v.Value == 0 {
+				return Const{1}
+			}
+			return Const{0}
+		case Not:
+			if IsBoolean(v.Value) {
+				return v.Value
+			}
+		case Binary:
+			if v.Op.IsComparison() {
+				return Binary{v.Op.Negate(), v.Left, v.Right}
+			}
+		}
+		return Not{value}
+	case Load:
+		return Load{Simplify(e.Address)}
+	case Word:
+		return Word{Simplify(e.Address), e.Wrap}
+	}
+	return e
+}
+
+func isAdditive(op Op) bool {
+	return op == Add || op == Sub
+}
+
+func signed(op Op, value int) int {
+	if op == Sub {
+		return -value
+	}
+	return value
+}
+
+// addConst returns x + n, or x - |n| if n is negative.
+func addConst(x Expr, n int) Expr {
+	switch {
+	case n > 0:
+		return Binary{Add, x, Const{n}}
+	case n < 0:
+		return Binary{Sub, x, Const{-n}}
+	}
+	return x
+}
+
+// IsBoolean returns true if an expression evaluates to 0 or 1.
+func IsBoolean(e Expr) bool {
+	switch e := e.(type) {
+	case Const:
+		return e.Value == 0 || e.Value == 1
+	case Reg:
+		return e.Register.IsFlag()
+	case Not:
+		return true
+	case Binary:
+		return e.Op.IsComparison()
+	}
+	return false
+}
+
+// MaxValue returns an upper bound of an expression,
+// or a negative value if there is none.
+func MaxValue(e Expr) int {
+	switch e := e.(type) {
+	case Const:
+		return e.Value
+	case Reg:
+		if e.Register.IsFlag() {
+			return 1
+		}
+		if e.Register.IsTemp() {
+			return -1
+		}
+		return 0xFF
+	case Load, Pull:
+		return 0xFF
+	case Word:
+		return 0xFFFF
+	case Not:
+		return 1
+	case Binary:
+		if e.Op.IsComparison() {
+			return 1
+		}
+		left, right := MaxValue(e.Left), MaxValue(e.Right)
+		switch e.Op {
+		case And:
+			if right >= 0 && (left < 0 || right < left) {
+				return right
+			}
+			return left
+		case Shr:
+			return left
+		case Add:
+			if left >= 0 && right >= 0 {
+				return left + right
+			}
+		case Or, Xor:
+			if left >= 0 && right >= 0 {
+				// Smallest 2^n-1 greater than both
+				max := 1
+				for max <= left || max <= right {
+					max <<= 1
+				}
+				return max - 1
+			}
+		case Shl:
+			if c, ok := e.Right.(Const); ok && left >= 0 {
+				return left << uint(c.Value)
+			}
+		}
+	}
+	return -1
+}
+
+func evalOp(op Op, l, r int) int {
+	boolean := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	switch op {
+	case Add:
+		return l + r
+	case Sub:
+		return l - r
+	case And:
+		return l & r
+	case Or:
+		return l | r
+	case Xor:
+		return l ^ r
+	case Shl:
+		return l << uint(r)
+	case Shr:
+		return l >> uint(r)
+	case Eq:
+		return boolean(l == r)
+	case Ne:
+		return boolean(l != r)
+	case Lt:
+		return boolean(l < r)
+	case Le:
+		return boolean(l <= r)
+	case Gt:
+		return boolean(l > r)
+	case Ge:
+		return boolean(l >= r)
+	}
+	return 0
+}
+
+// Uses returns the registers read by an expression,
+// once per occurrence.
+func Uses(e Expr) []Register {
+	switch e := e.(type) {
+	case Reg:
+		return []Register{e.Register}
+	case Load:
+		return Uses(e.Address)
+	case Word:
+		return Uses(e.Address)
+	case Binary:
+		return append(Uses(e.Left), Uses(e.Right)...)
+	case Not:
+		return Uses(e.Value)
+	}
+	return nil
+}
+
+// StmtUses returns the registers read by a statement,
+// once per occurrence.
+func StmtUses(s Stmt) []Register {
+	switch s := s.(type) {
+	case Assign:
+		return Uses(s.Value)
+	case Store:
+		return append(Uses(s.Address), Uses(s.Value)...)
+	case Store16:
+		return append(Uses(s.Address), Uses(s.Value)...)
+	case Eval:
+		return Uses(s.Value)
+	case Push:
+		return Uses(s.Value)
+	case Branch:
+		return Uses(s.Cond)
+	case JumpIndirect:
+		return Uses(s.Target)
+	}
+	return nil
+}
+
+// Substitute replaces the reads of a register by an expression.
+func Substitute(e Expr, r Register, by Expr) Expr {
+	switch e := e.(type) {
+	case Reg:
+		if e.Register == r {
+			return by
+		}
+	case Load:
+		return Load{Substitute(e.Address, r, by)}
+	case Word:
+		return Word{Substitute(e.Address, r, by), e.Wrap}
+	case Binary:
+		return Binary{e.Op, Substitute(e.Left, r, by), Substitute(e.Right, r, by)}
+	case Not:
+		return Not{Substitute(e.Value, r, by)}
+	}
+	return e
+}
+
+// SubstituteStmt replaces the reads of a register
+// by an expression in a statement.
+func SubstituteStmt(s Stmt, r Register, by Expr) Stmt {
+	sub := func(e Expr) Expr {
+		return Simplify(Substitute(e, r, by))
+	}
+	switch s := s.(type) {
+	case Assign:
+		return Assign{s.Register, sub(s.Value)}
+	case Store:
+		return Store{sub(s.Address), sub(s.Value)}
+	case Store16:
+		return Store16{sub(s.Address), sub(s.Value)}
+	case Eval:
+		return Eval{sub(s.Value)}
+	case Push:
+		return Push{sub(s.Value)}
+	case Branch:
+		return Branch{sub(s.Cond), s.Target}
+	case JumpIndirect:
+		return JumpIndirect{sub(s.Target)}
+	}
+	return s
+}
+
+// ReadsMemory returns true if an expression reads memory or the stack.
+func ReadsMemory(e Expr) bool {
+	switch e := e.(type) {
+	case Load, Word, Pull:
+		return true
+	case Binary:
+		return ReadsMemory(e.Left) || ReadsMemory(e.Right)
+	case Not:
+		return ReadsMemory(e.Value)
+	}
+	return false
+}
+
+// isIORegister returns true for the PPU, APU and I/O registers,
+// and for cartridge registers below PRG ROM.
+func isIORegister(address int) bool {
+	return address >= 0x2000 && address < 0x4020
+}
+
+// HasSideEffects returns true if evaluating an expression changes
+// the machine state: pulling from the stack or reading registers
+// such as PPUSTATUS ($2002) which are reset when read.
+func HasSideEffects(e Expr) bool {
+	switch e := e.(type) {
+	case Pull:
+		return true
+	case Load:
+		if c, ok := e.Address.(Const); ok {
+			return isIORegister(c.Value)
+		}
+		if b, ok := e.Address.(Binary); ok {
+			if c, ok := b.Left.(Const); ok && isIORegister(c.Value) {
+				return true
+			}
+		}
+		return HasSideEffects(e.Address)
+	case Word:
+		return HasSideEffects(e.Address)
+	case Binary:
+		return HasSideEffects(e.Left) || HasSideEffects(e.Right)
+	case Not:
+		return HasSideEffects(e.Value)
+	}
+	return false
+}
+
+// Size returns the number of nodes of an expression.
+func Size(e Expr) int {
+	switch e := e.(type) {
+	case Load:
+		return 1 + Size(e.Address)
+	case Word:
+		return 1 + Size(e.Address)
+	case Binary:
+		return 1 + Size(e.Left) + Size(e.Right)
+	case Not:
+		return 1 + Size(e.Value)
+	}
+	return 1
+}
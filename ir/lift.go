@@ -0,0 +1,247 @@
+package ir
+
+import "github.com/vpenando/nes-rom-decompiler/nes"
+
+// Lifter translates instructions into IR statements.
+// Temporaries are numbered from 0 for each lifter,
+// so a single lifter should be used per subroutine.
+type Lifter struct {
+	temps int
+}
+
+// temp returns a new temporary register.
+func (l *Lifter) temp() Register {
+	t := Temp(l.temps)
+	l.temps++
+	return t
+}
+
+// Lift returns the statements executing an instruction.
+// The 2A03 has no decimal mode, so ADC and SBC ignore the D flag.
+//  LDA #$10 -> A = 16; Z = A == 0; N = A >= 128
+func (l *Lifter) Lift(inst nes.Instruction) []Stmt {
+	var stmts []Stmt
+	emit := func(s ...Stmt) {
+		stmts = append(stmts, s...)
+	}
+	setNZ := func(value Expr) {
+		emit(Assign{Z, Bin(Eq, value, Const{0})}, Assign{N, Bin(Ge, value, Const{0x80})})
+	}
+	// operand returns the operand value, read once
+	operand := func() Expr {
+		if inst.Mode == nes.Immediate {
+			return Const{int(inst.Operand)}
+		}
+		t := l.temp()
+		emit(Assign{t, Load{Address(inst)}})
+		return Reg{t}
+	}
+	// modify applies a read-modify-write operation to A or memory:
+	// the result and the carry are computed from the old value
+	modify := func(result, carry func(value Expr) Expr) {
+		var value Expr = Reg{A}
+		address := Address(inst)
+		if inst.Mode != nes.Accumulator {
+			t := l.temp()
+			emit(Assign{t, Load{address}})
+			value = Reg{t}
+		}
+		t := l.temp()
+		emit(Assign{t, result(value)})
+		if carry != nil {
+			emit(Assign{C, carry(value)})
+		}
+		if inst.Mode == nes.Accumulator {
+			emit(Assign{A, Reg{t}})
+		} else {
+			emit(Store{address, Reg{t}})
+		}
+		setNZ(Reg{t})
+	}
+
+	switch inst.Mnemonic {
+	case "LDA", "LDX", "LDY":
+		r := loadRegisters[inst.Mnemonic]
+		emit(Assign{r, operandExpr(inst)})
+		setNZ(Reg{r})
+	case "STA", "STX", "STY":
+		emit(Store{Address(inst), Reg{loadRegisters["LD"+inst.Mnemonic[2:]]}})
+	case "TAX", "TAY", "TXA", "TYA", "TSX":
+		to, from := loadRegisters["LD"+inst.Mnemonic[2:]], transferSources[inst.Mnemonic]
+		emit(Assign{to, Reg{from}})
+		setNZ(Reg{to})
+	case "TXS":
+		emit(Assign{S, Reg{X}})
+	case "ADC":
+		m := operand()
+		t := l.temp()
+		emit(Assign{t, Bin(Add, Bin(Add, Reg{A}, m), Reg{C})})
+		emit(Assign{V, Bin(Ne, Bin(And, Bin(And, Bin(Xor, Reg{A}, Reg{t}), Bin(Xor, m, Reg{t})), Const{0x80}), Const{0})})
+		emit(Assign{C, Bin(Gt, Reg{t}, Const{0xFF})})
+		emit(Assign{A, Bin(And, Reg{t}, Const{0xFF})})
+		setNZ(Reg{A})
+	case "SBC":
+		m := operand()
+		t := l.temp()
+		emit(Assign{t, Bin(Add, Bin(Sub, Bin(Sub, Reg{A}, m), Const{1}), Reg{C})})
+		emit(Assign{V, Bin(Ne, Bin(And, Bin(And, Bin(Xor, Reg{A}, m), Bin(Xor, Reg{A}, Reg{t})), Const{0x80}), Const{0})})
+		emit(Assign{C, Bin(Ge, Reg{t}, Const{0})})
+		emit(Assign{A, Bin(And, Reg{t}, Const{0xFF})})
+		setNZ(Reg{A})
+	case "AND", "ORA", "EOR":
+		emit(Assign{A, Bin(logicalOps[inst.Mnemonic], Reg{A}, operandExpr(inst))})
+		setNZ(Reg{A})
+	case "CMP", "CPX", "CPY":
+		r := Reg{compareRegisters[inst.Mnemonic]}
+		m := operand()
+		emit(Assign{C, Bin(Ge, r, m)})
+		emit(Assign{Z, Bin(Eq, r, m)})
+		emit(Assign{N, Bin(Ge, Bin(And, Bin(Sub, r, m), Const{0xFF}), Const{0x80})})
+	case "BIT":
+		m := operand()
+		emit(Assign{Z, Bin(Eq, Bin(And, Reg{A}, m), Const{0})})
+		emit(Assign{N, Bin(Ge, m, Const{0x80})})
+		emit(Assign{V, Bin(Ne, Bin(And, m, Const{0x40}), Const{0})})
+	case "ASL":
+		modify(func(value Expr) Expr {
+			return Bin(And, Bin(Shl, value, Const{1}), Const{0xFF})
+		}, highBit)
+	case "LSR":
+		modify(func(value Expr) Expr {
+			return Bin(Shr, value, Const{1})
+		}, lowBit)
+	case "ROL":
+		modify(func(value Expr) Expr {
+			return Bin(And, Bin(Or, Bin(Shl, value, Const{1}), Reg{C}), Const{0xFF})
+		}, highBit)
+	case "ROR":
+		modify(func(value Expr) Expr {
+			return Bin(Or, Bin(Shr, value, Const{1}), Bin(Shl, Reg{C}, Const{7}))
+		}, lowBit)
+	case "INC", "DEC":
+		modify(func(value Expr) Expr {
+			return Bin(And, Bin(incrementOps[inst.Mnemonic[:2]], value, Const{1}), Const{0xFF})
+		}, nil)
+	case "INX", "INY", "DEX", "DEY":
+		r := incrementRegisters[inst.Mnemonic[2]]
+		emit(Assign{r, Bin(And, Bin(incrementOps[inst.Mnemonic[:2]], Reg{r}, Const{1}), Const{0xFF})})
+		setNZ(Reg{r})
+	case "BPL", "BMI", "BVC", "BVS", "BCC", "BCS", "BNE", "BEQ":
+		target, _ := inst.Target()
+		emit(Branch{BranchCondition(inst.Mnemonic), target})
+	case "JMP":
+		if inst.Mode == nes.Indirect {
+			emit(JumpIndirect{Word{Const{int(inst.Operand)}, true}})
+		} else {
+			emit(Jump{inst.Operand})
+		}
+	case "JSR":
+		emit(Call{inst.Operand})
+	case "RTS":
+		emit(Return{})
+	case "RTI":
+		emit(Return{Interrupt: true})
+	case "BRK":
+		emit(Break{})
+	case "PHA":
+		emit(Push{Reg{A}})
+	case "PHP":
+		emit(Push{StatusByte()})
+	case "PLA":
+		emit(Assign{A, Pull{}})
+		setNZ(Reg{A})
+	case "PLP":
+		t := l.temp()
+		emit(Assign{t, Pull{}})
+		for i, flag := range statusFlags {
+			if flag >= 0 {
+				emit(Assign{flag, Bin(Ne, Bin(And, Reg{t}, Const{1 << uint(i)}), Const{0})})
+			}
+		}
+	case "CLC", "CLD", "CLI", "CLV":
+		emit(Assign{flagRegisters[inst.Mnemonic[2]], Const{0}})
+	case "SEC", "SED", "SEI":
+		emit(Assign{flagRegisters[inst.Mnemonic[2]], Const{1}})
+	}
+	return stmts
+}
+
+func highBit(value Expr) Expr {
+	return Bin(Ge, value, Const{0x80})
+}
+
+func lowBit(value Expr) Expr {
+	return Bin(And, value, Const{1})
+}
+
+// statusFlags lists the flags by bit of the status register.
+// Bits 4 and 5 (B and unused) are not flags: -1.
+var statusFlags = [8]Register{C, Z, I, D, -1, -1, V, N}
+
+// StatusByte returns the status register as pushed by PHP,
+// with the B and unused bits set.
+func StatusByte() Expr {
+	var status Expr = Const{0x30}
+	for i, flag := range statusFlags {
+		if flag >= 0 {
+			status = Bin(Or, status, Bin(Shl, Reg{flag}, Const{i}))
+		}
+	}
+	return status
+}
+
+// BranchCondition returns the condition of a branch mnemonic.
+//  BranchCondition("BNE") == Not{Reg{Z}}
+func BranchCondition(mnemonic string) Expr {
+	flag := branchFlags[mnemonic[1]]
+	if mnemonic == "BMI" || mnemonic == "BVS" || mnemonic == "BCS" || mnemonic == "BEQ" {
+		return Reg{flag}
+	}
+	return Not{Reg{flag}}
+}
+
+// Address returns the effective address of a memory operand.
+func Address(inst nes.Instruction) Expr {
+	operand := Const{int(inst.Operand)}
+	switch inst.Mode {
+	case nes.ZeroPageX:
+		return Bin(And, Bin(Add, operand, Reg{X}), Const{0xFF})
+	case nes.ZeroPageY:
+		return Bin(And, Bin(Add, operand, Reg{Y}), Const{0xFF})
+	case nes.AbsoluteX:
+		return Bin(And, Bin(Add, operand, Reg{X}), Const{0xFFFF})
+	case nes.AbsoluteY:
+		return Bin(And, Bin(Add, operand, Reg{Y}), Const{0xFFFF})
+	case nes.IndirectX:
+		return Word{Bin(And, Bin(Add, operand, Reg{X}), Const{0xFF}), true}
+	case nes.IndirectY:
+		return Bin(And, Bin(Add, Word{operand, true}, Reg{Y}), Const{0xFFFF})
+	case nes.Indirect:
+		return Word{operand, true}
+	}
+	return operand
+}
+
+// operandExpr returns the value of the operand.
+func operandExpr(inst nes.Instruction) Expr {
+	if inst.Mode == nes.Immediate {
+		return Const{int(inst.Operand)}
+	}
+	return Load{Address(inst)}
+}
+
+var loadRegisters = map[string]Register{"LDA": A, "LDX": X, "LDY": Y}
+
+var transferSources = map[string]Register{"TAX": A, "TAY": A, "TXA": X, "TYA": Y, "TSX": S}
+
+var compareRegisters = map[string]Register{"CMP": A, "CPX": X, "CPY": Y}
+
+var logicalOps = map[string]Op{"AND": And, "ORA": Or, "EOR": Xor}
+
+var incrementOps = map[string]Op{"IN": Add, "DE": Sub}
+
+var incrementRegisters = map[byte]Register{'X': X, 'Y': Y}
+
+var flagRegisters = map[byte]Register{'C': C, 'D': D, 'I': I, 'V': V}
+
+var branchFlags = map[byte]Register{'P': N, 'M': N, 'V': V, 'C': C, 'N': Z, 'E': Z}
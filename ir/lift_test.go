@@ -0,0 +1,77 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func decode(code ...byte) nes.Instruction {
+	inst, _ := nes.DecodeInstruction(append(code, 0, 0), 0, 0xC000)
+	return inst
+}
+
+func TestLiftLoad(t *testing.T) {
+	lifter := &Lifter{}
+	stmts := lifter.Lift(decode(0xBD, 0x00, 0x03)) // LDA $0300,X
+	assert.Equal(t, []Stmt{
+		Assign{A, Load{Binary{Add, Const{0x0300}, Reg{X}}}},
+		Assign{Z, Binary{Eq, Reg{A}, Const{0}}},
+		Assign{N, Binary{Ge, Reg{A}, Const{0x80}}},
+	}, stmts)
+}
+
+func TestLiftIndirect(t *testing.T) {
+	lifter := &Lifter{}
+	stmts := lifter.Lift(decode(0x91, 0x10)) // STA ($10),Y
+	assert.Equal(t, []Stmt{
+		Store{Binary{And, Binary{Add, Word{Const{0x10}, true}, Reg{Y}}, Const{0xFFFF}}, Reg{A}},
+	}, stmts)
+	stmts = lifter.Lift(decode(0x6C, 0xFF, 0x02)) // JMP ($02FF)
+	assert.Equal(t, []Stmt{JumpIndirect{Word{Const{0x02FF}, true}}}, stmts)
+}
+
+func TestLiftBranch(t *testing.T) {
+	lifter := &Lifter{}
+	stmts := lifter.Lift(decode(0xD0, 0xFE)) // BNE $C000
+	assert.Equal(t, []Stmt{Branch{Not{Reg{Z}}, 0xC000}}, stmts)
+	stmts = lifter.Lift(decode(0xB0, 0x02)) // BCS $C004
+	assert.Equal(t, []Stmt{Branch{Reg{C}, 0xC004}}, stmts)
+}
+
+func TestLiftTemporaries(t *testing.T) {
+	lifter := &Lifter{}
+	stmts := lifter.Lift(decode(0x26, 0x10)) // ROL $10
+	assert.Equal(t, []Stmt{
+		Assign{Temp(0), Load{Const{0x10}}},
+		Assign{Temp(1), Binary{And, Binary{Or, Binary{Shl, Reg{Temp(0)}, Const{1}}, Reg{C}}, Const{0xFF}}},
+		Assign{C, Binary{Ge, Reg{Temp(0)}, Const{0x80}}},
+		Store{Const{0x10}, Reg{Temp(1)}},
+		Assign{Z, Binary{Eq, Reg{Temp(1)}, Const{0}}},
+		Assign{N, Binary{Ge, Reg{Temp(1)}, Const{0x80}}},
+	}, stmts)
+	stmts = lifter.Lift(decode(0xCA)) // DEX
+	assert.Equal(t, Assign{X, Binary{And, Binary{Sub, Reg{X}, Const{1}}, Const{0xFF}}}, stmts[0])
+	assert.Equal(t, "t2", lifter.temp().String())
+}
+
+func TestSimplify(t *testing.T) {
+	assert.Equal(t, Const{0x0F}, Simplify(Binary{And, Const{0xFF}, Const{0x0F}}))
+	assert.Equal(t, Reg{A}, Simplify(Binary{Add, Reg{A}, Const{0}}))
+	assert.Equal(t, Reg{A}, Simplify(Binary{And, Reg{A}, Const{0xFF}}))
+	assert.Equal(t, Binary{Add, Reg{A}, Const{2}}, Simplify(Binary{Add, Binary{Sub, Reg{A}, Const{1}}, Const{3}}))
+	assert.Equal(t, Reg{A}, Simplify(Binary{Add, Binary{Sub, Reg{A}, Const{1}}, Const{1}}))
+	assert.Equal(t, Binary{Ge, Reg{A}, Const{5}}, Simplify(Not{Binary{Lt, Reg{A}, Const{5}}}))
+	assert.Equal(t, Reg{C}, Simplify(Not{Not{Reg{C}}}))
+	assert.Equal(t,
+		Binary{And, Binary{Add, Const{0x10}, Reg{X}}, Const{0xFF}},
+		Simplify(Binary{And, Binary{Add, Const{0x10}, Reg{X}}, Const{0xFF}}))
+}
+
+func TestHasSideEffects(t *testing.T) {
+	assert.True(t, HasSideEffects(Load{Const{0x2002}}))
+	assert.True(t, HasSideEffects(Binary{Add, Pull{}, Const{1}}))
+	assert.False(t, HasSideEffects(Load{Const{0x0300}}))
+	assert.True(t, ReadsMemory(Load{Const{0x0300}}))
+}
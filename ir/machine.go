@@ -0,0 +1,179 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// Memory is the CPU address space.
+type Memory interface {
+	Read(address uint16) byte
+	Write(address uint16, value byte)
+}
+
+// Machine executes IR statements, giving them their 6502 semantics.
+// It is used to check the lifter against a CPU core.
+type Machine struct {
+	Memory    Memory
+	PC        uint16
+	registers [firstTemp]int
+	temps     map[Register]int
+}
+
+// NewMachine returns a machine in the power-up state of the CPU.
+func NewMachine(memory Memory) *Machine {
+	m := &Machine{Memory: memory}
+	m.Set(S, 0xFD)
+	m.Set(I, 1)
+	return m
+}
+
+// Get returns the value of a register or flag.
+func (m *Machine) Get(r Register) int {
+	if r.IsTemp() {
+		return m.temps[r]
+	}
+	return m.registers[r]
+}
+
+// Set sets the value of a register or flag.
+func (m *Machine) Set(r Register, value int) {
+	if r.IsTemp() {
+		m.temps[r] = value
+		return
+	}
+	m.registers[r] = value
+}
+
+// Status returns the status register, with the unused bit set.
+func (m *Machine) Status() byte {
+	return byte(m.Eval(StatusByte())) &^ 0x10
+}
+
+// SetStatus sets the flags from a status register value.
+func (m *Machine) SetStatus(status byte) {
+	for i, flag := range statusFlags {
+		if flag >= 0 {
+			m.Set(flag, int(status>>uint(i))&1)
+		}
+	}
+}
+
+func (m *Machine) push(value int) {
+	s := m.Get(S)
+	m.Memory.Write(0x0100|uint16(s), byte(value))
+	m.Set(S, (s-1)&0xFF)
+}
+
+func (m *Machine) pull() int {
+	s := (m.Get(S) + 1) & 0xFF
+	m.Set(S, s)
+	return int(m.Memory.Read(0x0100 | uint16(s)))
+}
+
+func (m *Machine) readWord(address int, wrap bool) int {
+	hi := address + 1
+	if wrap {
+		hi = address&0xFF00 | hi&0xFF
+	}
+	return int(m.Memory.Read(uint16(address))) | int(m.Memory.Read(uint16(hi)))<<8
+}
+
+// Eval returns the value of an expression.
+func (m *Machine) Eval(e Expr) int {
+	switch e := e.(type) {
+	case Const:
+		return e.Value
+	case Reg:
+		return m.Get(e.Register)
+	case Load:
+		return int(m.Memory.Read(uint16(m.Eval(e.Address))))
+	case Word:
+		return m.readWord(m.Eval(e.Address), e.Wrap)
+	case Pull:
+		return m.pull()
+	case Not:
+		if m.Eval(e.Value) == 0 {
+			return 1
+		}
+		return 0
+	case Binary:
+		return evalOp(e.Op, m.Eval(e.Left), m.Eval(e.Right))
+	}
+	panic(fmt.Sprintf("unknown expression %T", e))
+}
+
+// Run executes the statements of an instruction. `next` is the
+// address of the following instruction, where PC ends up unless
+// the control is transferred:
+//  - Call pushes the address of the last byte of the JSR, high byte first
+//  - Return pulls this address and returns after it; RTI pulls the status first
+//  - Break pushes the address following its padding byte and the status
+//    with B set, then jumps through the IRQ vector
+func (m *Machine) Run(stmts []Stmt, next uint16) {
+	m.temps = map[Register]int{}
+	m.PC = next
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case Assign:
+			m.Set(s.Register, m.Eval(s.Value))
+		case Store:
+			address := m.Eval(s.Address)
+			m.Memory.Write(uint16(address), byte(m.Eval(s.Value)))
+		case Store16:
+			address, value := m.Eval(s.Address), m.Eval(s.Value)
+			m.Memory.Write(uint16(address), byte(value))
+			m.Memory.Write(uint16(address+1), byte(value>>8))
+		case Eval:
+			m.Eval(s.Value)
+		case Push:
+			m.push(m.Eval(s.Value))
+		case Branch:
+			if m.Eval(s.Cond) != 0 {
+				m.PC = s.Target
+			}
+		case Jump:
+			m.PC = s.Target
+		case JumpIndirect:
+			m.PC = uint16(m.Eval(s.Target))
+		case Call:
+			m.push(int(next-1) >> 8)
+			m.push(int(next - 1))
+			m.PC = s.Target
+		case Return:
+			if s.Interrupt {
+				m.SetStatus(byte(m.pull()))
+			}
+			lo := m.pull()
+			address := uint16(lo | m.pull()<<8)
+			if !s.Interrupt {
+				address++
+			}
+			m.PC = address
+		case Break:
+			m.push(int(next+1) >> 8)
+			m.push(int(next + 1))
+			m.push(m.Eval(StatusByte()))
+			m.Set(I, 1)
+			m.PC = uint16(m.readWord(0xFFFE, false))
+		}
+	}
+}
+
+// Execute executes an instruction.
+func (m *Machine) Execute(inst nes.Instruction) {
+	lifter := &Lifter{}
+	m.Run(lifter.Lift(inst), inst.Address+uint16(inst.Size()))
+}
+
+// Step decodes and executes the instruction at PC.
+func (m *Machine) Step() error {
+	code := []byte{m.Memory.Read(m.PC), m.Memory.Read(m.PC + 1), m.Memory.Read(m.PC + 2), 0}
+	inst, ok := nes.DecodeInstruction(code, 0, m.PC)
+	if !ok {
+		return fmt.Errorf("unknown opcode $%02X at %s", code[0], nes.WordToAddress(m.PC))
+	}
+	m.Execute(inst)
+	return nil
+}
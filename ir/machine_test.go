@@ -0,0 +1,382 @@
+package ir
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+type flatMemory [0x10000]byte
+
+func (mem *flatMemory) Read(address uint16) byte {
+	return mem[address]
+}
+
+func (mem *flatMemory) Write(address uint16, value byte) {
+	mem[address] = value
+}
+
+// Status register bits.
+const (
+	flagC byte = 1 << iota
+	flagZ
+	flagI
+	flagD
+	flagB
+	flagU
+	flagV
+	flagN
+)
+
+// referenceCPU is a straightforward 6502 core, written independently
+// from the lifter, to check its semantics.
+type referenceCPU struct {
+	A, X, Y, S, P byte
+	PC            uint16
+	mem           *flatMemory
+}
+
+func (cpu *referenceCPU) flag(f byte) bool {
+	return cpu.P&f != 0
+}
+
+func (cpu *referenceCPU) setFlag(f byte, on bool) {
+	if on {
+		cpu.P |= f
+	} else {
+		cpu.P &^= f
+	}
+}
+
+func (cpu *referenceCPU) setZN(value byte) byte {
+	cpu.setFlag(flagZ, value == 0)
+	cpu.setFlag(flagN, value&0x80 != 0)
+	return value
+}
+
+func (cpu *referenceCPU) push(value byte) {
+	cpu.mem[0x0100|uint16(cpu.S)] = value
+	cpu.S--
+}
+
+func (cpu *referenceCPU) pull() byte {
+	cpu.S++
+	return cpu.mem[0x0100|uint16(cpu.S)]
+}
+
+func (cpu *referenceCPU) word(address uint16, wrap bool) uint16 {
+	hi := address + 1
+	if wrap {
+		hi = address&0xFF00 | hi&0x00FF
+	}
+	return uint16(cpu.mem[address]) | uint16(cpu.mem[hi])<<8
+}
+
+func (cpu *referenceCPU) address(inst nes.Instruction) uint16 {
+	op := inst.Operand
+	switch inst.Mode {
+	case nes.ZeroPageX:
+		return uint16(byte(op) + cpu.X)
+	case nes.ZeroPageY:
+		return uint16(byte(op) + cpu.Y)
+	case nes.AbsoluteX:
+		return op + uint16(cpu.X)
+	case nes.AbsoluteY:
+		return op + uint16(cpu.Y)
+	case nes.IndirectX:
+		return cpu.word(uint16(byte(op)+cpu.X), true)
+	case nes.IndirectY:
+		return cpu.word(op, true) + uint16(cpu.Y)
+	case nes.Indirect:
+		return cpu.word(op, true)
+	}
+	return op
+}
+
+func (cpu *referenceCPU) compare(r, m byte) {
+	cpu.setFlag(flagC, r >= m)
+	cpu.setZN(r - m)
+}
+
+func (cpu *referenceCPU) adc(m byte) {
+	sum := uint16(cpu.A) + uint16(m)
+	if cpu.flag(flagC) {
+		sum++
+	}
+	cpu.setFlag(flagV, ^(cpu.A^m)&(cpu.A^byte(sum))&0x80 != 0)
+	cpu.setFlag(flagC, sum > 0xFF)
+	cpu.A = cpu.setZN(byte(sum))
+}
+
+func (cpu *referenceCPU) branch(inst nes.Instruction, taken bool) {
+	if taken {
+		cpu.PC, _ = inst.Target()
+	}
+}
+
+func (cpu *referenceCPU) step() {
+	code := []byte{cpu.mem[cpu.PC], cpu.mem[cpu.PC+1], cpu.mem[cpu.PC+2], 0}
+	inst, _ := nes.DecodeInstruction(code, 0, cpu.PC)
+	cpu.PC += uint16(inst.Size())
+	address := cpu.address(inst)
+	value := func() byte {
+		switch inst.Mode {
+		case nes.Immediate:
+			return byte(inst.Operand)
+		case nes.Accumulator:
+			return cpu.A
+		}
+		return cpu.mem[address]
+	}
+	write := func(v byte) {
+		if inst.Mode == nes.Accumulator {
+			cpu.A = v
+		} else {
+			cpu.mem[address] = v
+		}
+		cpu.setZN(v)
+	}
+
+	switch inst.Mnemonic {
+	case "LDA":
+		cpu.A = cpu.setZN(value())
+	case "LDX":
+		cpu.X = cpu.setZN(value())
+	case "LDY":
+		cpu.Y = cpu.setZN(value())
+	case "STA":
+		cpu.mem[address] = cpu.A
+	case "STX":
+		cpu.mem[address] = cpu.X
+	case "STY":
+		cpu.mem[address] = cpu.Y
+	case "TAX":
+		cpu.X = cpu.setZN(cpu.A)
+	case "TAY":
+		cpu.Y = cpu.setZN(cpu.A)
+	case "TXA":
+		cpu.A = cpu.setZN(cpu.X)
+	case "TYA":
+		cpu.A = cpu.setZN(cpu.Y)
+	case "TSX":
+		cpu.X = cpu.setZN(cpu.S)
+	case "TXS":
+		cpu.S = cpu.X
+	case "ADC":
+		cpu.adc(value())
+	case "SBC":
+		cpu.adc(^value())
+	case "AND":
+		cpu.A = cpu.setZN(cpu.A & value())
+	case "ORA":
+		cpu.A = cpu.setZN(cpu.A | value())
+	case "EOR":
+		cpu.A = cpu.setZN(cpu.A ^ value())
+	case "CMP":
+		cpu.compare(cpu.A, value())
+	case "CPX":
+		cpu.compare(cpu.X, value())
+	case "CPY":
+		cpu.compare(cpu.Y, value())
+	case "BIT":
+		m := value()
+		cpu.setFlag(flagZ, cpu.A&m == 0)
+		cpu.setFlag(flagN, m&0x80 != 0)
+		cpu.setFlag(flagV, m&0x40 != 0)
+	case "ASL":
+		m := value()
+		cpu.setFlag(flagC, m&0x80 != 0)
+		write(m << 1)
+	case "LSR":
+		m := value()
+		cpu.setFlag(flagC, m&1 != 0)
+		write(m >> 1)
+	case "ROL":
+		m, carry := value(), cpu.P&flagC
+		cpu.setFlag(flagC, m&0x80 != 0)
+		write(m<<1 | carry)
+	case "ROR":
+		m, carry := value(), cpu.P&flagC
+		cpu.setFlag(flagC, m&1 != 0)
+		write(m>>1 | carry<<7)
+	case "INC":
+		write(value() + 1)
+	case "DEC":
+		write(value() - 1)
+	case "INX":
+		cpu.X = cpu.setZN(cpu.X + 1)
+	case "INY":
+		cpu.Y = cpu.setZN(cpu.Y + 1)
+	case "DEX":
+		cpu.X = cpu.setZN(cpu.X - 1)
+	case "DEY":
+		cpu.Y = cpu.setZN(cpu.Y - 1)
+	case "BPL":
+		cpu.branch(inst, !cpu.flag(flagN))
+	case "BMI":
+		cpu.branch(inst, cpu.flag(flagN))
+	case "BVC":
+		cpu.branch(inst, !cpu.flag(flagV))
+	case "BVS":
+		cpu.branch(inst, cpu.flag(flagV))
+	case "BCC":
+		cpu.branch(inst, !cpu.flag(flagC))
+	case "BCS":
+		cpu.branch(inst, cpu.flag(flagC))
+	case "BNE":
+		cpu.branch(inst, !cpu.flag(flagZ))
+	case "BEQ":
+		cpu.branch(inst, cpu.flag(flagZ))
+	case "JMP":
+		cpu.PC = address
+	case "JSR":
+		cpu.push(byte((cpu.PC - 1) >> 8))
+		cpu.push(byte(cpu.PC - 1))
+		cpu.PC = address
+	case "RTS":
+		lo := cpu.pull()
+		cpu.PC = (uint16(lo) | uint16(cpu.pull())<<8) + 1
+	case "RTI":
+		cpu.P = cpu.pull()&^flagB | flagU
+		lo := cpu.pull()
+		cpu.PC = uint16(lo) | uint16(cpu.pull())<<8
+	case "BRK":
+		cpu.push(byte((cpu.PC + 1) >> 8))
+		cpu.push(byte(cpu.PC + 1))
+		cpu.push(cpu.P | flagB | flagU)
+		cpu.P |= flagI
+		cpu.PC = cpu.word(0xFFFE, false)
+	case "PHA":
+		cpu.push(cpu.A)
+	case "PHP":
+		cpu.push(cpu.P | flagB | flagU)
+	case "PLA":
+		cpu.A = cpu.setZN(cpu.pull())
+	case "PLP":
+		cpu.P = cpu.pull()&^flagB | flagU
+	case "CLC":
+		cpu.P &^= flagC
+	case "CLD":
+		cpu.P &^= flagD
+	case "CLI":
+		cpu.P &^= flagI
+	case "CLV":
+		cpu.P &^= flagV
+	case "SEC":
+		cpu.P |= flagC
+	case "SED":
+		cpu.P |= flagD
+	case "SEI":
+		cpu.P |= flagI
+	}
+}
+
+func TestLiftEveryOpcode(t *testing.T) {
+	count := 0
+	for code := 0; code < 0x100; code++ {
+		op, ok := nes.LookupOpcode(byte(code))
+		if !ok {
+			continue
+		}
+		count++
+		lifter := &Lifter{}
+		stmts := lifter.Lift(decode(byte(code), 0x10, 0x20))
+		if op.Mnemonic != "NOP" {
+			assert.NotEmpty(t, stmts, op.Mnemonic)
+		}
+	}
+	assert.Equal(t, 151, count)
+}
+
+// TestMachineAgainstReference executes every official opcode from random
+// states with both the IR machine and the reference core.
+func TestMachineAgainstReference(t *testing.T) {
+	random := rand.New(rand.NewSource(6502))
+	var initial flatMemory
+	random.Read(initial[:])
+	for code := 0; code < 0x100; code++ {
+		op, ok := nes.LookupOpcode(byte(code))
+		if !ok {
+			continue
+		}
+		for i := 0; i < 64; i++ {
+			pc := uint16(0x0200 + random.Intn(0xFD00))
+			operand := uint16(random.Intn(0x10000))
+			if i%8 == 0 {
+				// Page boundaries: JMP ($xxFF), ($FF),Y and ($FF,X)
+				operand |= 0x00FF
+			}
+			refMemory, irMemory := initial, initial
+			for _, mem := range []*flatMemory{&refMemory, &irMemory} {
+				mem[pc], mem[pc+1], mem[pc+2] = byte(code), byte(operand), byte(operand>>8)
+			}
+			a, x, y, s := byte(random.Intn(256)), byte(random.Intn(256)), byte(random.Intn(256)), byte(random.Intn(256))
+			p := byte(random.Intn(256))&^flagB | flagU
+
+			cpu := &referenceCPU{A: a, X: x, Y: y, S: s, P: p, PC: pc, mem: &refMemory}
+			cpu.step()
+
+			m := NewMachine(&irMemory)
+			m.Set(A, int(a))
+			m.Set(X, int(x))
+			m.Set(Y, int(y))
+			m.Set(S, int(s))
+			m.SetStatus(p)
+			m.PC = pc
+			assert.NoError(t, m.Step())
+
+			name := op.Mnemonic
+			assert.Equal(t, int(cpu.A), m.Get(A), "A after %s", name)
+			assert.Equal(t, int(cpu.X), m.Get(X), "X after %s", name)
+			assert.Equal(t, int(cpu.Y), m.Get(Y), "Y after %s", name)
+			assert.Equal(t, int(cpu.S), m.Get(S), "S after %s", name)
+			assert.Equal(t, cpu.P, m.Status(), "P after %s", name)
+			assert.Equal(t, cpu.PC, m.PC, "PC after %s", name)
+			if refMemory != irMemory {
+				t.Errorf("memory differs after %s", name)
+			}
+			if t.Failed() {
+				t.Fatalf("%s from A=%02X X=%02X Y=%02X S=%02X P=%02X", decode(byte(code), byte(operand), byte(operand>>8)), a, x, y, s, p)
+			}
+		}
+	}
+}
+
+func TestJumpIndirectPageWrap(t *testing.T) {
+	var mem flatMemory
+	copy(mem[0x0600:], []byte{0x6C, 0xFF, 0x02}) // JMP ($02FF)
+	mem[0x02FF], mem[0x0200], mem[0x0300] = 0x34, 0x12, 0x56
+	m := NewMachine(&mem)
+	m.PC = 0x0600
+	assert.NoError(t, m.Step())
+	assert.Equal(t, uint16(0x1234), m.PC)
+}
+
+func TestMachineStep(t *testing.T) {
+	var mem flatMemory
+	copy(mem[0x0600:], []byte{
+		0xA2, 0x05, // LDX #$05
+		0xA9, 0x00, // LDA #$00
+		0x18,       // CLC
+		0x69, 0x03, // ADC #$03
+		0xCA,       // DEX
+		0xD0, 0xFA, // BNE $0604
+		0x85, 0x10, // STA $10
+		0x20, 0x00, 0x07, // JSR $0700
+		0xEA, // NOP
+	})
+	mem[0x0700] = 0x60 // RTS
+	m := NewMachine(&mem)
+	m.PC = 0x0600
+	for m.PC != 0x060F {
+		assert.NoError(t, m.Step())
+	}
+	assert.Equal(t, byte(15), mem[0x10])
+	assert.Equal(t, 0xFD, m.Get(S))
+	assert.Equal(t, 1, m.Get(Z))
+
+	mem[0x060F] = 0x02
+	assert.EqualError(t, m.Step(), "unknown opcode $02 at $060F")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archiveExtensions are the extensions of the files
+// which can be selected in a zip archive.
+var archiveExtensions = map[string]bool{
+	".nes": true, ".unf": true, ".unif": true, ".fds": true, ".nsf": true, ".nsfe": true,
+}
+
+// addEntryFlag adds the -entry flag to a command reading a ROM,
+// as it is only parsed by the global flags in -i mode.
+func addEntryFlag(flags *flag.FlagSet) {
+	flags.StringVar(entryName, "entry", "", flag.Lookup("entry").Usage)
+}
+
+// loadFile returns the content of a file, uncompressed if it is
+// a gzip file. For a zip archive, it returns its only ROM, or the
+// file named `entry` if not empty.
+func loadFile(filePath, entry string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(data, []byte("\x1F\x8B")):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %s", filePath, err)
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %s", filePath, err)
+		}
+		file, err := selectZipEntry(archive, entry)
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %s", filePath, err)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	return data, nil
+}
+
+// selectZipEntry returns the file of a zip archive named `entry`,
+// or its only ROM if `entry` is empty. `entry` is either the full
+// name of the file, or its base name if no other file has it.
+func selectZipEntry(archive *zip.Reader, entry string) (*zip.File, error) {
+	var candidates []*zip.File
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if entry != "" && file.Name == entry {
+			return file, nil
+		}
+		if entry != "" && path.Base(file.Name) == entry ||
+			entry == "" && archiveExtensions[strings.ToLower(filepath.Ext(file.Name))] {
+			candidates = append(candidates, file)
+		}
+	}
+	switch {
+	case len(candidates) == 0 && entry != "":
+		return nil, fmt.Errorf("no entry '%s'", entry)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("no ROM in the archive")
+	case len(candidates) > 1:
+		names := make([]string, len(candidates))
+		for i, file := range candidates {
+			names[i] = file.Name
+		}
+		sort.Strings(names)
+		if entry != "" {
+			return nil, fmt.Errorf("several entries named '%s' (%s), give the full name with -entry", entry, strings.Join(names, ", "))
+		}
+		return nil, fmt.Errorf("several ROMs in the archive (%s), choose one with -entry", strings.Join(names, ", "))
+	}
+	return candidates[0], nil
+}
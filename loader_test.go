@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "loader")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte("game"))
+	writer.Close()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "game.nes.gz"), compressed.Bytes(), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "game.nes"), []byte("game"), 0644))
+
+	archives := map[string]map[string][]byte{
+		"one.zip":       {"readme.txt": []byte("readme"), "game.nes": []byte("game")},
+		"several.zip":   {"game.nes": []byte("game"), "game.fds": []byte("disk")},
+		"dirs.zip":      {"beta/game.nes": []byte("beta"), "game.nes": []byte("game"), "other.nes": []byte("other")},
+		"ambiguous.zip": {"eu/game.nes": []byte("eu"), "us/game.nes": []byte("us")},
+		"empty.zip":     {"readme.txt": []byte("readme")},
+	}
+	for name, files := range archives {
+		writeTestZip(t, filepath.Join(dir, name), files)
+	}
+
+	var tests = []struct {
+		file     string
+		entry    string
+		expected string // Content, or error without the file name
+		err      bool
+	}{
+		{"game.nes", "", "game", false},
+		{"game.nes.gz", "", "game", false},
+		{"one.zip", "", "game", false},
+		{"several.zip", "", "several ROMs in the archive (game.fds, game.nes), choose one with -entry", true},
+		{"several.zip", "game.fds", "disk", false},
+		{"dirs.zip", "game.nes", "game", false},
+		{"dirs.zip", "beta/game.nes", "beta", false},
+		{"dirs.zip", "other.nes", "other", false},
+		{"ambiguous.zip", "us/game.nes", "us", false},
+		{"ambiguous.zip", "game.nes", "several entries named 'game.nes' (eu/game.nes, us/game.nes), give the full name with -entry", true},
+		{"one.zip", "other.nes", "no entry 'other.nes'", true},
+		{"empty.zip", "", "no ROM in the archive", true},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, test.file)
+		data, err := loadFile(path, test.entry)
+		if test.err {
+			assert.EqualError(t, err, "'"+path+"': "+test.expected, test.file+" "+test.entry)
+		} else if assert.NoError(t, err, test.file+" "+test.entry) {
+			assert.Equal(t, test.expected, string(data), test.file+" "+test.entry)
+		}
+	}
+}
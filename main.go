@@ -3,25 +3,46 @@ package main
 import (
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/vpenando/nes-rom-decompiler/cfg"
 	"github.com/vpenando/nes-rom-decompiler/nes"
 )
 
 var (
-	inputFile  *string
-	outputFile *string
+	inputFile   *string
+	outputFile  *string
+	traceFile   *string
+	varsFile    *string
+	projectFile *string
+	tblFile     *string
+	showCycles  *bool
+	dbFile      *string
+	entryName   *string
 )
 
+// command is a subcommand, e.g. `./decompiler graph ...`.
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+// commands are registered by the init function of their file.
+var commands = map[string]command{}
+
 func init() {
-	inputFile = flag.String("i", "", "Input file (*.nes)")
+	inputFile = flag.String("i", "", "Input file (*.nes / *.unf / *.fds / *.nsf), or a zip or gzip file")
 	outputFile = flag.String("o", "", "Output file (*.s / *.asm)")
-	flag.Parse()
-	if !checkInputFile() {
-		printUsage()
-		os.Exit(0)
-	}
+	traceFile = flag.String("trace", "", "FCEUX or Mesen trace log to import (*.log)")
+	varsFile = flag.String("vars", "", "RAM variable names, one 'name = $address' per line")
+	projectFile = flag.String("project", "", "Project file (*.json), XXX.json by default if it exists")
+	tblFile = flag.String("tbl", "", "Text table (*.tbl) to detect and decode strings")
+	showCycles = flag.Bool("cycles", false, "Annotate instructions and basic blocks with their CPU cycles")
+	dbFile = flag.String("db", "", "No-Intro DAT or NesCartDB XML file to identify the ROM")
+	entryName = flag.String("entry", "", "File to read in a zip archive, if it holds several ROMs")
 }
 
 func checkInputFile() bool {
@@ -42,43 +63,270 @@ func printUsage() {
 	outputFileFlag := flag.Lookup("o")
 	fmt.Println(fmt.Sprintf(pattern, outputFileFlag.Name, outputFileFlag.Usage))
 
+	traceFileFlag := flag.Lookup("trace")
+	fmt.Println(fmt.Sprintf(pattern, traceFileFlag.Name, traceFileFlag.Usage))
+
+	varsFileFlag := flag.Lookup("vars")
+	fmt.Println(fmt.Sprintf(pattern, varsFileFlag.Name, varsFileFlag.Usage))
+
+	projectFileFlag := flag.Lookup("project")
+	fmt.Println(fmt.Sprintf(pattern, projectFileFlag.Name, projectFileFlag.Usage))
+
+	tblFileFlag := flag.Lookup("tbl")
+	fmt.Println(fmt.Sprintf(pattern, tblFileFlag.Name, tblFileFlag.Usage))
+
+	showCyclesFlag := flag.Lookup("cycles")
+	fmt.Println(fmt.Sprintf(pattern, showCyclesFlag.Name, showCyclesFlag.Usage))
+
+	dbFileFlag := flag.Lookup("db")
+	fmt.Println(fmt.Sprintf(pattern, dbFileFlag.Name, dbFileFlag.Usage))
+
+	entryNameFlag := flag.Lookup("entry")
+	fmt.Println(fmt.Sprintf(pattern, entryNameFlag.Name, entryNameFlag.Usage))
+
 	fmt.Println("Example:")
-	fmt.Println("  ./decompiler -i XXX.nes [-o YYY.asm]")
+	fmt.Println("  ./decompiler -i XXX.nes [-o YYY.asm] [-trace ZZZ.log] [-vars vars.txt]")
+
+	fmt.Println("Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(fmt.Sprintf("  %s", commands[name].usage))
+	}
+}
+
+// readRom reads an iNES, NES 2.0 or UNIF ROM,
+// which may be compressed.
+func readRom(path string) (*nes.Rom, error) {
+	data, err := loadFile(path, *entryName)
+	if err != nil {
+		return nil, err
+	}
+	rom, err := nes.ReadRom(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return rom, nil
+}
+
+// projectPath returns the default project file
+// of a ROM file, e.g. XXX.json for XXX.nes.
+func projectPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".json"
 }
 
-func tryReadRom() []byte {
-	rom, err := ioutil.ReadFile(*inputFile)
+// applyProject applies a project file to a disassembly.
+// Missing files are ignored unless `required` is true.
+func applyProject(path string, required bool, disassembly *nes.Disassembly) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
 	if err != nil {
-		panic(fmt.Sprintf("Failed to read '%s'. Aborting.", *inputFile))
+		return err
 	}
-	if !nes.IsNesFile(rom) {
-		panic("Not a NES ROM.")
+	defer file.Close()
+	project, err := nes.ReadProject(file)
+	if err == nil {
+		err = project.Apply(disassembly)
 	}
-	return rom
+	if err != nil {
+		return fmt.Errorf("failed to load '%s': %s", path, err)
+	}
+	return nil
 }
 
-func writePrg(reader *nes.PrgRomReader) error {
-	asm := reader.Decompile()
-	if *outputFile == "" {
-		fmt.Println(asm)
+// analyzeRom returns the analyzed disassembly of a ROM file,
+// with its project file applied.
+func analyzeRom(path string) (*nes.Disassembly, error) {
+	rom, err := readRom(path)
+	if err != nil {
+		return nil, err
+	}
+	disassembly := rom.PrgRomReader().Disassembly()
+	if err := applyProject(projectPath(path), false, disassembly); err != nil {
+		return nil, err
+	}
+	disassembly.AddVectors()
+	disassembly.Analyze()
+	return disassembly, nil
+}
+
+// writeOutput writes `content` to `path`,
+// or to the standard output if `path` is empty.
+func writeOutput(path, content string) error {
+	if path == "" {
+		fmt.Println(content)
 		return nil
 	}
-	output, err := os.OpenFile(*outputFile, os.O_WRONLY|os.O_CREATE, os.ModePerm)
+	output, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
 	if err != nil {
 		return err
 	}
 	defer output.Close()
-	output.Write([]byte(asm))
+	_, err = output.Write([]byte(content))
+	return err
+}
+
+func readTrace(path string) (*nes.Trace, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	trace, err := nes.ParseTrace(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return trace, nil
+}
+
+func readVariableNames(path string) (map[uint16]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	names, err := nes.ParseVariableNames(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return names, nil
+}
+
+// setVariableNames names the RAM variables of a disassembly
+// from the -vars file, if any.
+func setVariableNames(disassembly *nes.Disassembly) error {
+	if *varsFile == "" {
+		return nil
+	}
+	names, err := readVariableNames(*varsFile)
+	if err != nil {
+		return err
+	}
+	for address, name := range names {
+		disassembly.SetVariableName(address, name)
+	}
 	return nil
 }
 
+func readTextTable(path string) (*nes.TextTable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	table, err := nes.ParseTextTable(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return table, nil
+}
+
+// setTextTable sets the -tbl text table of a disassembly, if any.
+func setTextTable(disassembly *nes.Disassembly) error {
+	if *tblFile == "" {
+		return nil
+	}
+	table, err := readTextTable(*tblFile)
+	if err != nil {
+		return err
+	}
+	disassembly.SetTextTable(table)
+	return nil
+}
+
+// writePrg writes the listing of a ROM, preceded by
+// its database entry if a database is given.
+func writePrg(rom *nes.Rom) error {
+	disassembly := rom.PrgRomReader().Disassembly()
+	var err error
+	if *projectFile != "" {
+		err = applyProject(*projectFile, true, disassembly)
+	} else {
+		err = applyProject(projectPath(*inputFile), false, disassembly)
+	}
+	if err != nil {
+		return err
+	}
+	if *traceFile != "" {
+		trace, err := readTrace(*traceFile)
+		if err != nil {
+			return err
+		}
+		disassembly.ApplyTrace(trace)
+	}
+	if err := setVariableNames(disassembly); err != nil {
+		return err
+	}
+	if err := setTextTable(disassembly); err != nil {
+		return err
+	}
+	disassembly.AddVectors()
+	disassembly.Analyze()
+	if *showCycles {
+		cfg.Build(disassembly).AnnotateCycles()
+	}
+	listing := disassembly.String()
+	if *dbFile != "" {
+		db, err := readRomDb(*dbFile)
+		if err != nil {
+			return err
+		}
+		lines, _ := dbReport(db, rom)
+		listing = "; " + strings.Join(lines, "\n; ") + "\n" + listing
+	}
+	return writeOutput(*outputFile, listing)
+}
+
+// writeInput writes the listing of the input file,
+// whatever its format.
+func writeInput(path string) error {
+	data, err := loadFile(path, *entryName)
+	if err != nil {
+		return err
+	}
+	switch {
+	case nes.IsFdsFile(data):
+		disk, err := nes.ReadFds(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse '%s': %s", path, err)
+		}
+		return writeFds(disk)
+	case nes.IsNsfFile(data), nes.IsNsfeFile(data):
+		nsf, err := nes.ReadNsf(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse '%s': %s", path, err)
+		}
+		return writeNsf(nsf)
+	}
+	rom, err := nes.ReadRom(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return writePrg(rom)
+}
+
 func main() {
-	rom := tryReadRom()
-	var reader *nes.PrgRomReader
-	if nes.IsNes2File(rom) {
-		reader = nes.ReadNes2PrgRom(rom)
-	} else if nes.IsNesFile(rom) {
-		reader = nes.ReadNesPrgRom(rom)
-	}
-	writePrg(reader)
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Println(fmt.Sprintf("Error: %s", err))
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	flag.Parse()
+	if !checkInputFile() {
+		printUsage()
+		os.Exit(0)
+	}
+	if err := writeInput(*inputFile); err != nil {
+		fmt.Println(fmt.Sprintf("Error: %s", err))
+		os.Exit(1)
+	}
 }
@@ -1,5 +1,11 @@
 package nes
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
 // IsNesFile returns true if the raw ROM is a NES ROM,
 // i.e. `rom` starts with "NES" followed by 0x1A.
 //	IsNesFile([]byte("NES\x1A")) == true
@@ -12,3 +18,86 @@ func IsNesFile(rom []byte) bool {
 func IsNes2File(rom []byte) bool {
 	return IsNesFile(rom) && len(rom) >= 8 && (rom[7]&0x0C) == 0x08
 }
+
+// romLayout returns the offset of the PRG ROM and the sizes of
+// the PRG and CHR ROM, as given by the iNES or NES 2.0 header.
+func romLayout(rom []byte) (prgRomStartIndex, prgRomSize, chrRomSize int) {
+	prgRomStartIndex = 16 // Header size
+	if rom[6]&0b00000100 != 0 {
+		prgRomStartIndex += 512 // Trainer size
+	}
+	prgRomSize = int(rom[4]) * 16384
+	chrRomSize = int(rom[5]) * 8192
+	if IsNes2File(rom) && len(rom) >= 10 {
+		prgRomSize += int(rom[9]&0b00001111) << 8 * 16384
+		chrRomSize += int(rom[9]>>4) << 8 * 8192
+	}
+	return
+}
+
+// CheckRom returns an error if the raw ROM is not a NES ROM
+// or is shorter than its header says.
+func CheckRom(rom []byte) error {
+	if !IsNesFile(rom) || len(rom) < 16 {
+		return errors.New("not a NES ROM")
+	}
+	prgRomStartIndex, prgRomSize, chrRomSize := romLayout(rom)
+	if prgRomSize == 0 {
+		return errors.New("no PRG ROM")
+	}
+	if expected := prgRomStartIndex + prgRomSize + chrRomSize; len(rom) < expected {
+		return fmt.Errorf("%d bytes, the header expects at least %d", len(rom), expected)
+	}
+	return nil
+}
+
+// Region is the TV system, and CPU/PPU timing, a ROM runs on.
+type Region int
+
+const (
+	NTSC Region = iota
+	PAL
+	MultiRegion
+	Dendy
+)
+
+var regionNames = map[Region]string{NTSC: "NTSC", PAL: "PAL", MultiRegion: "multi-region", Dendy: "Dendy"}
+
+func (region Region) String() string {
+	return regionNames[region]
+}
+
+// ParseRegion parses "ntsc", "pal", "multi-region" or "dendy".
+func ParseRegion(s string) (Region, error) {
+	for region, name := range regionNames {
+		if strings.EqualFold(name, s) {
+			return region, nil
+		}
+	}
+	return NTSC, fmt.Errorf("unknown region '%s'", s)
+}
+
+// ReadRegion returns the region of a raw ROM: the timing field
+// of a NES 2.0 header, or the (rarely set) TV system bit of an
+// iNES header.
+// See https://wiki.nesdev.com/w/index.php/NES_2.0#CPU.2FPPU_Timing
+func ReadRegion(rom []byte) Region {
+	if IsNes2File(rom) && len(rom) > 12 {
+		return Region(rom[12] & 0b00000011)
+	}
+	if IsNesFile(rom) && len(rom) > 9 && !HasHeaderGarbage(rom) && rom[9]&0b00000001 != 0 {
+		return PAL
+	}
+	return NTSC
+}
+
+// VblankCycles returns the number of CPU cycles between the NMI
+// and the end of vblank: 20 scanlines of 341 PPU dots, 3 per CPU
+// cycle, on NTSC and Dendy; 70 scanlines, 3.2 dots per cycle, on
+// PAL. Multi-region ROMs must fit the shortest one.
+func (region Region) VblankCycles() int {
+	if region == PAL {
+		return 70 * 341 * 10 / 32
+	}
+	return 20 * 341 / 3
+}
@@ -29,3 +29,40 @@ func TestIsNes2File(t *testing.T) {
 		t.Errorf("Not supposed to be a NES 2.0 file")
 	}
 }
+
+func TestCheckRom(t *testing.T) {
+	rom := make([]byte, 16+16384+8192)
+	copy(rom, "NES\x1A\x01\x01")
+
+	if err := CheckRom(rom); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	if err := CheckRom(rom[:16+16384]); err == nil || err.Error() != "16400 bytes, the header expects at least 24592" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if err := CheckRom(rom[1:]); err == nil {
+		t.Errorf("Not supposed to be a NES file")
+	}
+}
+
+func TestReadRegion(t *testing.T) {
+	nes2 := []byte("NES\x1A\x01\x01\x00\x08\x00\x00\x00\x00\x03\x00\x00\x00")
+	if region := ReadRegion(nes2); region != Dendy {
+		t.Errorf("Expected Dendy, got %s", region)
+	}
+
+	ines := []byte("NES\x1A\x01\x01\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00")
+	if region := ReadRegion(ines); region != PAL {
+		t.Errorf("Expected PAL, got %s", region)
+	}
+
+	if cycles := NTSC.VblankCycles(); cycles != 2273 {
+		t.Errorf("Expected 2273 NTSC vblank cycles, got %d", cycles)
+	}
+
+	if cycles := PAL.VblankCycles(); cycles != 7459 {
+		t.Errorf("Expected 7459 PAL vblank cycles, got %d", cycles)
+	}
+}
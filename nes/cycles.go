@@ -0,0 +1,86 @@
+package nes
+
+import "fmt"
+
+// CycleRange is the duration of code in CPU cycles.
+type CycleRange struct {
+	Min, Max int
+}
+
+// Add returns the duration of two pieces of code in sequence.
+func (r CycleRange) Add(other CycleRange) CycleRange {
+	return CycleRange{r.Min + other.Min, r.Max + other.Max}
+}
+
+// String returns "4", or "4-5" if the duration varies.
+func (r CycleRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprint(r.Min)
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// HasPagePenalty returns true if the instruction takes one more
+// cycle when its indexed address crosses a page, i.e. for reads
+// (but not stores and read-modify-writes) through $XXXX,X,
+// $XXXX,Y and ($XX),Y.
+func (inst Instruction) HasPagePenalty() bool {
+	switch inst.Mode {
+	case AbsoluteX, AbsoluteY:
+		return inst.Cycles == 4
+	case IndirectY:
+		return inst.Cycles == 5
+	}
+	return false
+}
+
+// CrossesPage returns true if a branch, once taken, lands
+// on another page than the next instruction.
+func (inst Instruction) CrossesPage() bool {
+	target, ok := inst.Target()
+	return inst.IsBranch() && ok && target&0xFF00 != (inst.Address+2)&0xFF00
+}
+
+// CycleRange returns the duration of an instruction, from its
+// base cycles to its worst case: a taken branch, or an indexed
+// address crossing a page. An absolute index base ending with
+// $00 never crosses a page; other bases may.
+//  LDA $0300,X -> 4
+//  LDA $03F0,X -> 4-5
+//  BNE $C010   -> 2-3, or 2-4 across pages
+func (inst Instruction) CycleRange() CycleRange {
+	r := CycleRange{inst.Cycles, inst.Cycles}
+	switch {
+	case inst.IsBranch():
+		r.Max++
+		if inst.CrossesPage() {
+			r.Max++
+		}
+	case inst.HasPagePenalty():
+		if inst.Mode == IndirectY || inst.Operand&0xFF != 0 {
+			r.Max++
+		}
+	}
+	return r
+}
+
+// cycleComment returns the listing comment of the duration
+// of an instruction, e.g. "4-5 cycles (page cross)".
+func (inst Instruction) cycleComment() string {
+	r := inst.CycleRange()
+	switch {
+	case inst.IsBranch() && inst.CrossesPage():
+		return fmt.Sprintf("%s cycles (taken, page cross)", r)
+	case inst.IsBranch():
+		return fmt.Sprintf("%s cycles (taken)", r)
+	case r.Max > r.Min:
+		return fmt.Sprintf("%s cycles (page cross)", r)
+	}
+	return fmt.Sprintf("%s cycles", r)
+}
+
+// ShowCycles annotates the instructions of the
+// listing with their duration.
+func (d *Disassembly) ShowCycles() {
+	d.cycles = true
+}
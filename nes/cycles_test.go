@@ -0,0 +1,39 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestCycleRange(t *testing.T) {
+	decode := func(address uint16, code ...byte) Instruction {
+		inst, ok := DecodeInstruction(append(code, 0), 0, address)
+		assert.True(t, ok)
+		return inst
+	}
+	assert.Equal(t, CycleRange{4, 4}, decode(0xC000, 0xBD, 0x00, 0x03).CycleRange()) // LDA $0300,X
+	assert.Equal(t, CycleRange{4, 5}, decode(0xC000, 0xBD, 0xF0, 0x03).CycleRange()) // LDA $03F0,X
+	assert.Equal(t, CycleRange{5, 6}, decode(0xC000, 0xB1, 0x10).CycleRange())       // LDA ($10),Y
+	assert.Equal(t, CycleRange{6, 6}, decode(0xC000, 0x91, 0x10).CycleRange())       // STA ($10),Y
+	assert.Equal(t, CycleRange{7, 7}, decode(0xC000, 0xFE, 0xF0, 0x03).CycleRange()) // INC $03F0,X
+	assert.Equal(t, CycleRange{2, 3}, decode(0xC010, 0xD0, 0x10).CycleRange())       // BNE $C022
+	assert.Equal(t, CycleRange{2, 4}, decode(0xC0F0, 0xD0, 0x10).CycleRange())       // BNE $C102
+	assert.Equal(t, CycleRange{6, 6}, decode(0xC000, 0x20, 0x00, 0xC0).CycleRange()) // JSR $C000
+	assert.Equal(t, "4-5", CycleRange{4, 5}.String())
+
+	d := NewDisassembly(testrom.Prg(
+		0xBD, 0xF0, 0x03, // C000: LDA $03F0,X
+		0xD0, 0xFB, // C003: BNE $C000
+		0x60, // C005: RTS
+	))
+	d.AddVectors()
+	d.Analyze()
+	d.ShowCycles()
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA ram_03F0,X               ; 4-5 cycles (page cross)\n"))
+	assert.True(t, strings.Contains(asm, "BNE nmi                      ; 2-3 cycles (taken)\n"))
+	assert.True(t, strings.Contains(asm, "RTS                          ; 6 cycles\n"))
+}
@@ -0,0 +1,157 @@
+package nes
+
+import "fmt"
+
+// Instruction represents a decoded 6502 instruction.
+type Instruction struct {
+	Opcode
+	Code    byte
+	Operand uint16
+	Address uint16 // CPU address of the opcode
+	Offset  int    // PRG ROM offset of the opcode
+}
+
+// DecodeInstruction decodes the instruction located at `offset`
+// in `prg`, assuming this byte is mapped at CPU address `address`.
+// The second value is false for unknown opcodes and truncated instructions.
+func DecodeInstruction(prg []byte, offset int, address uint16) (Instruction, bool) {
+	if offset < 0 || offset >= len(prg) {
+		return Instruction{}, false
+	}
+	code := prg[offset]
+	op, ok := LookupOpcode(code)
+	if !ok {
+		return Instruction{}, false
+	}
+	size := op.Mode.OperandSize()
+	if offset+size >= len(prg) {
+		return Instruction{}, false
+	}
+	inst := Instruction{Opcode: op, Code: code, Address: address, Offset: offset}
+	switch size {
+	case 1:
+		inst.Operand = uint16(prg[offset+1])
+	case 2:
+		inst.Operand = uint16(prg[offset+1]) | uint16(prg[offset+2])<<8
+	}
+	return inst, true
+}
+
+// Size returns the number of bytes of the instruction.
+func (inst Instruction) Size() int {
+	return 1 + inst.Mode.OperandSize()
+}
+
+// Bytes returns the raw bytes of the instruction.
+func (inst Instruction) Bytes() []byte {
+	bytes := []byte{inst.Code}
+	switch inst.Mode.OperandSize() {
+	case 1:
+		bytes = append(bytes, byte(inst.Operand))
+	case 2:
+		bytes = append(bytes, byte(inst.Operand), byte(inst.Operand>>8))
+	}
+	return bytes
+}
+
+// Target returns the address an instruction transfers control to,
+// i.e. the destination of a branch, JMP or JSR.
+// Indirect jumps have no static target.
+func (inst Instruction) Target() (uint16, bool) {
+	switch {
+	case inst.Mode == Relative:
+		return inst.Address + 2 + uint16(int8(inst.Operand)), true
+	case inst.Code == JmpAbsolute, inst.Code == JsrAbsolute:
+		return inst.Operand, true
+	}
+	return 0, false
+}
+
+// IsBranch returns true for conditional branches.
+func (inst Instruction) IsBranch() bool {
+	return inst.Mode == Relative
+}
+
+// IsCall returns true for JSR.
+func (inst Instruction) IsCall() bool {
+	return inst.Code == JsrAbsolute
+}
+
+// EndsFlow returns true if the execution never falls through
+// to the next instruction (JMP, RTS, RTI and BRK).
+func (inst Instruction) EndsFlow() bool {
+	switch inst.Code {
+	case JmpAbsolute, JmpIndirect, RtsImplied, RtiImplied, Brk:
+		return true
+	}
+	return false
+}
+
+// HasMemoryOperand returns true if the operand
+// is a memory address (i.e. not an immediate value).
+func (inst Instruction) HasMemoryOperand() bool {
+	switch inst.Mode {
+	case Implied, Accumulator, Immediate:
+		return false
+	}
+	return true
+}
+
+// OperandAddress returns the memory address referenced by the operand,
+// or the branch target for relative instructions.
+func (inst Instruction) OperandAddress() (uint16, bool) {
+	if inst.Mode == Relative {
+		return inst.Target()
+	}
+	return inst.Operand, inst.HasMemoryOperand()
+}
+
+// operandValue returns the operand as an hex string.
+func (inst Instruction) operandValue() string {
+	switch inst.Mode {
+	case Relative:
+		target, _ := inst.Target()
+		return WordToAddress(target)
+	case Immediate:
+		return fmt.Sprintf("$%s", ByteToHexString(byte(inst.Operand)))
+	case ZeroPage, ZeroPageX, ZeroPageY, IndirectX, IndirectY:
+		return ByteToZeroPageAddress(byte(inst.Operand))
+	default:
+		return WordToAddress(inst.Operand)
+	}
+}
+
+// Format returns the instruction in assembly language,
+// using `operand` in place of the hex operand when not empty.
+//  LDA $0300,X -> inst.Format("buffer") == "LDA buffer,X"
+func (inst Instruction) Format(operand string) string {
+	if operand == "" {
+		operand = inst.operandValue()
+	}
+	switch inst.Mode {
+	case Implied:
+		return inst.Mnemonic
+	case Accumulator:
+		return fmt.Sprintf("%s A", inst.Mnemonic)
+	case Immediate:
+		return fmt.Sprintf("%s #%s", inst.Mnemonic, operand)
+	case ZeroPageX, AbsoluteX:
+		return fmt.Sprintf("%s %s,X", inst.Mnemonic, operand)
+	case ZeroPageY, AbsoluteY:
+		return fmt.Sprintf("%s %s,Y", inst.Mnemonic, operand)
+	case Indirect:
+		return fmt.Sprintf("%s (%s)", inst.Mnemonic, operand)
+	case IndirectX:
+		return fmt.Sprintf("%s (%s,X)", inst.Mnemonic, operand)
+	case IndirectY:
+		return fmt.Sprintf("%s (%s),Y", inst.Mnemonic, operand)
+	default:
+		return fmt.Sprintf("%s %s", inst.Mnemonic, operand)
+	}
+}
+
+// String returns the instruction in assembly language.
+//  "LDA #$10"
+func (inst Instruction) String() string {
+	return inst.Format("")
+}
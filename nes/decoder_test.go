@@ -0,0 +1,63 @@
+package nes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ExampleDecodeInstruction() {
+	prg := []byte{0xBD, 0x00, 0x03}
+	inst, _ := DecodeInstruction(prg, 0, 0xC000)
+	fmt.Println(inst)
+	// Output: LDA $0300,X
+}
+
+func TestDecodeInstruction(t *testing.T) {
+	var expectedResults = map[string][]byte{
+		"SEI":         {0x78},
+		"ASL A":       {0x0A},
+		"LDA #$10":    {0xA9, 0x10},
+		"STA $2000":   {0x8D, 0x00, 0x20},
+		"DEC $20,X":   {0xD6, 0x20},
+		"LDX $10,Y":   {0xB6, 0x10},
+		"ORA ($40),Y": {0x11, 0x40},
+		"EOR ($41,X)": {0x41, 0x41},
+		"JMP ($0006)": {0x6C, 0x06, 0x00},
+		"BPL $C000":   {0x10, 0xFE},
+		"BNE $C012":   {0xD0, 0x10},
+	}
+
+	for expected, bytes := range expectedResults {
+		inst, ok := DecodeInstruction(bytes, 0, 0xC000)
+		assert.True(t, ok)
+		assert.Equal(t, expected, inst.String())
+		assert.Equal(t, len(bytes), inst.Size())
+		assert.Equal(t, bytes, inst.Bytes())
+	}
+}
+
+func TestDecodeInstructionInvalid(t *testing.T) {
+	_, ok := DecodeInstruction([]byte{0x02}, 0, 0xC000)
+	assert.False(t, ok, "Unofficial opcode")
+
+	_, ok = DecodeInstruction([]byte{0x8D, 0x00}, 0, 0xC000)
+	assert.False(t, ok, "Truncated instruction")
+}
+
+func TestInstructionTarget(t *testing.T) {
+	inst, _ := DecodeInstruction([]byte{0xF0, 0x80}, 0, 0xC100)
+	target, ok := inst.Target()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0xC082), target)
+
+	inst, _ = DecodeInstruction([]byte{0x20, 0x34, 0x12}, 0, 0xC100)
+	target, ok = inst.Target()
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0x1234), target)
+
+	inst, _ = DecodeInstruction([]byte{0x6C, 0x34, 0x12}, 0, 0xC100)
+	_, ok = inst.Target()
+	assert.False(t, ok)
+}
@@ -0,0 +1,317 @@
+package nes
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	diffContext      = 3    // Unchanged lines around the changes
+	maxDiffEdits     = 2000 // Beyond, a bank is considered as replaced
+	minRelocatedSize = 4    // Smaller routines match by chance
+	maxRoutineSize   = 256
+)
+
+// DiffKind tells whether a line is unchanged, removed or added.
+type DiffKind byte
+
+const (
+	Unchanged DiffKind = ' '
+	Removed   DiffKind = '-'
+	Added     DiffKind = '+'
+)
+
+// DiffLine is an instruction, or a data byte, of a ROM diff.
+type DiffLine struct {
+	Kind   DiffKind
+	Offset int // PRG ROM offset in the new ROM if added, else in the old one
+	Text   string
+}
+
+// Relocation is a subroutine found at another offset.
+type Relocation struct {
+	Name     string
+	From, To int // PRG ROM offsets in the old and new ROM
+}
+
+// RomDiff is an instruction-level diff between two ROMs.
+type RomDiff struct {
+	Old, New    *Disassembly
+	Hunks       [][]DiffLine
+	Relocations []Relocation
+}
+
+// diffItem is an instruction or a byte which is not code.
+type diffItem struct {
+	offset int
+	text   string
+}
+
+// bankItems returns the instructions and data bytes of a bank.
+func (d *Disassembly) bankItems(bank int) []diffItem {
+	if bank >= len(d.Banks) {
+		return nil
+	}
+	var items []diffItem
+	for offset, end := d.Banks[bank].Offset, d.Banks[bank].Offset+d.Banks[bank].Size; offset < end; {
+		if inst, ok := d.Instruction(offset); ok {
+			items = append(items, diffItem{offset, inst.String()})
+			offset += inst.Size()
+			continue
+		}
+		items = append(items, diffItem{offset, fmt.Sprintf(".byte $%s", ByteToHexString(d.prg[offset]))})
+		offset++
+	}
+	return items
+}
+
+// Diff compares two analyzed disassemblies, bank by bank.
+func Diff(old, patched *Disassembly) *RomDiff {
+	diff := &RomDiff{Old: old, New: patched}
+	banks := len(old.Banks)
+	if len(patched.Banks) > banks {
+		banks = len(patched.Banks)
+	}
+	for bank := 0; bank < banks; bank++ {
+		a, b := old.bankItems(bank), patched.bankItems(bank)
+		diff.Hunks = append(diff.Hunks, diffHunks(a, b, diffItems(a, b))...)
+	}
+	diff.Relocations = findRelocations(old, patched)
+	return diff
+}
+
+// edit is a step of an edit script: keeps, removes or adds an item.
+type edit struct {
+	kind DiffKind
+	a, b int // Indexes in both sequences
+}
+
+// diffItems returns the shortest edit script turning `a` into `b`,
+// using the Myers algorithm on the items between their common
+// prefix and suffix.
+func diffItems(a, b []diffItem) []edit {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix].text == b[prefix].text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix].text == b[len(b)-1-suffix].text {
+		suffix++
+	}
+	var edits []edit
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, edit{Unchanged, i, i})
+	}
+	edits = append(edits, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix, prefix)...)
+	for i := suffix; i > 0; i-- {
+		edits = append(edits, edit{Unchanged, len(a) - i, len(b) - i})
+	}
+	return edits
+}
+
+// myers returns the edit script turning `a` into `b`, or removes
+// all of `a` and adds all of `b` past maxDiffEdits edits. The
+// indexes of the script start at `ia` and `ib`.
+func myers(a, b []diffItem, ia, ib int) []edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int // Copies of v before each step
+	for d := 0; d <= n+m; d++ {
+		if d > maxDiffEdits {
+			var edits []edit
+			for i := range a {
+				edits = append(edits, edit{Removed, ia + i, ib})
+			}
+			for i := range b {
+				edits = append(edits, edit{Added, ia + n, ib + i})
+			}
+			return edits
+		}
+		trace = append(trace, append([]int{}, v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x].text == b[y].text {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m, ia, ib)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack rebuilds the edit script from the steps of myers.
+func backtrack(trace [][]int, n, m, ia, ib int) []edit {
+	var edits []edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && at(k-1) < at(k+1) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, edit{Unchanged, ia + x, ib + y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, edit{Added, ia + x, ib + y})
+		} else {
+			x--
+			edits = append(edits, edit{Removed, ia + x, ib + y})
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// diffHunks groups the changes of an edit script
+// with diffContext unchanged lines around them.
+func diffHunks(a, b []diffItem, edits []edit) [][]DiffLine {
+	var hunks [][]DiffLine
+	for i := 0; i < len(edits); {
+		if edits[i].kind == Unchanged {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end, unchanged := i, 0
+		for ; end < len(edits) && unchanged <= 2*diffContext; end++ {
+			if edits[end].kind == Unchanged {
+				unchanged++
+			} else {
+				unchanged = 0
+			}
+		}
+		end -= unchanged - diffContext
+		if end > len(edits) {
+			end = len(edits)
+		}
+		var hunk []DiffLine
+		for _, e := range edits[start:end] {
+			var item diffItem
+			if e.kind == Added {
+				item = b[e.b]
+			} else {
+				item = a[e.a]
+			}
+			hunk = append(hunk, DiffLine{e.kind, item.offset, item.text})
+		}
+		hunks = append(hunks, hunk)
+		i = end
+	}
+	return hunks
+}
+
+// routineFingerprint returns the instructions of a subroutine up
+// to its first RTS, RTI or JMP, without the ROM addresses, which
+// change when the routine moves. The second value is the number
+// of instructions.
+func (d *Disassembly) routineFingerprint(offset int) (string, int) {
+	var texts []string
+	for len(texts) < maxRoutineSize {
+		inst, ok := d.Instruction(offset)
+		if !ok {
+			break
+		}
+		if address, ok := inst.OperandAddress(); ok && (inst.IsBranch() || address >= 0x8000) {
+			texts = append(texts, inst.Format("*"))
+		} else {
+			texts = append(texts, inst.String())
+		}
+		if inst.EndsFlow() {
+			break
+		}
+		offset += inst.Size()
+	}
+	return strings.Join(texts, "\n"), len(texts)
+}
+
+// findRelocations returns the subroutines of the old ROM
+// found at another offset in the new one, and not at their
+// original offset.
+func findRelocations(old, patched *Disassembly) []Relocation {
+	moved := map[string][]int{}
+	for _, offset := range patched.Subroutines() {
+		fingerprint, _ := patched.routineFingerprint(offset)
+		moved[fingerprint] = append(moved[fingerprint], offset)
+	}
+	var relocations []Relocation
+	for _, offset := range old.Subroutines() {
+		fingerprint, size := old.routineFingerprint(offset)
+		if size < minRelocatedSize {
+			continue
+		}
+		if same, _ := patched.routineFingerprint(offset); same == fingerprint {
+			continue
+		}
+		if offsets := moved[fingerprint]; len(offsets) > 0 {
+			name, _ := old.Label(offset)
+			relocations = append(relocations, Relocation{Name: name, From: offset, To: offsets[0]})
+			moved[fingerprint] = offsets[1:]
+		}
+	}
+	return relocations
+}
+
+// String writes the relocations, then the hunks of the diff.
+//  ; sub_C020 moved from $C020 to $C030
+//  @@ $C000 $C000 @@
+//    $C000 LDA #$00
+//  - $C002 STA $0300
+//  + $C002 STA $0301
+func (diff *RomDiff) String() string {
+	var lines []string
+	for _, r := range diff.Relocations {
+		lines = append(lines, fmt.Sprintf("; %s moved from %s to %s", r.Name, diff.Old.Location(r.From), diff.New.Location(r.To)))
+	}
+	for _, hunk := range diff.Hunks {
+		oldStart, newStart := "", ""
+		for _, line := range hunk {
+			if line.Kind != Added && oldStart == "" {
+				oldStart = diff.Old.Location(line.Offset)
+			}
+			if line.Kind == Added && newStart == "" {
+				newStart = diff.New.Location(line.Offset)
+			}
+		}
+		if oldStart == "" {
+			oldStart = "-"
+		}
+		if newStart == "" {
+			newStart = oldStart
+		}
+		lines = append(lines, fmt.Sprintf("@@ %s %s @@", oldStart, newStart))
+		for _, line := range hunk {
+			d := diff.Old
+			if line.Kind == Added {
+				d = diff.New
+			}
+			lines = append(lines, fmt.Sprintf("%c %-9s %s", line.Kind, d.Location(line.Offset), line.Text))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,57 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestDiff(t *testing.T) {
+	routine := []byte{
+		0xA9, 0x01, // LDA #$01
+		0x8D, 0x00, 0x03, // STA $0300
+		0xA2, 0x02, // LDX #$02
+		0x8E, 0x01, 0x03, // STX $0301
+		0x60, // RTS
+	}
+	oldPrg := testrom.Prg(
+		0x20, 0x10, 0xC0, // C000: JSR $C010
+		0xA9, 0x00, // C003: LDA #$00
+		0x60, // C005: RTS
+	)
+	copy(oldPrg[0x10:], routine)
+	newPrg := testrom.Prg(
+		0xEA,             // C000: NOP
+		0x20, 0x11, 0xC0, // C001: JSR $C011
+		0xA9, 0x01, // C004: LDA #$01
+		0x60, // C006: RTS
+	)
+	copy(newPrg[0x11:], routine)
+
+	old, patched := NewDisassembly(oldPrg), NewDisassembly(newPrg)
+	for _, d := range []*Disassembly{old, patched} {
+		d.AddVectors()
+		d.Analyze()
+	}
+	diff := Diff(old, patched)
+	assert.Equal(t, []Relocation{{Name: "sub_C010", From: 0x10, To: 0x11}}, diff.Relocations)
+	assert.Equal(t, `; sub_C010 moved from $C010 to $C011
+@@ $C000 $C000 @@
+- $C000     JSR $C010
+- $C003     LDA #$00
++ $C000     NOP
++ $C001     JSR $C011
++ $C004     LDA #$01
+  $C005     RTS
+  $C006     .byte $FF
+  $C007     .byte $FF
+@@ $C015 $C015 @@
+  $C015     LDX #$02
+  $C017     STX $0301
+  $C01A     RTS
+- $C01B     .byte $FF
+  $C01C     .byte $FF
+  $C01D     .byte $FF
+  $C01E     .byte $FF`, diff.String())
+}
@@ -0,0 +1,577 @@
+package nes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ByteKind tells how a PRG ROM byte has been classified
+// by the analysis.
+type ByteKind byte
+
+const (
+	UnknownByte ByteKind = iota
+	CodeByte             // First byte of an instruction
+	OperandByte          // Operand byte of an instruction
+	DataByte
+)
+
+const bankSize = 0x4000
+
+// Bank describes where a slice of the PRG ROM
+// is mapped in the CPU address space.
+type Bank struct {
+	Index  int
+	Offset int // Offset in the PRG ROM
+	Size   int
+	Base   uint16 // CPU address of the first byte
+	Fixed  bool   // The bank is always mapped
+}
+
+// Contains returns true if `address` is
+// inside the bank once mapped.
+func (bank Bank) Contains(address uint16) bool {
+	return address >= bank.Base && int(address-bank.Base) < bank.Size
+}
+
+// ContainsOffset returns true if the PRG ROM
+// offset `offset` belongs to the bank.
+func (bank Bank) ContainsOffset(offset int) bool {
+	return offset >= bank.Offset && offset < bank.Offset+bank.Size
+}
+
+// AddressOf returns the CPU address of a PRG ROM offset.
+func (bank Bank) AddressOf(offset int) uint16 {
+	return bank.Base + uint16(offset-bank.Offset)
+}
+
+// OffsetOf returns the PRG ROM offset of a CPU address.
+func (bank Bank) OffsetOf(address uint16) int {
+	return bank.Offset + int(address-bank.Base)
+}
+
+// DefaultBanks returns the usual PRG ROM layout:
+// NROM images are mapped as a whole (16 KiB images at $C000),
+// bigger images are split into 16 KiB banks with
+// the last one fixed at $C000 and the others at $8000.
+func DefaultBanks(prgSize int) []Bank {
+	if prgSize <= bankSize {
+		return []Bank{{Index: 0, Size: prgSize, Base: 0xC000, Fixed: true}}
+	}
+	if prgSize <= 2*bankSize {
+		return []Bank{{Index: 0, Size: prgSize, Base: 0x8000, Fixed: true}}
+	}
+	return SplitBanks(prgSize, bankSize)
+}
+
+// SplitBanks splits a PRG ROM into banks of `size` bytes mapped at
+// $8000, except the last one which is fixed at the end of the
+// address space, e.g. at $E000 for 8 KiB banks.
+func SplitBanks(prgSize, size int) []Bank {
+	var banks []Bank
+	for offset := 0; offset < prgSize; offset += size {
+		bankSize := size
+		if prgSize-offset < bankSize {
+			bankSize = prgSize - offset
+		}
+		banks = append(banks, Bank{Index: len(banks), Offset: offset, Size: bankSize, Base: 0x8000})
+	}
+	last := &banks[len(banks)-1]
+	last.Base = uint16(0x10000 - last.Size)
+	last.Fixed = true
+	return banks
+}
+
+// Disassembly represents a recursive descent disassembly
+// of a PRG ROM: code is only decoded from entry points
+// and followed through branches, jumps and calls.
+type Disassembly struct {
+	Banks        []Bank
+	prg          []byte
+	kinds        []ByteKind
+	instructions map[int]Instruction
+	labels       map[int]string
+	autoLabels   map[int]bool
+	comments     map[int][]string
+	tables       map[int]*Table
+	jumpEngines  map[int]bool
+	subroutines  map[int]bool
+	dispatches   map[int]*Table
+	idioms       map[int]*Idiom
+	pointers     map[int]pointerHalf
+	xrefs        map[uint16][]Xref
+	variables    map[uint16]*Variable // By address, both bytes of words
+	varNames     map[uint16]string
+	texts        map[int]int // Lengths of the text strings, by PRG ROM offset
+	textTable    *TextTable
+	cycles       bool // Annotates the instructions with their duration
+	pending      []int
+}
+
+// NewDisassembly returns an empty disassembly of `prg`
+// using the default bank layout.
+func NewDisassembly(prg []byte) *Disassembly {
+	return &Disassembly{
+		Banks:        DefaultBanks(len(prg)),
+		prg:          prg,
+		kinds:        make([]ByteKind, len(prg)),
+		instructions: map[int]Instruction{},
+		labels:       map[int]string{},
+		autoLabels:   map[int]bool{},
+		comments:     map[int][]string{},
+		tables:       map[int]*Table{},
+		jumpEngines:  map[int]bool{},
+		subroutines:  map[int]bool{},
+		dispatches:   map[int]*Table{},
+		idioms:       map[int]*Idiom{},
+		pointers:     map[int]pointerHalf{},
+		xrefs:        map[uint16][]Xref{},
+		varNames:     map[uint16]string{},
+		texts:        map[int]int{},
+	}
+}
+
+// Prg returns the disassembled PRG ROM.
+func (d *Disassembly) Prg() []byte {
+	return d.prg
+}
+
+// BankAt returns the bank containing a PRG ROM offset.
+func (d *Disassembly) BankAt(offset int) Bank {
+	for _, bank := range d.Banks {
+		if bank.ContainsOffset(offset) {
+			return bank
+		}
+	}
+	return Bank{Index: -1}
+}
+
+// AddressOf returns the CPU address of a PRG ROM offset.
+func (d *Disassembly) AddressOf(offset int) uint16 {
+	return d.BankAt(offset).AddressOf(offset)
+}
+
+// Resolve returns the PRG ROM offset of a CPU address seen from
+// the bank `from` (-1 if unknown). The second value is false if
+// the address is not in PRG ROM or if it lies in a switchable
+// bank that cannot be statically determined.
+func (d *Disassembly) Resolve(from int, address uint16) (int, bool) {
+	if len(d.prg) == 0 {
+		return 0, false
+	}
+	if from >= 0 && from < len(d.Banks) && d.Banks[from].Contains(address) {
+		return d.Banks[from].OffsetOf(address), true
+	}
+	for _, bank := range d.Banks {
+		if bank.Fixed && bank.Contains(address) {
+			return bank.OffsetOf(address), true
+		}
+	}
+	if address < 0x8000 {
+		return 0, false
+	}
+	if len(d.Banks) == 1 && d.Banks[0].Base >= 0x8000 {
+		// Small images are mirrored over $8000-$FFFF
+		bank := d.Banks[0]
+		return bank.Offset + int(address-0x8000)%bank.Size, true
+	}
+	return 0, false
+}
+
+// Kind returns the classification of a PRG ROM byte.
+func (d *Disassembly) Kind(offset int) ByteKind {
+	if offset < 0 || offset >= len(d.kinds) {
+		return UnknownByte
+	}
+	return d.kinds[offset]
+}
+
+// Coverage returns the share of the PRG ROM classified as code,
+// between 0 and 1.
+func (d *Disassembly) Coverage() float64 {
+	if len(d.kinds) == 0 {
+		return 0
+	}
+	code := 0
+	for _, kind := range d.kinds {
+		if kind == CodeByte || kind == OperandByte {
+			code++
+		}
+	}
+	return float64(code) / float64(len(d.kinds))
+}
+
+// Instruction returns the instruction decoded at a PRG ROM offset.
+func (d *Disassembly) Instruction(offset int) (Instruction, bool) {
+	inst, ok := d.instructions[offset]
+	return inst, ok
+}
+
+// Instructions returns every decoded instruction,
+// sorted by PRG ROM offset.
+func (d *Disassembly) Instructions() []Instruction {
+	instructions := make([]Instruction, 0, len(d.instructions))
+	for _, inst := range d.instructions {
+		instructions = append(instructions, inst)
+	}
+	sort.Slice(instructions, func(i, j int) bool {
+		return instructions[i].Offset < instructions[j].Offset
+	})
+	return instructions
+}
+
+// Label returns the label of a PRG ROM offset.
+func (d *Disassembly) Label(offset int) (string, bool) {
+	label, ok := d.labels[offset]
+	return label, ok
+}
+
+// SetLabel names a PRG ROM offset,
+// replacing any generated label.
+func (d *Disassembly) SetLabel(offset int, name string) {
+	d.labels[offset] = name
+	delete(d.autoLabels, offset)
+}
+
+// Labels returns every label, indexed by PRG ROM offset.
+func (d *Disassembly) Labels() map[int]string {
+	return d.labels
+}
+
+// autoLabel generates a label such as "sub_C012"; the bank
+// number is added for switchable banks, i.e. "sub_02_8012".
+func (d *Disassembly) autoLabel(prefix string, offset int) string {
+	bank := d.BankAt(offset)
+	address := bank.AddressOf(offset)
+	if !bank.Fixed {
+		return fmt.Sprintf("%s_%02X_%04X", prefix, bank.Index, address)
+	}
+	return fmt.Sprintf("%s_%04X", prefix, address)
+}
+
+// addAutoLabel names an offset unless it has already been named.
+// Subroutine labels take precedence over other generated labels.
+func (d *Disassembly) addAutoLabel(offset int, prefix string) {
+	if _, ok := d.labels[offset]; ok && !(d.autoLabels[offset] && prefix == "sub") {
+		return
+	}
+	d.labels[offset] = d.autoLabel(prefix, offset)
+	d.autoLabels[offset] = true
+}
+
+// Comments returns the comments of a PRG ROM offset.
+func (d *Disassembly) Comments(offset int) []string {
+	return d.comments[offset]
+}
+
+// AddComment adds a comment to a PRG ROM offset.
+func (d *Disassembly) AddComment(offset int, comment string) {
+	d.comments[offset] = append(d.comments[offset], comment)
+}
+
+// AddEntryPoint adds a PRG ROM offset to decode as code
+// during the next call to Analyze. `label` may be empty.
+func (d *Disassembly) AddEntryPoint(offset int, label string) {
+	if offset < 0 || offset >= len(d.prg) {
+		return
+	}
+	if label != "" {
+		d.SetLabel(offset, label)
+	}
+	d.pending = append(d.pending, offset)
+}
+
+// MarkData marks a PRG ROM range as data,
+// so that it is never decoded as code.
+func (d *Disassembly) MarkData(offset, length int) {
+	for i := offset; i < offset+length && i < len(d.kinds); i++ {
+		if i >= 0 && d.kinds[i] == UnknownByte {
+			d.kinds[i] = DataByte
+		}
+	}
+}
+
+// MarkText marks a PRG ROM range as a text string,
+// decoded with the text table in the listing.
+func (d *Disassembly) MarkText(offset, length int) {
+	if offset < 0 || length <= 0 || offset+length > len(d.prg) {
+		return
+	}
+	d.MarkData(offset, length)
+	d.texts[offset] = length
+}
+
+// Interrupt vectors, in the order of $FFFA-$FFFF.
+const (
+	NmiVector = iota
+	ResetVector
+	IrqVector
+)
+
+// Vector returns the PRG ROM offset of the handler
+// of an interrupt vector, e.g. NmiVector.
+func (d *Disassembly) Vector(vector int) (int, bool) {
+	vectors, ok := d.Resolve(-1, 0xFFFA)
+	if !ok || vectors+6 > len(d.prg) {
+		return 0, false
+	}
+	address := uint16(d.prg[vectors+2*vector]) | uint16(d.prg[vectors+2*vector+1])<<8
+	return d.Resolve(d.BankAt(vectors).Index, address)
+}
+
+// AddVectors adds the NMI, RESET and IRQ vectors
+// located at $FFFA-$FFFF as entry points.
+// See https://wiki.nesdev.com/w/index.php/CPU_memory_map
+func (d *Disassembly) AddVectors() {
+	vectors, ok := d.Resolve(-1, 0xFFFA)
+	if !ok || vectors+6 > len(d.prg) {
+		return
+	}
+	for i, name := range []string{"nmi", "reset", "irq"} {
+		if offset, ok := d.Vector(NmiVector + i); ok {
+			if _, named := d.labels[offset]; !named {
+				d.SetLabel(offset, name)
+			}
+			d.AddEntryPoint(offset, "")
+			d.subroutines[offset] = true
+		}
+	}
+	d.MarkData(vectors, 6)
+}
+
+// Subroutines returns the PRG ROM offsets of the routines found so
+// far: interrupt handlers, JSR targets and jump table targets.
+func (d *Disassembly) Subroutines() []int {
+	offsets := make([]int, 0, len(d.subroutines))
+	for offset := range d.subroutines {
+		if d.kinds[offset] == CodeByte {
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Ints(offsets)
+	return offsets
+}
+
+// IsSubroutine returns true if a routine starts at a PRG ROM offset.
+func (d *Disassembly) IsSubroutine(offset int) bool {
+	return d.subroutines[offset]
+}
+
+// Analyze decodes the code reachable from the entry points.
+// Jump tables found along the way are decoded
+// and their targets are analyzed as well; pointer tables,
+// 16-bit idioms, cross-references, RAM variables and,
+// given a text table, strings are found once the code is known.
+func (d *Disassembly) Analyze() {
+	for len(d.pending) > 0 {
+		offset := d.pending[len(d.pending)-1]
+		d.pending = d.pending[:len(d.pending)-1]
+		d.follow(offset)
+	}
+	d.detectPointerTables()
+	d.detectIdioms()
+	d.buildXrefs()
+	d.detectVariables()
+	if d.textTable != nil {
+		d.detectStrings()
+	}
+}
+
+// follow decodes instructions from `offset` until the flow ends
+// or reaches already classified bytes.
+func (d *Disassembly) follow(offset int) {
+	var run []Instruction
+	for offset >= 0 && offset < len(d.prg) && d.kinds[offset] == UnknownByte {
+		bank := d.BankAt(offset)
+		inst, ok := DecodeInstruction(d.prg, offset, bank.AddressOf(offset))
+		if !ok || !bank.ContainsOffset(offset+inst.Size()-1) {
+			return
+		}
+		for i := 1; i < inst.Size(); i++ {
+			if d.kinds[offset+i] != UnknownByte {
+				return
+			}
+		}
+		d.kinds[offset] = CodeByte
+		for i := 1; i < inst.Size(); i++ {
+			d.kinds[offset+i] = OperandByte
+		}
+		d.instructions[offset] = inst
+		run = append(run, inst)
+		if target, ok := inst.Target(); ok {
+			if targetOffset, ok := d.Resolve(bank.Index, target); ok {
+				if inst.IsCall() {
+					d.addAutoLabel(targetOffset, "sub")
+					d.subroutines[targetOffset] = true
+				} else {
+					d.addAutoLabel(targetOffset, "loc")
+				}
+				d.pending = append(d.pending, targetOffset)
+				if inst.IsCall() && d.isJumpEngine(targetOffset) {
+					// The return address points to a jump table
+					d.detectInlineJumpTable(inst)
+					return
+				}
+			}
+		}
+		if inst.EndsFlow() {
+			d.detectJumpTable(bank, run)
+			return
+		}
+		offset += inst.Size()
+	}
+}
+
+// Line is a line of a disassembly listing.
+type Line struct {
+	Offset       int // PRG ROM offset, -1 if the line has no content
+	Address      uint16
+	Label        string
+	Text         string
+	Comment      string
+	LabelComment string // Written above the label, e.g. its references
+}
+
+// String returns the line in assembly language.
+func (line Line) String() string {
+	var builder strings.Builder
+	if line.Label != "" && line.LabelComment != "" {
+		builder.WriteString("; " + line.LabelComment + "\n")
+	}
+	if line.Label != "" {
+		builder.WriteString(line.Label)
+		builder.WriteString(":\n")
+	}
+	text := ""
+	if line.Text != "" {
+		text = "    " + line.Text
+	}
+	if line.Comment != "" {
+		if text != "" {
+			text = fmt.Sprintf("%-32s ", text)
+		}
+		text += "; " + line.Comment
+	}
+	builder.WriteString(text)
+	return builder.String()
+}
+
+// operandLabel returns the label of the ROM location or the name
+// of the RAM variable referenced by an instruction operand, if any.
+func (d *Disassembly) operandLabel(bank Bank, inst Instruction) string {
+	address, ok := inst.OperandAddress()
+	if !ok {
+		return ""
+	}
+	if d.isRAM(address) {
+		return d.variableOperand(address)
+	}
+	offset, ok := d.Resolve(bank.Index, address)
+	if !ok || d.kinds[offset] == OperandByte {
+		return ""
+	}
+	if label, ok := d.labels[offset]; ok {
+		return label
+	}
+	if table, ok := d.tableAt(offset); ok {
+		if label, ok := d.labels[table.Offset]; ok {
+			return fmt.Sprintf("%s+%d", label, offset-table.Offset)
+		}
+	}
+	return ""
+}
+
+// FormatInstruction returns an instruction in assembly language,
+// using labels for the ROM locations it references. The immediates
+// of pointer setups are written #<label and #>label.
+func (d *Disassembly) FormatInstruction(inst Instruction) string {
+	if half, ok := d.pointers[inst.Offset]; ok {
+		if label, ok := d.labels[half.target]; ok {
+			if half.high {
+				return inst.Format(">" + label)
+			}
+			return inst.Format("<" + label)
+		}
+	}
+	return inst.Format(d.operandLabel(d.BankAt(inst.Offset), inst))
+}
+
+// Listing returns the disassembly as listing lines: the RAM
+// layout, then the PRG ROM bank by bank. Bytes which are not
+// code are written as .byte directives.
+func (d *Disassembly) Listing() []Line {
+	lines := d.variableLines()
+	for _, bank := range d.Banks {
+		lines = append(lines, Line{
+			Offset:  -1,
+			Address: bank.Base,
+			Text:    fmt.Sprintf(".org %s", WordToAddress(bank.Base)),
+			Comment: fmt.Sprintf("PRG bank %d", bank.Index),
+		})
+		end := bank.Offset + bank.Size
+		for offset := bank.Offset; offset < end; {
+			if table, ok := d.tables[offset]; ok {
+				lines = append(lines, d.tableLines(table)...)
+				offset += table.Size()
+				continue
+			}
+			line := Line{
+				Offset:       offset,
+				Address:      bank.AddressOf(offset),
+				Label:        d.labels[offset],
+				Comment:      joinComments(d.comments[offset]),
+				LabelComment: d.xrefComment(offset),
+			}
+			if inst, ok := d.instructions[offset]; ok && d.kinds[offset] == CodeByte {
+				line.Text = d.FormatInstruction(inst)
+				if d.cycles {
+					line.Comment = joinComments(append(d.comments[offset], inst.cycleComment()))
+				}
+				offset += inst.Size()
+			} else if length, ok := d.texts[offset]; ok && d.kinds[offset] == DataByte {
+				lines = append(lines, d.textLines(line, length)...)
+				offset += length
+				continue
+			} else {
+				line.Text, offset = d.formatBytes(offset, end)
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// formatBytes writes up to 8 non-code bytes as a .byte directive,
+// stopping before code, labels and comments.
+// It returns the directive and the offset following it.
+func (d *Disassembly) formatBytes(offset, end int) (string, int) {
+	var values []string
+	for i := offset; i < end && len(values) < 8; i++ {
+		if i != offset {
+			if _, ok := d.labels[i]; ok || d.kinds[i] == CodeByte || len(d.comments[i]) > 0 {
+				break
+			}
+			if _, ok := d.tables[i]; ok {
+				break
+			}
+		}
+		values = append(values, fmt.Sprintf("$%s", ByteToHexString(d.prg[i])))
+	}
+	return fmt.Sprintf(".byte %s", strings.Join(values, ",")), offset + len(values)
+}
+
+// joinComments returns the comments of a line.
+func joinComments(comments []string) string {
+	return strings.Join(comments, "; ")
+}
+
+// String returns the disassembly in assembly language.
+func (d *Disassembly) String() string {
+	var builder strings.Builder
+	for _, line := range d.Listing() {
+		builder.WriteString(line.String())
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
@@ -0,0 +1,75 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestDefaultBanks(t *testing.T) {
+	banks := DefaultBanks(0x4000)
+	assert.Len(t, banks, 1)
+	assert.Equal(t, uint16(0xC000), banks[0].Base)
+
+	banks = DefaultBanks(0x20000)
+	assert.Len(t, banks, 8)
+	assert.Equal(t, uint16(0x8000), banks[0].Base)
+	assert.False(t, banks[0].Fixed)
+	assert.Equal(t, uint16(0xC000), banks[7].Base)
+	assert.True(t, banks[7].Fixed)
+}
+
+func TestResolve(t *testing.T) {
+	d := NewDisassembly(make([]byte, 0x20000))
+	offset, ok := d.Resolve(-1, 0xFFFA)
+	assert.True(t, ok)
+	assert.Equal(t, 0x1FFFA, offset)
+
+	offset, ok = d.Resolve(2, 0x8010)
+	assert.True(t, ok)
+	assert.Equal(t, 0x8010, offset)
+
+	_, ok = d.Resolve(7, 0x8010)
+	assert.False(t, ok, "Switchable bank seen from the fixed bank")
+
+	_, ok = d.Resolve(-1, 0x0300)
+	assert.False(t, ok, "RAM")
+
+	d = NewDisassembly(make([]byte, 0x4000))
+	offset, ok = d.Resolve(-1, 0x8010)
+	assert.True(t, ok)
+	assert.Equal(t, 0x10, offset, "Mirrored NROM-128")
+}
+
+func TestAnalyze(t *testing.T) {
+	prg := testrom.Prg(
+		0x78,             // C000: SEI
+		0x20, 0x09, 0xC0, // C001: JSR $C009
+		0xD0, 0xFB, // C004: BNE $C001
+		0x4C, 0x00, 0xC0, // C006: JMP $C000
+		0x60, // C009: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	assert.Equal(t, CodeByte, d.Kind(0x00))
+	assert.Equal(t, OperandByte, d.Kind(0x02))
+	assert.Equal(t, UnknownByte, d.Kind(0x0A))
+	assert.Equal(t, DataByte, d.Kind(0x3FFA))
+	assert.Equal(t, 10.0/0x4000, d.Coverage())
+
+	label, _ := d.Label(0x00)
+	assert.Equal(t, "nmi", label)
+	label, _ = d.Label(0x01)
+	assert.Equal(t, "loc_C001", label)
+
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "JSR sub_C009\n"))
+	assert.True(t, strings.Contains(asm, "BNE loc_C001\n"))
+	assert.True(t, strings.Contains(asm, "JMP nmi\n"))
+	assert.True(t, strings.Contains(asm, "sub_C009:\n    RTS\n"))
+	assert.True(t, strings.Contains(asm, ".byte $FF,$FF,$FF,$FF,$FF,$FF,$FF,$FF\n"))
+}
@@ -0,0 +1,248 @@
+package nes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	fdsHeaderSize   = 16    // fwNES header
+	fdsSideSize     = 65500 // Without the CRC of the blocks
+	fdsInfoSize     = 56
+	fdsFileHeadSize = 16
+	fdsVerification = "*NINTENDO-HVC*"
+	fdsVectors      = 0xDFF6
+)
+
+// fdsVectorNames are the names of the vectors at $DFF6-$DFFF.
+var fdsVectorNames = []string{"nmi1", "nmi2", "nmi3", "reset", "irq"}
+
+// FDS block codes.
+const (
+	fdsInfoBlock = iota + 1
+	fdsFileAmountBlock
+	fdsFileHeaderBlock
+	fdsFileDataBlock
+)
+
+// FdsFileKind tells where the BIOS loads a file.
+type FdsFileKind byte
+
+const (
+	FdsPrg  FdsFileKind = iota // CPU memory
+	FdsChr                     // PPU pattern tables
+	FdsVram                    // PPU nametables
+)
+
+var fdsFileKindNames = map[FdsFileKind]string{FdsPrg: "PRG", FdsChr: "CHR", FdsVram: "VRAM"}
+
+func (kind FdsFileKind) String() string {
+	if name, ok := fdsFileKindNames[kind]; ok {
+		return name
+	}
+	return fmt.Sprintf("kind %d", kind)
+}
+
+// FdsFile is a file of a disk side.
+type FdsFile struct {
+	Number  byte
+	ID      byte // Files up to the boot file ID are loaded at boot
+	Name    string
+	Address uint16 // Load address
+	Kind    FdsFileKind
+	Data    []byte
+}
+
+// FdsSide is a side of a disk.
+type FdsSide struct {
+	GameName   string
+	Side       byte // 0 for side A, 1 for side B
+	Disk       byte
+	BootFileID byte
+	Files      []FdsFile
+}
+
+// FdsDisk is a Famicom Disk System image.
+// See https://wiki.nesdev.com/w/index.php/FDS_disk_format
+type FdsDisk struct {
+	Sides []FdsSide
+}
+
+// IsFdsFile returns true if `data` is an FDS image, with or
+// without fwNES header, i.e. it starts with "FDS" followed by
+// 0x1A or with the disk info block.
+//  IsFdsFile([]byte("FDS\x1A")) == true
+func IsFdsFile(data []byte) bool {
+	if len(data) >= 4 && string(data[0:3]) == "FDS" && data[3] == 0x1A {
+		return true
+	}
+	return len(data) >= 1+len(fdsVerification) && data[0] == fdsInfoBlock &&
+		string(data[1:1+len(fdsVerification)]) == fdsVerification
+}
+
+// ReadFds parses an FDS image into its sides and files.
+func ReadFds(data []byte) (*FdsDisk, error) {
+	if !IsFdsFile(data) {
+		return nil, errors.New("not an FDS image")
+	}
+	if data[0] == 'F' {
+		if len(data) < fdsHeaderSize {
+			return nil, errors.New("truncated fwNES header")
+		}
+		data = data[fdsHeaderSize:]
+	}
+	disk := &FdsDisk{}
+	for offset := 0; offset+fdsInfoSize <= len(data); offset += fdsSideSize {
+		end := offset + fdsSideSize
+		if end > len(data) {
+			end = len(data)
+		}
+		side, err := readFdsSide(data[offset:end])
+		if err != nil {
+			return nil, fmt.Errorf("side %d: %s", len(disk.Sides), err)
+		}
+		disk.Sides = append(disk.Sides, *side)
+	}
+	if len(disk.Sides) == 0 {
+		return nil, errors.New("no disk side")
+	}
+	return disk, nil
+}
+
+// readFdsSide parses the blocks of a disk side. Files are read
+// until a block is not a file header, as some games hide files
+// after the count of the file amount block.
+func readFdsSide(data []byte) (*FdsSide, error) {
+	if data[0] != fdsInfoBlock || string(data[1:1+len(fdsVerification)]) != fdsVerification {
+		return nil, errors.New("missing disk info block")
+	}
+	side := &FdsSide{
+		GameName:   strings.TrimRight(string(data[16:19]), " \x00"),
+		Side:       data[21],
+		Disk:       data[22],
+		BootFileID: data[25],
+	}
+	offset := fdsInfoSize
+	if offset+2 > len(data) || data[offset] != fdsFileAmountBlock {
+		return nil, errors.New("missing file amount block")
+	}
+	offset += 2
+	for offset+fdsFileHeadSize <= len(data) && data[offset] == fdsFileHeaderBlock {
+		header := data[offset : offset+fdsFileHeadSize]
+		file := FdsFile{
+			Number:  header[1],
+			ID:      header[2],
+			Name:    strings.TrimRight(string(header[3:11]), " \x00"),
+			Address: uint16(header[11]) | uint16(header[12])<<8,
+			Kind:    FdsFileKind(header[15]),
+		}
+		size := int(header[13]) | int(header[14])<<8
+		offset += fdsFileHeadSize
+		if offset+1+size > len(data) || data[offset] != fdsFileDataBlock {
+			return nil, fmt.Errorf("missing data of file '%s'", file.Name)
+		}
+		file.Data = data[offset+1 : offset+1+size]
+		offset += 1 + size
+		side.Files = append(side.Files, file)
+	}
+	return side, nil
+}
+
+// Vectors returns the interrupt vectors of a side, loaded at
+// $DFF6-$DFFF by one of its PRG files: three NMI vectors, the
+// reset vector and the IRQ vector. The last file loaded wins.
+func (side *FdsSide) Vectors() map[string]uint16 {
+	vectors := map[string]uint16{}
+	for _, file := range side.Files {
+		if file.Kind != FdsPrg {
+			continue
+		}
+		for i, name := range fdsVectorNames {
+			address := fdsVectors + 2*i
+			offset := address - int(file.Address)
+			if offset >= 0 && offset+1 < len(file.Data) {
+				vectors[name] = uint16(file.Data[offset]) | uint16(file.Data[offset+1])<<8
+			}
+		}
+	}
+	return vectors
+}
+
+// Disassembly returns a disassembly of a PRG file mapped at its
+// load address, with the vectors of its side pointing in the file
+// as entry points, and the JSR and JMP targets of the other files.
+func (side *FdsSide) Disassembly(file FdsFile) *Disassembly {
+	return side.disassembly(file, side.entryPoints())
+}
+
+// entryPoints returns the JSR and JMP targets of the PRG files
+// of a side, true for subroutines. The files are analyzed until
+// no new target is found, as code reached from another file can
+// call a third one.
+func (side *FdsSide) entryPoints() map[uint16]bool {
+	entries := map[uint16]bool{}
+	for found := true; found; {
+		found = false
+		for _, file := range side.Files {
+			if file.Kind != FdsPrg {
+				continue
+			}
+			d := side.disassembly(file, entries)
+			d.Analyze()
+			for _, inst := range d.Instructions() {
+				if !inst.IsCall() && inst.Code != JmpAbsolute {
+					continue
+				}
+				address, _ := inst.OperandAddress()
+				if call, known := entries[address]; !known || inst.IsCall() && !call {
+					entries[address] = inst.IsCall()
+					found = true
+				}
+			}
+		}
+	}
+	return entries
+}
+
+// disassembly returns a disassembly of a PRG file with the vectors
+// and the `entries` pointing in the file as entry points.
+func (side *FdsSide) disassembly(file FdsFile, entries map[uint16]bool) *Disassembly {
+	d := NewDisassembly(file.Data)
+	d.Banks = []Bank{{Index: 0, Offset: 0, Size: len(file.Data), Base: file.Address, Fixed: true}}
+	vectors := side.Vectors()
+	for _, name := range fdsVectorNames {
+		address, ok := vectors[name]
+		if !ok {
+			continue
+		}
+		if offset, ok := d.Resolve(0, address); ok {
+			if _, named := d.labels[offset]; !named {
+				d.SetLabel(offset, name)
+			}
+			d.AddEntryPoint(offset, "")
+			d.subroutines[offset] = true
+		}
+	}
+	addresses := make([]int, 0, len(entries))
+	for address := range entries {
+		addresses = append(addresses, int(address))
+	}
+	sort.Ints(addresses)
+	for _, address := range addresses {
+		if offset, ok := d.Resolve(0, uint16(address)); ok {
+			d.AddEntryPoint(offset, "")
+			if entries[uint16(address)] {
+				d.subroutines[offset] = true
+				d.addAutoLabel(offset, "sub")
+			} else {
+				d.addAutoLabel(offset, "loc")
+			}
+		}
+	}
+	if offset, ok := d.Resolve(0, fdsVectors); ok && offset+2*len(fdsVectorNames) <= len(file.Data) {
+		d.MarkData(offset, 2*len(fdsVectorNames))
+	}
+	return d
+}
@@ -0,0 +1,106 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFdsSide returns a disk side holding `files`,
+// each one as a file header block and a file data block.
+func newTestFdsSide(files ...FdsFile) []byte {
+	side := make([]byte, fdsInfoSize)
+	side[0] = fdsInfoBlock
+	copy(side[1:], fdsVerification)
+	copy(side[16:], "TST")
+	side[25] = 0x0F // Boot file ID
+	side = append(side, fdsFileAmountBlock, byte(len(files)))
+	for _, file := range files {
+		side = append(side, fdsFileHeaderBlock, file.Number, file.ID)
+		side = append(side, (file.Name + strings.Repeat(" ", 8))[:8]...)
+		side = append(side, byte(file.Address), byte(file.Address>>8), byte(len(file.Data)), byte(len(file.Data)>>8), byte(file.Kind))
+		side = append(side, fdsFileDataBlock)
+		side = append(side, file.Data...)
+	}
+	return append(side, make([]byte, fdsSideSize-len(side))...)
+}
+
+func TestReadFds(t *testing.T) {
+	main := FdsFile{Number: 0, ID: 1, Name: "MAIN", Address: 0x6000, Kind: FdsPrg, Data: []byte{
+		0xA9, 0x00, // 6000: LDA #$00
+		0x8D, 0x00, 0x20, // 6002: STA $2000
+		0x20, 0x09, 0x60, // 6005: JSR $6009
+		0x40,             // 6008: RTI
+		0xAD, 0x00, 0x61, // 6009: LDA $6100
+		0x60, // 600C: RTS
+	}}
+	vectors := FdsFile{Number: 1, ID: 2, Name: "VECTORS", Address: 0xDFF6, Kind: FdsPrg, Data: []byte{
+		0x08, 0x60, 0x08, 0x60, 0x08, 0x60, 0x00, 0x60, 0x08, 0x60,
+	}}
+	chr := FdsFile{Number: 2, ID: 3, Name: "CHR", Address: 0x0000, Kind: FdsChr, Data: make([]byte, 16)}
+	image := append([]byte("FDS\x1A\x02"), make([]byte, 11)...)
+	image = append(image, newTestFdsSide(main, vectors, chr)...)
+	image = append(image, newTestFdsSide()...)
+
+	assert.True(t, IsFdsFile(image))
+	assert.True(t, IsFdsFile(image[fdsHeaderSize:]))
+	assert.False(t, IsFdsFile([]byte("NES\x1A")))
+	disk, err := ReadFds(image)
+	assert.NoError(t, err)
+	assert.Len(t, disk.Sides, 2)
+	side := disk.Sides[0]
+	assert.Equal(t, "TST", side.GameName)
+	assert.Equal(t, byte(0x0F), side.BootFileID)
+	assert.Len(t, side.Files, 3)
+	assert.Equal(t, "VECTORS", side.Files[1].Name)
+	assert.Equal(t, uint16(0xDFF6), side.Files[1].Address)
+	assert.Equal(t, "CHR", side.Files[2].Kind.String())
+	assert.Equal(t, map[string]uint16{"nmi1": 0x6008, "nmi2": 0x6008, "nmi3": 0x6008, "reset": 0x6000, "irq": 0x6008}, side.Vectors())
+	assert.Empty(t, disk.Sides[1].Files)
+
+	d := side.Disassembly(side.Files[0])
+	d.Analyze()
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, ".org $6000"))
+	assert.True(t, strings.Contains(asm, "reset:\n    LDA #$00\n"))
+	assert.True(t, strings.Contains(asm, "JSR sub_6009\n"))
+	assert.True(t, strings.Contains(asm, "nmi1:\n    RTI\n"))
+	assert.True(t, strings.Contains(asm, "LDA wram_6100\n"))
+
+	_, err = ReadFds(append([]byte("FDS\x1A\x01"), make([]byte, 11+fdsInfoSize)...))
+	assert.EqualError(t, err, "side 0: missing disk info block")
+	_, err = ReadFds([]byte("FDS\x1A\x01"))
+	assert.EqualError(t, err, "truncated fwNES header")
+}
+
+func TestFdsSideEntryPoints(t *testing.T) {
+	main := FdsFile{Number: 0, ID: 1, Name: "MAIN", Address: 0x6000, Kind: FdsPrg, Data: []byte{
+		0x20, 0x00, 0x70, // 6000: JSR $7000
+		0x4C, 0x00, 0x60, // 6003: JMP $6000
+	}}
+	sub := FdsFile{Number: 1, ID: 2, Name: "SUB", Address: 0x7000, Kind: FdsPrg, Data: []byte{
+		0xA9, 0x01, // 7000: LDA #$01
+		0x4C, 0x00, 0x78, // 7002: JMP $7800
+	}}
+	tail := FdsFile{Number: 2, ID: 3, Name: "TAIL", Address: 0x7800, Kind: FdsPrg, Data: []byte{
+		0x85, 0x10, // 7800: STA $10
+		0x60, // 7802: RTS
+	}}
+	vectors := FdsFile{Number: 3, ID: 4, Name: "VECTORS", Address: 0xDFF6, Kind: FdsPrg, Data: []byte{
+		0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60, 0x00, 0x60,
+	}}
+	disk, err := ReadFds(newTestFdsSide(main, sub, tail, vectors))
+	assert.NoError(t, err)
+	side := disk.Sides[0]
+
+	d := side.Disassembly(side.Files[1])
+	d.Analyze()
+	assert.True(t, d.IsSubroutine(0))
+	assert.True(t, strings.Contains(d.String(), "sub_7000:\n    LDA #$01\n"))
+
+	d = side.Disassembly(side.Files[2])
+	d.Analyze()
+	assert.Equal(t, CodeByte, d.Kind(0))
+	assert.True(t, strings.Contains(d.String(), "loc_7800:\n    STA zp_10\n    RTS\n"))
+}
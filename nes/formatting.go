@@ -36,3 +36,10 @@ func BytesToAddress(upper, lower byte) string {
 	lowerByte := ByteToHexString(lower)
 	return fmt.Sprintf("$%s%s", lowerByte, upperByte)
 }
+
+// WordToAddress turns a 16-bit value into
+// a 6502 address.
+//  WordToAddress(0x1234) == "$1234"
+func WordToAddress(w uint16) string {
+	return BytesToAddress(byte(w), byte(w>>8))
+}
@@ -0,0 +1,297 @@
+package nes
+
+import "fmt"
+
+// RomFormat is the format of a ROM file.
+type RomFormat int
+
+const (
+	INes RomFormat = iota
+	Nes2
+	Unif
+)
+
+var romFormatNames = map[RomFormat]string{INes: "iNES", Nes2: "NES 2.0", Unif: "UNIF"}
+
+func (format RomFormat) String() string {
+	return romFormatNames[format]
+}
+
+// Mirroring is the nametable layout of a board.
+type Mirroring int
+
+const (
+	Horizontal Mirroring = iota
+	Vertical
+	SingleScreenA // All nametables at $2000
+	SingleScreenB // All nametables at $2400
+	FourScreen
+	MapperMirroring // Set by the mapper at run time
+)
+
+var mirroringNames = map[Mirroring]string{
+	Horizontal: "horizontal", Vertical: "vertical", SingleScreenA: "single screen A",
+	SingleScreenB: "single screen B", FourScreen: "four screen", MapperMirroring: "mapper controlled",
+}
+
+func (mirroring Mirroring) String() string {
+	return mirroringNames[mirroring]
+}
+
+// Header describes the board of a ROM, whatever its format.
+// RAM sizes are in bytes; NES 2.0 is the only format giving
+// all of them.
+type Header struct {
+	Format       RomFormat
+	Mapper       int // -1 for UNIF boards without iNES number
+	Submapper    int
+	Board        string // UNIF board name, e.g. "NES-SNROM"
+	Name         string // UNIF only
+	Mirroring    Mirroring
+	Battery      bool
+	Trainer      []byte // 512 bytes loaded at $7000, if any
+	PrgRamSize   int
+	PrgNvramSize int
+	ChrRamSize   int
+	ChrNvramSize int
+	Region       Region
+}
+
+// Rom is a ROM file split into its header, PRG ROM and CHR ROM.
+type Rom struct {
+	Header
+	Prg []byte
+	Chr []byte // Empty for boards with CHR RAM
+}
+
+// ReadRom parses an iNES, NES 2.0 or UNIF ROM.
+func ReadRom(data []byte) (*Rom, error) {
+	if IsUnifFile(data) {
+		return readUnif(data)
+	}
+	if err := CheckRom(data); err != nil {
+		return nil, err
+	}
+	prgRomStartIndex, prgRomSize, chrRomSize := romLayout(data)
+	chrRomStartIndex := prgRomStartIndex + prgRomSize
+	garbage := HasHeaderGarbage(data)
+	rom := &Rom{
+		Header: Header{
+			Format:  INes,
+			Mapper:  int(data[6] >> 4),
+			Battery: data[6]&0b00000010 != 0,
+			Region:  ReadRegion(data),
+		},
+		Prg: data[prgRomStartIndex:chrRomStartIndex],
+		Chr: data[chrRomStartIndex : chrRomStartIndex+chrRomSize],
+	}
+	switch {
+	case data[6]&0b00001000 != 0:
+		rom.Mirroring = FourScreen
+	case data[6]&0b00000001 != 0:
+		rom.Mirroring = Vertical
+	}
+	if data[6]&0b00000100 != 0 {
+		rom.Trainer = data[16 : 16+512]
+	}
+	if !garbage {
+		rom.Mapper |= int(data[7] & 0xF0)
+	}
+	if IsNes2File(data) {
+		rom.Format = Nes2
+		rom.Mapper |= int(data[8]&0x0F) << 8
+		rom.Submapper = int(data[8] >> 4)
+		rom.PrgRamSize, rom.PrgNvramSize = shiftSize(data[10]), shiftSize(data[10]>>4)
+		rom.ChrRamSize, rom.ChrNvramSize = shiftSize(data[11]), shiftSize(data[11]>>4)
+		return rom, nil
+	}
+	// iNES 1.0 only gives the PRG RAM size, in 8 KiB units, 0 meaning 8 KiB
+	rom.PrgRamSize = 8192
+	if !garbage && data[8] > 1 {
+		rom.PrgRamSize = int(data[8]) * 8192
+	}
+	if len(rom.Chr) == 0 {
+		rom.ChrRamSize = 8192
+	}
+	if rom.Battery {
+		rom.PrgRamSize, rom.PrgNvramSize = 0, rom.PrgRamSize
+	}
+	return rom, nil
+}
+
+// HasHeaderGarbage returns true if the bytes 7-15 of an iNES
+// header are garbage written by old tools, e.g. "DiskDude!".
+// They are then ignored, as the mapper number would be wrong.
+//  HasHeaderGarbage([]byte("NES\x1A\x02\x01\x01DiskDude!")) == true
+func HasHeaderGarbage(rom []byte) bool {
+	if !IsNesFile(rom) || IsNes2File(rom) || len(rom) < 16 {
+		return false
+	}
+	for _, b := range rom[12:16] {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// shiftSize decodes a NES 2.0 RAM size: 64 << shift bytes,
+// 0 meaning none.
+func shiftSize(shift byte) int {
+	if shift&0x0F == 0 {
+		return 0
+	}
+	return 64 << (shift & 0x0F)
+}
+
+// sizeShift encodes a NES 2.0 RAM size,
+// which must be 0 or a power of two from 128 bytes to 1 MiB.
+func sizeShift(size int) (byte, bool) {
+	if size == 0 {
+		return 0, true
+	}
+	for shift := byte(1); shift <= 0x0F; shift++ {
+		if 64<<shift == size {
+			return shift, true
+		}
+	}
+	return 0, false
+}
+
+// Encode returns the ROM as an iNES or NES 2.0 file, along with
+// what the header cannot hold in this format, e.g. a submapper
+// in iNES. The ROM is unchanged when there is no loss.
+// It fails if the mapper or the ROM sizes cannot be written.
+func (rom *Rom) Encode(format RomFormat) ([]byte, []string, error) {
+	if format != INes && format != Nes2 {
+		return nil, nil, fmt.Errorf("cannot write %s ROMs", format)
+	}
+	if rom.Mapper < 0 {
+		return nil, nil, fmt.Errorf("no iNES mapper for board '%s'", rom.Board)
+	}
+	if rom.Submapper < 0 {
+		return nil, nil, fmt.Errorf("invalid submapper %d", rom.Submapper)
+	}
+	if len(rom.Prg)%16384 != 0 || len(rom.Prg) == 0 {
+		return nil, nil, fmt.Errorf("the PRG ROM size (%d bytes) is not a multiple of 16 KiB", len(rom.Prg))
+	}
+	if len(rom.Chr)%8192 != 0 {
+		return nil, nil, fmt.Errorf("the CHR ROM size (%d bytes) is not a multiple of 8 KiB", len(rom.Chr))
+	}
+	prgUnits, chrUnits := len(rom.Prg)/16384, len(rom.Chr)/8192
+	maxUnits, maxMapper := 0xFF, 0xFF
+	if format == Nes2 {
+		maxUnits, maxMapper = 0xEFF, 0xFFF
+	}
+	if prgUnits > maxUnits || chrUnits > maxUnits {
+		return nil, nil, fmt.Errorf("the ROM is too large for %s", format)
+	}
+	if rom.Mapper > maxMapper {
+		return nil, nil, fmt.Errorf("mapper %d does not fit in %s", rom.Mapper, format)
+	}
+
+	var losses []string
+	header := make([]byte, 16)
+	copy(header, "NES\x1A")
+	header[4], header[5] = byte(prgUnits), byte(chrUnits)
+	header[6] = byte(rom.Mapper&0x0F) << 4
+	header[7] = byte(rom.Mapper & 0xF0)
+	switch rom.Mirroring {
+	case Vertical:
+		header[6] |= 0b00000001
+	case FourScreen:
+		header[6] |= 0b00001000
+	case SingleScreenA, SingleScreenB:
+		losses = append(losses, fmt.Sprintf("%s mirroring", rom.Mirroring))
+	}
+	if rom.Battery || rom.PrgNvramSize != 0 || rom.ChrNvramSize != 0 {
+		header[6] |= 0b00000010
+	}
+	if rom.Trainer != nil {
+		header[6] |= 0b00000100
+	}
+
+	if format == Nes2 {
+		header[7] |= 0b00001000
+		if rom.Submapper > 0x0F {
+			return nil, nil, fmt.Errorf("submapper %d does not fit in NES 2.0", rom.Submapper)
+		}
+		header[8] = byte(rom.Submapper)<<4 | byte(rom.Mapper>>8)
+		header[9] = byte(chrUnits>>8)<<4 | byte(prgUnits>>8)
+		sizes := []struct {
+			name string
+			size int
+		}{
+			{"PRG RAM", rom.PrgRamSize}, {"PRG NVRAM", rom.PrgNvramSize},
+			{"CHR RAM", rom.ChrRamSize}, {"CHR NVRAM", rom.ChrNvramSize},
+		}
+		for i, ram := range sizes {
+			shift, ok := sizeShift(ram.size)
+			if !ok {
+				losses = append(losses, fmt.Sprintf("%s size (%d bytes)", ram.name, ram.size))
+			}
+			header[10+i/2] |= shift << (4 * (i % 2))
+		}
+		header[12] = byte(rom.Region)
+	} else {
+		if rom.Submapper != 0 {
+			losses = append(losses, fmt.Sprintf("submapper %d", rom.Submapper))
+		}
+		// iNES only gives the size of the PRG RAM, battery-backed or not
+		prgRam := rom.PrgRamSize + rom.PrgNvramSize
+		switch {
+		case rom.PrgRamSize != 0 && rom.PrgNvramSize != 0:
+			losses = append(losses, "PRG RAM and NVRAM sizes")
+		case prgRam%8192 != 0 || prgRam > 0xFF*8192:
+			losses = append(losses, fmt.Sprintf("PRG RAM size (%d bytes)", prgRam))
+		case prgRam > 8192:
+			header[8] = byte(prgRam / 8192)
+		}
+		chrRam := 0
+		if len(rom.Chr) == 0 {
+			chrRam = 8192
+		}
+		if rom.ChrRamSize != chrRam || rom.ChrNvramSize != 0 {
+			losses = append(losses, fmt.Sprintf("CHR RAM size (%d bytes)", rom.ChrRamSize+rom.ChrNvramSize))
+		}
+		switch rom.Region {
+		case PAL:
+			header[9] = 0b00000001
+		case MultiRegion, Dendy:
+			losses = append(losses, fmt.Sprintf("%s region", rom.Region))
+		}
+	}
+
+	data := append(header, rom.Trainer...)
+	data = append(data, rom.Prg...)
+	data = append(data, rom.Chr...)
+	return data, losses, nil
+}
+
+// PrgRomReader returns a reader of the PRG ROM.
+func (rom *Rom) PrgRomReader() *PrgRomReader {
+	return NewPrgRomReader(rom.Prg)
+}
+
+// describe describes the board, given the ROM sizes.
+//  "iNES, mapper 4, 128 KiB PRG ROM, 128 KiB CHR ROM, vertical mirroring, battery"
+func (header Header) describe(prgSize, chrSize int) string {
+	board := fmt.Sprintf("mapper %d", header.Mapper)
+	if header.Format == Nes2 && header.Submapper != 0 {
+		board += fmt.Sprintf(".%d", header.Submapper)
+	}
+	if header.Board != "" {
+		board = header.Board
+	}
+	description := fmt.Sprintf("%s, %s, %d KiB PRG ROM, %d KiB CHR ROM, %s mirroring",
+		header.Format, board, prgSize/1024, chrSize/1024, header.Mirroring)
+	if header.Battery {
+		description += ", battery"
+	}
+	return description
+}
+
+// String describes the ROM.
+func (rom *Rom) String() string {
+	return rom.describe(len(rom.Prg), len(rom.Chr))
+}
@@ -0,0 +1,96 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRom(t *testing.T) {
+	data := make([]byte, 16+512+16384+8192)
+	copy(data, "NES\x1A\x01\x01\x47\x10\x00\x01")
+	data[16+512] = 0xEA
+	rom, err := ReadRom(data)
+	assert.NoError(t, err)
+	assert.Equal(t, INes, rom.Format)
+	assert.Equal(t, 0x14, rom.Mapper)
+	assert.Equal(t, Vertical, rom.Mirroring)
+	assert.True(t, rom.Battery)
+	assert.Len(t, rom.Trainer, 512)
+	assert.Equal(t, 8192, rom.PrgNvramSize)
+	assert.Equal(t, PAL, rom.Region)
+	assert.Equal(t, byte(0xEA), rom.Prg[0])
+	assert.Len(t, rom.Chr, 8192)
+
+	nes2 := make([]byte, 16+16384)
+	copy(nes2, "NES\x1A\x01\x00\x08\x08\x31\x00\x07\x09\x01")
+	rom, err = ReadRom(nes2)
+	assert.NoError(t, err)
+	assert.Equal(t, Nes2, rom.Format)
+	assert.Equal(t, 0x100, rom.Mapper)
+	assert.Equal(t, 3, rom.Submapper)
+	assert.Equal(t, FourScreen, rom.Mirroring)
+	assert.Equal(t, 8192, rom.PrgRamSize)
+	assert.Equal(t, 32768, rom.ChrRamSize)
+	assert.Equal(t, PAL, rom.Region)
+	assert.Equal(t, "NES 2.0, mapper 256.3, 16 KiB PRG ROM, 0 KiB CHR ROM, four screen mirroring", rom.String())
+
+	_, err = ReadRom(data[:100])
+	assert.Error(t, err)
+}
+
+func TestHasHeaderGarbage(t *testing.T) {
+	data := make([]byte, 16+16384)
+	copy(data, "NES\x1A\x01\x00\x01DiskDude!")
+	assert.True(t, HasHeaderGarbage(data))
+
+	rom, err := ReadRom(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, rom.Mapper)
+	assert.Equal(t, 8192, rom.PrgRamSize)
+	assert.Equal(t, NTSC, rom.Region)
+
+	copy(data[7:], make([]byte, 9))
+	assert.False(t, HasHeaderGarbage(data))
+}
+
+func TestEncode(t *testing.T) {
+	data := make([]byte, 16+16384+8192)
+	copy(data, "NES\x1A\x01\x01\x43\x10\x00\x01")
+	rom, err := ReadRom(data)
+	assert.NoError(t, err)
+
+	encoded, losses, err := rom.Encode(INes)
+	assert.NoError(t, err)
+	assert.Empty(t, losses)
+	assert.Equal(t, data, encoded)
+
+	rom.Submapper = 2
+	rom.PrgNvramSize = 32768
+	encoded, losses, err = rom.Encode(Nes2)
+	assert.NoError(t, err)
+	assert.Empty(t, losses)
+	assert.Equal(t, []byte("NES\x1A\x01\x01\x43\x18\x20\x00\x90\x00\x01\x00\x00\x00"), encoded[:16])
+	upgraded, err := ReadRom(encoded)
+	assert.NoError(t, err)
+	rom.Format = Nes2
+	assert.Equal(t, rom.Header, upgraded.Header)
+
+	_, losses, err = rom.Encode(INes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"submapper 2"}, losses)
+
+	rom.Region = Dendy
+	rom.ChrRamSize = 1000
+	_, losses, _ = rom.Encode(Nes2)
+	assert.Equal(t, []string{"CHR RAM size (1000 bytes)"}, losses)
+
+	rom.Submapper = -1
+	_, _, err = rom.Encode(Nes2)
+	assert.EqualError(t, err, "invalid submapper -1")
+
+	rom.Mapper = -1
+	rom.Board = "UNL-XYZ"
+	_, _, err = rom.Encode(Nes2)
+	assert.EqualError(t, err, "no iNES mapper for board 'UNL-XYZ'")
+}
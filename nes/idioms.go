@@ -0,0 +1,330 @@
+package nes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 16-bit idioms are short instruction sequences handling a word
+// as two bytes:
+//
+//  CLC               LDA lo            INC lo            LDA #<data
+//  LDA lo            CMP other         BNE skip          STA ptr
+//  ADC #$20          LDA lo+1          INC lo+1          LDA #>data
+//  STA lo            SBC other+1     skip:               STA ptr+1
+//  LDA lo+1
+//  ADC #$00
+//  STA lo+1
+
+// IdiomKind tells what a 16-bit idiom computes.
+type IdiomKind int
+
+const (
+	Add16        IdiomKind = iota // CLC, then ADC on both bytes
+	Sub16                         // SEC, then SBC on both bytes
+	Compare16                     // CMP on the low bytes, SBC on the high bytes
+	Increment16                   // INC lo; BNE skip; INC hi
+	Decrement16                   // LDA lo; BNE skip; DEC hi; skip: DEC lo
+	PointerSetup                  // Immediate low and high bytes stored in a pointer
+)
+
+// Operand16 is a 16-bit operand of an idiom.
+type Operand16 struct {
+	Immediate bool   // Value is a constant
+	Byte      bool   // Only the low byte is read, the high byte is 0
+	ZeroPage  bool   // The operand was written as a zero page address
+	Value     uint16 // Constant, or address of the low byte
+}
+
+// String returns the operand in assembly language.
+//  "#$0120", "word $10" or "$10" for bytes
+func (op Operand16) String() string {
+	switch {
+	case op.Immediate:
+		return "#" + WordToAddress(op.Value)
+	case op.ZeroPage && op.Value < 0x100:
+		if op.Byte {
+			return ByteToZeroPageAddress(byte(op.Value))
+		}
+		return "word " + ByteToZeroPageAddress(byte(op.Value))
+	case op.Byte:
+		return WordToAddress(op.Value)
+	}
+	return "word " + WordToAddress(op.Value)
+}
+
+// Idiom is a recognized 16-bit idiom.
+type Idiom struct {
+	Kind         IdiomKind
+	Instructions []Instruction
+	Dest         Operand16 // Word written (Add16, Sub16, Increment16, Decrement16, PointerSetup)
+	Left         Operand16 // First operand (Add16, Sub16, Compare16)
+	Right        Operand16 // Second operand (Add16, Sub16, Compare16)
+}
+
+// Offset returns the PRG ROM offset of the first instruction.
+func (idiom *Idiom) Offset() int {
+	return idiom.Instructions[0].Offset
+}
+
+// String describes the idiom.
+//  "word $10 += #$0120"
+func (idiom *Idiom) String() string {
+	switch idiom.Kind {
+	case Add16, Sub16:
+		op := "+"
+		if idiom.Kind == Sub16 {
+			op = "-"
+		}
+		if idiom.Left == idiom.Dest {
+			return fmt.Sprintf("%s %s= %s", idiom.Dest, op, idiom.Right)
+		}
+		return fmt.Sprintf("%s = %s %s %s", idiom.Dest, idiom.Left, op, idiom.Right)
+	case Compare16:
+		return fmt.Sprintf("compare %s with %s", idiom.Left, idiom.Right)
+	case Increment16:
+		return fmt.Sprintf("%s++", idiom.Dest)
+	case Decrement16:
+		return fmt.Sprintf("%s--", idiom.Dest)
+	}
+	return fmt.Sprintf("%s = %s", idiom.Dest, idiom.Right)
+}
+
+// pointerHalf is the immediate operand of a pointer setup,
+// written as #<label or #>label.
+type pointerHalf struct {
+	target int // PRG ROM offset pointed to
+	high   bool
+}
+
+// Idiom returns the idiom starting at a PRG ROM offset.
+func (d *Disassembly) Idiom(offset int) (*Idiom, bool) {
+	idiom, ok := d.idioms[offset]
+	return idiom, ok
+}
+
+// Idioms returns every idiom, sorted by PRG ROM offset.
+func (d *Disassembly) Idioms() []*Idiom {
+	idioms := make([]*Idiom, 0, len(d.idioms))
+	for _, idiom := range d.idioms {
+		idioms = append(idioms, idiom)
+	}
+	sort.Slice(idioms, func(i, j int) bool {
+		return idioms[i].Offset() < idioms[j].Offset()
+	})
+	return idioms
+}
+
+// detectIdioms recognizes the 16-bit idioms of the decoded code
+// and comments them. Pointers to the PRG ROM are labeled.
+func (d *Disassembly) detectIdioms() {
+	matchers := []func([]Instruction) (*Idiom, bool){
+		d.matchAddSub,
+		d.matchCompare,
+		d.matchIncrement,
+		d.matchDecrement,
+		d.matchPointerSetup,
+	}
+	instructions := d.Instructions()
+	for i := 0; i < len(instructions); i++ {
+		for _, match := range matchers {
+			idiom, ok := match(instructions[i:])
+			if !ok {
+				continue
+			}
+			d.idioms[idiom.Offset()] = idiom
+			d.AddComment(idiom.Offset(), idiom.String())
+			if idiom.Kind == PointerSetup {
+				d.labelPointer(idiom)
+			}
+			i += len(idiom.Instructions) - 1
+			break
+		}
+	}
+}
+
+// sequence returns the first `n` instructions of `run` if they
+// directly follow each other and only the first one is labeled.
+func (d *Disassembly) sequence(run []Instruction, n int) ([]Instruction, bool) {
+	if len(run) < n || !isStraight(run[:n]) {
+		return nil, false
+	}
+	for _, inst := range run[1:n] {
+		if _, ok := d.labels[inst.Offset]; ok {
+			return nil, false
+		}
+	}
+	return run[:n], true
+}
+
+// isDirect returns true for zero page and absolute operands.
+func isDirect(inst Instruction) bool {
+	return inst.Mode == ZeroPage || inst.Mode == Absolute
+}
+
+// directOperand returns the word whose low byte is read or written by `inst`.
+func directOperand(inst Instruction) Operand16 {
+	return Operand16{Value: inst.Operand, ZeroPage: inst.Mode == ZeroPage}
+}
+
+// operand16 returns the 16-bit operand read by two instructions
+// handling the low and high bytes.
+func operand16(lo, hi Instruction) (Operand16, bool) {
+	switch {
+	case lo.Mode == Immediate && hi.Mode == Immediate:
+		return Operand16{Immediate: true, Value: lo.Operand | hi.Operand<<8}, true
+	case isDirect(lo) && isDirect(hi) && hi.Operand == lo.Operand+1:
+		return directOperand(lo), true
+	case isDirect(lo) && hi.Mode == Immediate && hi.Operand == 0:
+		op := directOperand(lo)
+		op.Byte = true
+		return op, true
+	}
+	return Operand16{}, false
+}
+
+// overlaps returns true if a word written at `dest` partially
+// overlaps a memory operand, changing it while it is read.
+func overlaps(dest, op Operand16) bool {
+	if op.Immediate || op.Value == dest.Value {
+		return false
+	}
+	return op.Value+1 == dest.Value || dest.Value+1 == op.Value
+}
+
+// matchAddSub matches 16-bit additions and subtractions.
+// CLC or SEC may also follow the first load.
+func (d *Disassembly) matchAddSub(run []Instruction) (*Idiom, bool) {
+	seq, ok := d.sequence(run, 7)
+	if !ok {
+		return nil, false
+	}
+	flag, loLoad := seq[0], seq[1]
+	if seq[1].Code == Clc || seq[1].Code == Sec {
+		flag, loLoad = seq[1], seq[0]
+	}
+	loOp, loStore, hiLoad, hiOp, hiStore := seq[2], seq[3], seq[4], seq[5], seq[6]
+	var kind IdiomKind
+	switch {
+	case flag.Code == Clc && loOp.Mnemonic == "ADC" && hiOp.Mnemonic == "ADC":
+		kind = Add16
+	case flag.Code == Sec && loOp.Mnemonic == "SBC" && hiOp.Mnemonic == "SBC":
+		kind = Sub16
+	default:
+		return nil, false
+	}
+	if loLoad.Mnemonic != "LDA" || hiLoad.Mnemonic != "LDA" || !isStore(loStore) || !isStore(hiStore) ||
+		hiStore.Operand != loStore.Operand+1 {
+		return nil, false
+	}
+	left, okLeft := operand16(loLoad, hiLoad)
+	right, okRight := operand16(loOp, hiOp)
+	dest := directOperand(loStore)
+	if !okLeft || !okRight || overlaps(dest, left) || overlaps(dest, right) {
+		return nil, false
+	}
+	return &Idiom{Kind: kind, Instructions: seq, Dest: dest, Left: left, Right: right}, true
+}
+
+// matchCompare matches 16-bit comparisons, setting the carry
+// if the first word is greater than or equal to the second one.
+func (d *Disassembly) matchCompare(run []Instruction) (*Idiom, bool) {
+	seq, ok := d.sequence(run, 4)
+	if !ok || seq[0].Mnemonic != "LDA" || seq[1].Mnemonic != "CMP" ||
+		seq[2].Mnemonic != "LDA" || seq[3].Mnemonic != "SBC" {
+		return nil, false
+	}
+	left, okLeft := operand16(seq[0], seq[2])
+	right, okRight := operand16(seq[1], seq[3])
+	if !okLeft || !okRight || left.Immediate {
+		return nil, false
+	}
+	return &Idiom{Kind: Compare16, Instructions: seq, Left: left, Right: right}, true
+}
+
+// skips returns true if `branch` jumps right after `inst`.
+func skips(branch, inst Instruction) bool {
+	target, _ := branch.Target()
+	return branch.Code == Bne && target == inst.Address+uint16(inst.Size())
+}
+
+// matchIncrement matches 16-bit increments.
+func (d *Disassembly) matchIncrement(run []Instruction) (*Idiom, bool) {
+	seq, ok := d.sequence(run, 3)
+	if !ok || seq[0].Mnemonic != "INC" || seq[2].Mnemonic != "INC" || !isDirect(seq[0]) || !isDirect(seq[2]) ||
+		seq[2].Operand != seq[0].Operand+1 || !skips(seq[1], seq[2]) {
+		return nil, false
+	}
+	return &Idiom{Kind: Increment16, Instructions: seq, Dest: directOperand(seq[0])}, true
+}
+
+// matchDecrement matches 16-bit decrements.
+func (d *Disassembly) matchDecrement(run []Instruction) (*Idiom, bool) {
+	if len(run) < 4 {
+		return nil, false
+	}
+	seq, ok := d.sequence(run, 3)
+	if !ok || !isStraight(run[:4]) {
+		return nil, false
+	}
+	load, dec := seq[0], run[3]
+	if load.Mnemonic != "LDA" || seq[2].Mnemonic != "DEC" || dec.Mnemonic != "DEC" ||
+		!isDirect(load) || !isDirect(seq[2]) || !isDirect(dec) ||
+		dec.Operand != load.Operand || seq[2].Operand != load.Operand+1 || !skips(seq[1], seq[2]) {
+		return nil, false
+	}
+	return &Idiom{Kind: Decrement16, Instructions: run[:4], Dest: directOperand(dec)}, true
+}
+
+// pointerRegisters pairs the loads and stores of a same register.
+var pointerRegisters = map[string]string{"LDA": "STA", "LDX": "STX", "LDY": "STY"}
+
+// matchPointerSetup matches immediate pointers stored in two
+// consecutive bytes, low byte first or not.
+func (d *Disassembly) matchPointerSetup(run []Instruction) (*Idiom, bool) {
+	seq, ok := d.sequence(run, 4)
+	if !ok {
+		return nil, false
+	}
+	for i := 0; i < 4; i += 2 {
+		load, store := seq[i], seq[i+1]
+		if load.Mode != Immediate || pointerRegisters[load.Mnemonic] != store.Mnemonic || !isDirect(store) {
+			return nil, false
+		}
+	}
+	low := 0 // Index of the load of the low byte
+	switch {
+	case seq[3].Operand == seq[1].Operand+1:
+	case seq[1].Operand == seq[3].Operand+1:
+		low = 2
+	default:
+		return nil, false
+	}
+	lo, hi := seq[low], seq[2-low]
+	dest := directOperand(seq[low+1])
+	value := Operand16{Immediate: true, Value: lo.Operand | hi.Operand<<8}
+	return &Idiom{Kind: PointerSetup, Instructions: seq, Dest: dest, Right: value}, true
+}
+
+// labelPointer labels the PRG ROM location a pointer setup points
+// to, so that its immediates are written #<label and #>label.
+func (d *Disassembly) labelPointer(idiom *Idiom) {
+	bank := d.BankAt(idiom.Offset())
+	target := idiom.Right.Value
+	offset, ok := d.Resolve(bank.Index, target)
+	if !ok || d.kinds[offset] == OperandByte || d.BankAt(offset).AddressOf(offset) != target {
+		return
+	}
+	if d.kinds[offset] == CodeByte {
+		d.addAutoLabel(offset, "loc")
+	} else {
+		d.addAutoLabel(offset, "dat")
+	}
+	lo := idiom.Instructions[0]
+	hi := idiom.Instructions[2]
+	if idiom.Instructions[3].Operand == idiom.Dest.Value {
+		lo, hi = hi, lo
+	}
+	d.pointers[lo.Offset] = pointerHalf{target: offset}
+	d.pointers[hi.Offset] = pointerHalf{target: offset, high: true}
+}
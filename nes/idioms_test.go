@@ -0,0 +1,78 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestAdd16Idiom(t *testing.T) {
+	prg := testrom.Prg(
+		0x18,       // C000: CLC
+		0xA5, 0x10, // C001: LDA $10
+		0x69, 0x20, // C003: ADC #$20
+		0x85, 0x10, // C005: STA $10
+		0xA5, 0x11, // C007: LDA $11
+		0x69, 0x01, // C009: ADC #$01
+		0x85, 0x11, // C00B: STA $11
+		0x60, // C00D: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	idiom, ok := d.Idiom(0)
+	assert.True(t, ok)
+	assert.Equal(t, Add16, idiom.Kind)
+	assert.Len(t, idiom.Instructions, 7)
+	assert.Equal(t, "word $10 += #$0120", idiom.String())
+	assert.Equal(t, []string{"word $10 += #$0120"}, d.Comments(0))
+}
+
+func TestCompareAndIncrementIdioms(t *testing.T) {
+	prg := testrom.Prg(
+		0xA5, 0x10, // C000: LDA $10
+		0xC9, 0x00, // C002: CMP #$00
+		0xA5, 0x11, // C004: LDA $11
+		0xE9, 0x03, // C006: SBC #$03
+		0xB0, 0x06, // C008: BCS $C010
+		0xE6, 0x10, // C00A: INC $10
+		0xD0, 0x02, // C00C: BNE $C010
+		0xE6, 0x11, // C00E: INC $11
+		0x60, // C010: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	idiom, ok := d.Idiom(0)
+	assert.True(t, ok)
+	assert.Equal(t, "compare word $10 with #$0300", idiom.String())
+	idiom, ok = d.Idiom(0x0A)
+	assert.True(t, ok)
+	assert.Equal(t, Increment16, idiom.Kind)
+	assert.Equal(t, "word $10++", idiom.String())
+}
+
+func TestPointerSetupIdiom(t *testing.T) {
+	prg := testrom.Prg(
+		0xA9, 0x10, // C000: LDA #$10
+		0x85, 0x00, // C002: STA $00
+		0xA9, 0xC0, // C004: LDA #$C0
+		0x85, 0x01, // C006: STA $01
+		0x60, // C008: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	idiom, ok := d.Idiom(0)
+	assert.True(t, ok)
+	assert.Equal(t, PointerSetup, idiom.Kind)
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA #<dat_C010 "))
+	assert.True(t, strings.Contains(asm, "LDA #>dat_C010\n"))
+	assert.True(t, strings.Contains(asm, "dat_C010:\n"))
+}
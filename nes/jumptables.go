@@ -0,0 +1,119 @@
+package nes
+
+// Jump tables are detected from the usual dispatch idioms:
+//
+//  ASL A                     ASL A
+//  TAY                       TAX
+//  LDA table,Y               LDA table+1,X
+//  STA ptr                   PHA
+//  LDA table+1,Y             LDA table,X
+//  STA ptr+1                 PHA
+//  JMP (ptr)                 RTS  ; table entries are target-1
+//
+// as well as tables following a JSR to a "jump engine",
+// i.e. a routine pulling its return address to index a table:
+//
+//  JSR JumpEngine            JumpEngine:
+//  .word first                 ASL A
+//  .word second                TAY
+//                              PLA
+//                              STA ptr
+//                              PLA
+//                              STA ptr+1
+//                              ...
+//                              JMP (ptr)
+
+// maxJumpEngineSize bounds the number of instructions
+// decoded to recognize a jump engine.
+const maxJumpEngineSize = 32
+
+// isJumpEngine returns true if the routine at `offset` pulls its
+// return address from the stack and ends with an indirect jump.
+func (d *Disassembly) isJumpEngine(offset int) bool {
+	if engine, ok := d.jumpEngines[offset]; ok {
+		return engine
+	}
+	engine := false
+	pulls := 0
+	bank := d.BankAt(offset)
+	for i, current := 0, offset; i < maxJumpEngineSize && bank.ContainsOffset(current); i++ {
+		inst, ok := DecodeInstruction(d.prg, current, bank.AddressOf(current))
+		if !ok {
+			break
+		}
+		if inst.Code == Pla {
+			pulls++
+		}
+		if inst.Code == JmpIndirect {
+			engine = pulls >= 2
+		}
+		if inst.EndsFlow() || inst.IsBranch() || inst.IsCall() {
+			break
+		}
+		current += inst.Size()
+	}
+	d.jumpEngines[offset] = engine
+	return engine
+}
+
+// detectInlineJumpTable decodes the word table following
+// a call to a jump engine.
+func (d *Disassembly) detectInlineJumpTable(call Instruction) {
+	offset := call.Offset + call.Size()
+	targets := d.readTargets(offset, offset+1, 2, 0, true)
+	table := &Table{Offset: offset, Kind: WordTable, Targets: targets}
+	if d.AddTable(table, "jmptbl", true) {
+		d.dispatches[call.Offset] = table
+	}
+}
+
+// detectJumpTable looks for a dispatch idiom at the end of `run`,
+// a straight sequence of instructions ending with JMP (ptr) or RTS.
+func (d *Disassembly) detectJumpTable(bank Bank, run []Instruction) {
+	n := len(run)
+	switch run[n-1].Code {
+	case JmpIndirect:
+		pointer := run[n-1].Operand
+		lo, okLo := storedLoad(run, pointer)
+		hi, okHi := storedLoad(run, pointer+1)
+		if okLo && okHi {
+			d.addJumpTable(bank, run[n-1], lo, hi, 0)
+		}
+	case RtsImplied:
+		// The high byte is pushed first
+		if n >= 5 && run[n-2].Code == Pha && run[n-4].Code == Pha &&
+			run[n-3].Mnemonic == "LDA" && run[n-5].Mnemonic == "LDA" {
+			d.addJumpTable(bank, run[n-1], run[n-3], run[n-5], 1)
+		}
+	}
+}
+
+// storedLoad returns the LDA immediately preceding
+// the last STA to `address` in `run`.
+func storedLoad(run []Instruction, address uint16) (Instruction, bool) {
+	for i := len(run) - 1; i > 0; i-- {
+		inst := run[i]
+		if inst.Mnemonic == "STA" && (inst.Mode == ZeroPage || inst.Mode == Absolute) && inst.Operand == address {
+			load := run[i-1]
+			return load, load.Mnemonic == "LDA"
+		}
+	}
+	return Instruction{}, false
+}
+
+// addJumpTable decodes the jump table(s) read by two indexed loads
+// of the low and high bytes of the targets, and records the
+// instruction dispatching through them.
+func (d *Disassembly) addJumpTable(bank Bank, dispatch, lo, hi Instruction, adjust uint16) {
+	if table, ok := d.addIndexedTables(bank, lo, hi, adjust, true, "jmptbl"); ok {
+		d.dispatches[dispatch.Offset] = table
+	}
+}
+
+// Dispatch returns the jump table used by the instruction
+// at a PRG ROM offset, i.e. a JMP (indirect), an RTS or a JSR
+// to a jump engine.
+func (d *Disassembly) Dispatch(offset int) (*Table, bool) {
+	table, ok := d.dispatches[offset]
+	return table, ok
+}
@@ -0,0 +1,98 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestIndirectJumpTable(t *testing.T) {
+	prg := testrom.Prg(
+		0x0A,             // C000: ASL A
+		0xA8,             // C001: TAY
+		0xB9, 0x10, 0xC0, // C002: LDA $C010,Y
+		0x85, 0x00, // C005: STA $00
+		0xB9, 0x11, 0xC0, // C007: LDA $C011,Y
+		0x85, 0x01, // C00A: STA $01
+		0x6C, 0x00, 0x00, // C00C: JMP ($0000)
+		0xFF,
+		0x14, 0xC0, 0x15, 0xC0, // C010: .word $C014, $C015
+		0x60, // C014: RTS
+		0x60, // C015: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	table, ok := d.Table(0x10)
+	assert.True(t, ok)
+	assert.Equal(t, []uint16{0xC014, 0xC015}, table.Targets)
+	assert.Equal(t, CodeByte, d.Kind(0x14))
+	assert.Equal(t, CodeByte, d.Kind(0x15))
+
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA jmptbl_C010,Y\n"))
+	assert.True(t, strings.Contains(asm, "jmptbl_C010:\n    .word loc_C014\n    .word loc_C015\n"))
+}
+
+func TestRtsJumpTable(t *testing.T) {
+	prg := testrom.Prg(
+		0x0A,             // C000: ASL A
+		0xAA,             // C001: TAX
+		0xBD, 0x18, 0xC0, // C002: LDA $C018,X
+		0x48,             // C005: PHA
+		0xBD, 0x10, 0xC0, // C006: LDA $C010,X
+		0x48, // C009: PHA
+		0x60, // C00A: RTS
+	)
+	copy(prg[0x10:], []byte{0x1F, 0x20}) // lo bytes of $C020-1, $C021-1
+	copy(prg[0x18:], []byte{0xC0, 0xC0}) // hi bytes
+	copy(prg[0x20:], []byte{0x60, 0x60}) // C020, C021: RTS
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	// Split tables: the high bytes are not adjacent to the low ones
+	table, ok := d.Table(0x10)
+	assert.True(t, ok)
+	assert.Equal(t, LoBytesTable, table.Kind)
+	assert.Equal(t, []uint16{0xC020, 0xC021}, table.Targets)
+	assert.Equal(t, uint16(1), table.Adjust)
+	assert.True(t, strings.Contains(d.String(), ".lobytes loc_C020-1\n"))
+}
+
+func TestInlineJumpTable(t *testing.T) {
+	prg := testrom.Prg(
+		0x20, 0x10, 0xC0, // C000: JSR JumpEngine
+		0x08, 0xC0, // C003: .word $C008
+		0x09, 0xC0, // C005: .word $C009
+		0xFF,
+		0x60, // C008: RTS
+		0x60, // C009: RTS
+	)
+	copy(prg[0x10:], []byte{
+		0x0A,       // ASL A
+		0xA8,       // TAY
+		0x68,       // PLA
+		0x85, 0x04, // STA $04
+		0x68,       // PLA
+		0x85, 0x05, // STA $05
+		0xC8,       // INY
+		0xB1, 0x04, // LDA ($04),Y
+		0x85, 0x06, // STA $06
+		0xC8,       // INY
+		0xB1, 0x04, // LDA ($04),Y
+		0x85, 0x07, // STA $07
+		0x6C, 0x06, 0x00, // JMP ($0006)
+	})
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	table, ok := d.Table(0x03)
+	assert.True(t, ok)
+	assert.Equal(t, []uint16{0xC008, 0xC009}, table.Targets)
+	assert.Equal(t, CodeByte, d.Kind(0x09))
+}
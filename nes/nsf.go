@@ -0,0 +1,218 @@
+package nes
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	nsfHeaderSize = 0x80
+	nsfBankSize   = 0x1000
+	nsfBanks      = 8 // Bank registers $5FF8-$5FFF, for $8000-$FFFF
+)
+
+// Nsf is an NSF or NSFe music file: a sound driver, loaded at
+// `Load`, whose `Init` routine is called with the song number
+// in A, then `Play` at every frame.
+// See https://wiki.nesdev.com/w/index.php/NSF
+type Nsf struct {
+	Version     byte
+	Songs       byte
+	StartSong   byte // From 1
+	Load        uint16
+	Init        uint16
+	Play        uint16
+	Title       string
+	Artist      string
+	Copyright   string
+	Ripper      string   // NSFe only
+	TrackLabels []string // NSFe only
+	Banks       [nsfBanks]byte
+	Region      byte // Bit 0: PAL, bit 1: both NTSC and PAL
+	Chips       byte // Expansion sound chips
+	Data        []byte
+}
+
+// IsNsfFile returns true if `data` is an NSF file,
+// i.e. it starts with "NESM" followed by 0x1A.
+//  IsNsfFile([]byte("NESM\x1A")) == true
+func IsNsfFile(data []byte) bool {
+	return len(data) >= 5 && string(data[0:4]) == "NESM" && data[4] == 0x1A
+}
+
+// IsNsfeFile returns true if `data` is an NSFe file,
+// i.e. it starts with "NSFE".
+func IsNsfeFile(data []byte) bool {
+	return len(data) >= 4 && string(data[0:4]) == "NSFE"
+}
+
+// Bankswitched returns true if the driver uses the bank
+// registers, i.e. if any initial bank is not 0.
+func (nsf *Nsf) Bankswitched() bool {
+	return nsf.Banks != [nsfBanks]byte{}
+}
+
+// cString returns a string padded with zeros.
+func cString(data []byte) string {
+	if i := strings.IndexByte(string(data), 0); i >= 0 {
+		data = data[:i]
+	}
+	return string(data)
+}
+
+// ReadNsf parses an NSF or NSFe file.
+func ReadNsf(data []byte) (*Nsf, error) {
+	if IsNsfeFile(data) {
+		return readNsfe(data)
+	}
+	if !IsNsfFile(data) {
+		return nil, errors.New("not an NSF file")
+	}
+	if len(data) < nsfHeaderSize {
+		return nil, errors.New("truncated NSF header")
+	}
+	nsf := &Nsf{
+		Version:   data[0x05],
+		Songs:     data[0x06],
+		StartSong: data[0x07],
+		Load:      binary.LittleEndian.Uint16(data[0x08:]),
+		Init:      binary.LittleEndian.Uint16(data[0x0A:]),
+		Play:      binary.LittleEndian.Uint16(data[0x0C:]),
+		Title:     cString(data[0x0E:0x2E]),
+		Artist:    cString(data[0x2E:0x4E]),
+		Copyright: cString(data[0x4E:0x6E]),
+		Region:    data[0x7A],
+		Chips:     data[0x7B],
+		Data:      data[nsfHeaderSize:],
+	}
+	copy(nsf.Banks[:], data[0x70:0x78])
+	if len(nsf.Data) == 0 {
+		return nil, errors.New("no program data")
+	}
+	return nsf, nil
+}
+
+// readNsfe parses the chunks of an NSFe file: a 32-bit
+// length, a 4-character ID, then the data. Chunks whose ID
+// starts with a lowercase letter are optional.
+// See https://wiki.nesdev.com/w/index.php/NSFe
+func readNsfe(data []byte) (*Nsf, error) {
+	nsf := &Nsf{Version: 1}
+	info := false
+	for offset := 4; ; {
+		if offset+8 > len(data) {
+			return nil, errors.New("missing NEND chunk")
+		}
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		id := string(data[offset+4 : offset+8])
+		offset += 8
+		if length < 0 || offset+length > len(data) {
+			return nil, fmt.Errorf("truncated %s chunk", id)
+		}
+		chunk := data[offset : offset+length]
+		offset += length
+		switch id {
+		case "INFO":
+			if length < 9 {
+				return nil, errors.New("truncated INFO chunk")
+			}
+			nsf.Load = binary.LittleEndian.Uint16(chunk[0:])
+			nsf.Init = binary.LittleEndian.Uint16(chunk[2:])
+			nsf.Play = binary.LittleEndian.Uint16(chunk[4:])
+			nsf.Region, nsf.Chips, nsf.Songs = chunk[6], chunk[7], chunk[8]
+			nsf.StartSong = 1
+			if length > 9 {
+				nsf.StartSong = chunk[9] + 1
+			}
+			info = true
+		case "DATA":
+			nsf.Data = chunk
+		case "BANK":
+			copy(nsf.Banks[:], chunk)
+		case "tlbl":
+			nsf.TrackLabels = strings.Split(strings.TrimSuffix(string(chunk), "\x00"), "\x00")
+		case "auth":
+			fields := append(strings.Split(string(chunk), "\x00"), "", "", "", "")
+			nsf.Title, nsf.Artist, nsf.Copyright, nsf.Ripper = fields[0], fields[1], fields[2], fields[3]
+		case "NEND":
+			if !info || len(nsf.Data) == 0 {
+				return nil, errors.New("missing INFO or DATA chunk")
+			}
+			return nsf, nil
+		default:
+			if id[0] >= 'A' && id[0] <= 'Z' {
+				return nil, fmt.Errorf("unsupported required chunk '%s'", id)
+			}
+		}
+	}
+}
+
+// Disassembly returns a disassembly of the driver, with `init`
+// and `play` as entry points. Without bankswitching, the data
+// is mapped at the load address. Otherwise, it is split into
+// 4 KiB banks, the first one padded up to the load address;
+// the banks initially mapped are fixed at their address, the
+// others are switchable at $8000. A bank initially mapped in
+// several slots is copied after the data for each other slot.
+func (nsf *Nsf) Disassembly() *Disassembly {
+	if !nsf.Bankswitched() {
+		data := nsf.Data
+		if int(nsf.Load)+len(data) > 0x10000 {
+			data = data[:0x10000-int(nsf.Load)]
+		}
+		d := NewDisassembly(data)
+		d.Banks = []Bank{{Index: 0, Offset: 0, Size: len(data), Base: nsf.Load, Fixed: true}}
+		nsf.addEntryPoints(d)
+		return d
+	}
+
+	padding := int(nsf.Load & 0x0FFF)
+	size := (padding + len(nsf.Data) + nsfBankSize - 1) / nsfBankSize * nsfBankSize
+	prg := make([]byte, size)
+	copy(prg[padding:], nsf.Data)
+	slots := map[int]int{} // Slot of the banks initially mapped
+	for slot, bank := range nsf.Banks {
+		index := int(bank)
+		if index >= size/nsfBankSize {
+			continue
+		}
+		if _, mapped := slots[index]; mapped {
+			offset := index * nsfBankSize
+			index = len(prg) / nsfBankSize
+			prg = append(prg, prg[offset:offset+nsfBankSize]...)
+		}
+		slots[index] = slot
+	}
+	d := NewDisassembly(prg)
+	d.Banks = SplitBanks(len(prg), nsfBankSize)
+	for i := range d.Banks {
+		d.Banks[i].Base, d.Banks[i].Fixed = 0x8000, false
+		if slot, ok := slots[i]; ok {
+			d.Banks[i].Base = uint16(0x8000 + slot*nsfBankSize)
+			d.Banks[i].Fixed = true
+		}
+	}
+	if padding > 0 {
+		d.MarkData(0, padding)
+	}
+	nsf.addEntryPoints(d)
+	return d
+}
+
+// addEntryPoints adds the init and play routines as subroutines.
+func (nsf *Nsf) addEntryPoints(d *Disassembly) {
+	for _, entry := range []struct {
+		name    string
+		address uint16
+	}{{"init", nsf.Init}, {"play", nsf.Play}} {
+		if offset, ok := d.Resolve(-1, entry.address); ok {
+			if _, named := d.labels[offset]; !named {
+				d.SetLabel(offset, entry.name)
+			}
+			d.AddEntryPoint(offset, "")
+			d.subroutines[offset] = true
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package nes
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestNsf returns an NSF file with a 128-byte header.
+func newTestNsf(load, init, play uint16, banks []byte, data ...byte) []byte {
+	header := make([]byte, nsfHeaderSize)
+	copy(header, "NESM\x1A\x01\x03\x01")
+	binary.LittleEndian.PutUint16(header[0x08:], load)
+	binary.LittleEndian.PutUint16(header[0x0A:], init)
+	binary.LittleEndian.PutUint16(header[0x0C:], play)
+	copy(header[0x0E:], "Title")
+	copy(header[0x2E:], "Artist")
+	copy(header[0x70:], banks)
+	return append(header, data...)
+}
+
+func TestReadNsf(t *testing.T) {
+	data := newTestNsf(0x8000, 0x8000, 0x8005, nil,
+		0xA9, 0x0F, // 8000: LDA #$0F
+		0x8D, 0x15, 0x40, // 8002: STA $4015
+		0x60, // 8005: RTS
+	)
+	assert.True(t, IsNsfFile(data))
+	nsf, err := ReadNsf(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Title", nsf.Title)
+	assert.Equal(t, "Artist", nsf.Artist)
+	assert.Equal(t, byte(3), nsf.Songs)
+	assert.False(t, nsf.Bankswitched())
+
+	d := nsf.Disassembly()
+	d.Analyze()
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "init:\n    LDA #$0F\n    STA $4015\nplay:\n    RTS\n"))
+
+	_, err = ReadNsf(newTestNsf(0x8000, 0x8000, 0x8000, nil))
+	assert.EqualError(t, err, "no program data")
+}
+
+func TestNsfBankswitching(t *testing.T) {
+	code := make([]byte, 0x1FF0)
+	copy(code, []byte{
+		0x20, 0x00, 0x90, // 8010: JSR $9000
+		0x60, // 8013: RTS
+	})
+	copy(code[0xFF0:], []byte{
+		0x60, // 9000: RTS
+	})
+	nsf, err := ReadNsf(newTestNsf(0x8010, 0x8010, 0x8013, []byte{0, 1, 0, 0, 0, 0, 0, 0}, code...))
+	assert.NoError(t, err)
+	assert.True(t, nsf.Bankswitched())
+
+	d := nsf.Disassembly()
+	assert.Len(t, d.Banks, 8) // Bank 0 is also mapped at $A000-$FFFF
+	assert.Equal(t, Bank{Index: 1, Offset: 0x1000, Size: 0x1000, Base: 0x9000, Fixed: true}, d.Banks[1])
+	assert.Equal(t, Bank{Index: 7, Offset: 0x7000, Size: 0x1000, Base: 0xF000, Fixed: true}, d.Banks[7])
+	d.Analyze()
+	assert.True(t, d.IsSubroutine(0x1000))
+	assert.True(t, strings.Contains(d.String(), "init:\n    JSR sub_9000\n"))
+}
+
+func TestNsfDuplicateBanks(t *testing.T) {
+	code := make([]byte, 0x2000)
+	copy(code, []byte{
+		0x60, // 8000: RTS
+	})
+	copy(code[0x1000:], []byte{
+		0x20, 0x00, 0xA0, // 9000 / B000: JSR $A000
+		0x60, // 9003 / B003: RTS
+	})
+	// Bank 1 is mapped at $9000 and $B000, bank 0 at $8000 and $A000
+	nsf, err := ReadNsf(newTestNsf(0x8000, 0xB000, 0xB003, []byte{0, 1, 0, 1, 2, 3, 4, 5}, code...))
+	assert.NoError(t, err)
+
+	d := nsf.Disassembly()
+	assert.Len(t, d.Banks, 4)
+	assert.Equal(t, Bank{Index: 2, Offset: 0x2000, Size: 0x1000, Base: 0xA000, Fixed: true}, d.Banks[2])
+	assert.Equal(t, Bank{Index: 3, Offset: 0x3000, Size: 0x1000, Base: 0xB000, Fixed: true}, d.Banks[3])
+	d.Analyze()
+	label, _ := d.Label(0x3000)
+	assert.Equal(t, "init", label)
+	assert.True(t, d.IsSubroutine(0x2000))
+	assert.True(t, strings.Contains(d.String(), "init:\n    JSR sub_A000\nplay:\n    RTS\n"))
+}
+
+func TestReadNsfe(t *testing.T) {
+	chunk := func(id string, data ...byte) []byte {
+		header := binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+		return append(append(header, id...), data...)
+	}
+	data := []byte("NSFE")
+	data = append(data, chunk("INFO", 0x00, 0x80, 0x00, 0x80, 0x01, 0x80, 0x00, 0x00, 0x02, 0x01)...)
+	data = append(data, chunk("DATA", 0x60, 0x60)...)
+	data = append(data, chunk("time", 0, 0, 0, 0)...)
+	data = append(data, chunk("tlbl", []byte("Intro\x00Boss\x00")...)...)
+	data = append(data, chunk("auth", []byte("Title\x00Artist\x00(C)\x00Ripper\x00")...)...)
+	assert.True(t, IsNsfeFile(data))
+
+	nsf, err := ReadNsf(append(data, chunk("NEND")...))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x8001), nsf.Play)
+	assert.Equal(t, byte(2), nsf.Songs)
+	assert.Equal(t, byte(2), nsf.StartSong)
+	assert.Equal(t, []string{"Intro", "Boss"}, nsf.TrackLabels)
+	assert.Equal(t, "Ripper", nsf.Ripper)
+	assert.Equal(t, []byte{0x60, 0x60}, nsf.Data)
+
+	_, err = ReadNsf(append(data, chunk("ZZZZ")...))
+	assert.EqualError(t, err, "unsupported required chunk 'ZZZZ'")
+	_, err = ReadNsf(data)
+	assert.EqualError(t, err, "missing NEND chunk")
+}
@@ -0,0 +1,266 @@
+package nes
+
+// AddressingMode represents the way an instruction
+// fetches its operand.
+type AddressingMode int
+
+// Addressing modes of the 6502.
+// See https://wiki.nesdev.com/w/index.php/CPU_addressing_modes
+const (
+	Implied AddressingMode = iota
+	Accumulator
+	Immediate
+	ZeroPage
+	ZeroPageX
+	ZeroPageY
+	Absolute
+	AbsoluteX
+	AbsoluteY
+	Indirect
+	IndirectX
+	IndirectY
+	Relative
+)
+
+// OperandSize returns the number of operand bytes
+// following the opcode for a given addressing mode.
+//  Absolute.OperandSize() == 2
+func (mode AddressingMode) OperandSize() int {
+	switch mode {
+	case Implied, Accumulator:
+		return 0
+	case Absolute, AbsoluteX, AbsoluteY, Indirect:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Opcode describes an official 6502 opcode.
+type Opcode struct {
+	Mnemonic string
+	Mode     AddressingMode
+	Cycles   int // Without the page crossing and branch penalties
+}
+
+var opcodes = map[byte]Opcode{
+	// ADC
+	AdcImmediate: {"ADC", Immediate, 2},
+	AdcZeroPage:  {"ADC", ZeroPage, 3},
+	AdcZeroPageX: {"ADC", ZeroPageX, 4},
+	AdcAbsolute:  {"ADC", Absolute, 4},
+	AdcAbsoluteX: {"ADC", AbsoluteX, 4},
+	AdcAbsoluteY: {"ADC", AbsoluteY, 4},
+	AdcIndirectX: {"ADC", IndirectX, 6},
+	AdcIndirectY: {"ADC", IndirectY, 5},
+
+	// AND
+	AndImmediate: {"AND", Immediate, 2},
+	AndZeroPage:  {"AND", ZeroPage, 3},
+	AndZeroPageX: {"AND", ZeroPageX, 4},
+	AndAbsolute:  {"AND", Absolute, 4},
+	AndAbsoluteX: {"AND", AbsoluteX, 4},
+	AndAbsoluteY: {"AND", AbsoluteY, 4},
+	AndIndirectX: {"AND", IndirectX, 6},
+	AndIndirectY: {"AND", IndirectY, 5},
+
+	// ASL
+	AslImmediate: {"ASL", Accumulator, 2},
+	AslZeroPage:  {"ASL", ZeroPage, 5},
+	AslZeroPageX: {"ASL", ZeroPageX, 6},
+	AslAbsolute:  {"ASL", Absolute, 6},
+	AslAbsoluteX: {"ASL", AbsoluteX, 7},
+
+	// BIT
+	BitZeroPage: {"BIT", ZeroPage, 3},
+	BitAbsolute: {"BIT", Absolute, 4},
+
+	// Branches
+	Bpl: {"BPL", Relative, 2},
+	Bmi: {"BMI", Relative, 2},
+	Bvc: {"BVC", Relative, 2},
+	Bvs: {"BVS", Relative, 2},
+	Bcc: {"BCC", Relative, 2},
+	Bcs: {"BCS", Relative, 2},
+	Bne: {"BNE", Relative, 2},
+	Beq: {"BEQ", Relative, 2},
+
+	// BRK
+	Brk: {"BRK", Implied, 7},
+
+	// CMP
+	CmpImmediate: {"CMP", Immediate, 2},
+	CmpZeroPage:  {"CMP", ZeroPage, 3},
+	CmpZeroPageX: {"CMP", ZeroPageX, 4},
+	CmpAbsolute:  {"CMP", Absolute, 4},
+	CmpAbsoluteX: {"CMP", AbsoluteX, 4},
+	CmpAbsoluteY: {"CMP", AbsoluteY, 4},
+	CmpIndirectX: {"CMP", IndirectX, 6},
+	CmpIndirectY: {"CMP", IndirectY, 5},
+
+	// CPX
+	CpxImmediate: {"CPX", Immediate, 2},
+	CpxZeroPage:  {"CPX", ZeroPage, 3},
+	CpxAbsolute:  {"CPX", Absolute, 4},
+
+	// CPY
+	CpyImmediate: {"CPY", Immediate, 2},
+	CpyZeroPage:  {"CPY", ZeroPage, 3},
+	CpyAbsolute:  {"CPY", Absolute, 4},
+
+	// DEC
+	DecZeroPage:  {"DEC", ZeroPage, 5},
+	DecZeroPageX: {"DEC", ZeroPageX, 6},
+	DecAbsolute:  {"DEC", Absolute, 6},
+	DecAbsoluteX: {"DEC", AbsoluteX, 7},
+
+	// EOR
+	EorImmediate: {"EOR", Immediate, 2},
+	EorZeroPage:  {"EOR", ZeroPage, 3},
+	EorZeroPageX: {"EOR", ZeroPageX, 4},
+	EorAbsolute:  {"EOR", Absolute, 4},
+	EorAbsoluteX: {"EOR", AbsoluteX, 4},
+	EorAbsoluteY: {"EOR", AbsoluteY, 4},
+	EorIndirectX: {"EOR", IndirectX, 6},
+	EorIndirectY: {"EOR", IndirectY, 5},
+
+	// Processor status
+	Clc: {"CLC", Implied, 2},
+	Sec: {"SEC", Implied, 2},
+	Cli: {"CLI", Implied, 2},
+	Sei: {"SEI", Implied, 2},
+	Clv: {"CLV", Implied, 2},
+	Cld: {"CLD", Implied, 2},
+	Sed: {"SED", Implied, 2},
+
+	// INC
+	IncZeroPage:  {"INC", ZeroPage, 5},
+	IncZeroPageX: {"INC", ZeroPageX, 6},
+	IncAbsolute:  {"INC", Absolute, 6},
+	IncAbsoluteX: {"INC", AbsoluteX, 7},
+
+	// JMP
+	JmpAbsolute: {"JMP", Absolute, 3},
+	JmpIndirect: {"JMP", Indirect, 5},
+
+	// JSR
+	JsrAbsolute: {"JSR", Absolute, 6},
+
+	// LDA
+	LdaImmediate: {"LDA", Immediate, 2},
+	LdaZeroPage:  {"LDA", ZeroPage, 3},
+	LdaZeroPageX: {"LDA", ZeroPageX, 4},
+	LdaAbsolute:  {"LDA", Absolute, 4},
+	LdaAbsoluteX: {"LDA", AbsoluteX, 4},
+	LdaAbsoluteY: {"LDA", AbsoluteY, 4},
+	LdaIndirectX: {"LDA", IndirectX, 6},
+	LdaIndirectY: {"LDA", IndirectY, 5},
+
+	// LDX
+	LdxImmediate: {"LDX", Immediate, 2},
+	LdxZeroPage:  {"LDX", ZeroPage, 3},
+	LdxZeroPageY: {"LDX", ZeroPageY, 4},
+	LdxAbsolute:  {"LDX", Absolute, 4},
+	LdxAbsoluteY: {"LDX", AbsoluteY, 4},
+
+	// LDY
+	LdyImmediate: {"LDY", Immediate, 2},
+	LdyZeroPage:  {"LDY", ZeroPage, 3},
+	LdyZeroPageX: {"LDY", ZeroPageX, 4},
+	LdyAbsolute:  {"LDY", Absolute, 4},
+	LdyAbsoluteX: {"LDY", AbsoluteX, 4},
+
+	// LSR
+	LsrAccumulator: {"LSR", Accumulator, 2},
+	LsrZeroPage:    {"LSR", ZeroPage, 5},
+	LsrZeroPageX:   {"LSR", ZeroPageX, 6},
+	LsrAbsolute:    {"LSR", Absolute, 6},
+	LsrAbsoluteX:   {"LSR", AbsoluteX, 7},
+
+	// NOP
+	NopImplied: {"NOP", Implied, 2},
+
+	// ORA
+	OraImmediate: {"ORA", Immediate, 2},
+	OraZeroPage:  {"ORA", ZeroPage, 3},
+	OraZeroPageX: {"ORA", ZeroPageX, 4},
+	OraAbsolute:  {"ORA", Absolute, 4},
+	OraAbsoluteX: {"ORA", AbsoluteX, 4},
+	OraAbsoluteY: {"ORA", AbsoluteY, 4},
+	OraIndirectX: {"ORA", IndirectX, 6},
+	OraIndirectY: {"ORA", IndirectY, 5},
+
+	// Register transfers
+	Tax: {"TAX", Implied, 2},
+	Txa: {"TXA", Implied, 2},
+	Dex: {"DEX", Implied, 2},
+	Inx: {"INX", Implied, 2},
+	Tay: {"TAY", Implied, 2},
+	Tya: {"TYA", Implied, 2},
+	Dey: {"DEY", Implied, 2},
+	Iny: {"INY", Implied, 2},
+
+	// ROL
+	RolAccumulator: {"ROL", Accumulator, 2},
+	RolZeroPage:    {"ROL", ZeroPage, 5},
+	RolZeroPageX:   {"ROL", ZeroPageX, 6},
+	RolAbsolute:    {"ROL", Absolute, 6},
+	RolAbsoluteX:   {"ROL", AbsoluteX, 7},
+
+	// ROR
+	RorAccumulator: {"ROR", Accumulator, 2},
+	RorZeroPage:    {"ROR", ZeroPage, 5},
+	RorZeroPageX:   {"ROR", ZeroPageX, 6},
+	RorAbsolute:    {"ROR", Absolute, 6},
+	RorAbsoluteX:   {"ROR", AbsoluteX, 7},
+
+	// RTI
+	RtiImplied: {"RTI", Implied, 6},
+
+	// RTS
+	RtsImplied: {"RTS", Implied, 6},
+
+	// SBC
+	SbcImmediate: {"SBC", Immediate, 2},
+	SbcZeroPage:  {"SBC", ZeroPage, 3},
+	SbcZeroPageX: {"SBC", ZeroPageX, 4},
+	SbcAbsolute:  {"SBC", Absolute, 4},
+	SbcAbsoluteX: {"SBC", AbsoluteX, 4},
+	SbcAbsoluteY: {"SBC", AbsoluteY, 4},
+	SbcIndirectX: {"SBC", IndirectX, 6},
+	SbcIndirectY: {"SBC", IndirectY, 5},
+
+	// STA
+	StaZeroPage:  {"STA", ZeroPage, 3},
+	StaZeroPageX: {"STA", ZeroPageX, 4},
+	StaAbsolute:  {"STA", Absolute, 4},
+	StaAbsoluteX: {"STA", AbsoluteX, 5},
+	StaAbsoluteY: {"STA", AbsoluteY, 5},
+	StaIndirectX: {"STA", IndirectX, 6},
+	StaIndirectY: {"STA", IndirectY, 6},
+
+	// Stack
+	Txs: {"TXS", Implied, 2},
+	Tsx: {"TSX", Implied, 2},
+	Pha: {"PHA", Implied, 3},
+	Pla: {"PLA", Implied, 4},
+	Php: {"PHP", Implied, 3},
+	Plp: {"PLP", Implied, 4},
+
+	// STX
+	StxZeroPage:  {"STX", ZeroPage, 3},
+	StxZeroPageY: {"STX", ZeroPageY, 4},
+	StxAbsolute:  {"STX", Absolute, 4},
+
+	// STY
+	StyZeroPage:  {"STY", ZeroPage, 3},
+	StyZeroPageX: {"STY", ZeroPageX, 4},
+	StyAbsolute:  {"STY", Absolute, 4},
+}
+
+// LookupOpcode returns the description of an opcode.
+// The second value is false for unofficial opcodes.
+func LookupOpcode(b byte) (Opcode, bool) {
+	op, ok := opcodes[b]
+	return op, ok
+}
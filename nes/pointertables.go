@@ -0,0 +1,55 @@
+package nes
+
+// detectPointerTables looks for pointers loaded
+// from indexed tables into consecutive bytes:
+//
+//  LDA table_lo,X            LDA table,Y
+//  STA ptr                   STA ptr
+//  LDA table_hi,X            LDA table+1,Y
+//  STA ptr+1                 STA ptr+1
+//
+// The high byte may be loaded first. Jump tables are
+// already known at this point and are left untouched.
+func (d *Disassembly) detectPointerTables() {
+	instructions := d.Instructions()
+	for i := 0; i+3 < len(instructions); i++ {
+		sequence := instructions[i : i+4]
+		if !isStraight(sequence) {
+			continue
+		}
+		first, second := sequence[0], sequence[2]
+		firstStore, secondStore := sequence[1], sequence[3]
+		if !isIndexedLoad(first) || !isIndexedLoad(second) || !isStore(firstStore) || !isStore(secondStore) {
+			continue
+		}
+		bank := d.BankAt(first.Offset)
+		switch {
+		case secondStore.Operand == firstStore.Operand+1:
+			d.addIndexedTables(bank, first, second, 0, false, "ptrtbl")
+		case firstStore.Operand == secondStore.Operand+1:
+			d.addIndexedTables(bank, second, first, 0, false, "ptrtbl")
+		}
+	}
+}
+
+// isStraight returns true if the instructions
+// directly follow each other.
+func isStraight(instructions []Instruction) bool {
+	for i := 1; i < len(instructions); i++ {
+		previous := instructions[i-1]
+		if previous.Offset+previous.Size() != instructions[i].Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// isIndexedLoad returns true for LDA absolute,X and LDA absolute,Y.
+func isIndexedLoad(inst Instruction) bool {
+	return inst.Mnemonic == "LDA" && (inst.Mode == AbsoluteX || inst.Mode == AbsoluteY)
+}
+
+// isStore returns true for STA zero page and STA absolute.
+func isStore(inst Instruction) bool {
+	return inst.Mnemonic == "STA" && (inst.Mode == ZeroPage || inst.Mode == Absolute)
+}
@@ -0,0 +1,56 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestSplitPointerTables(t *testing.T) {
+	prg := testrom.Prg(
+		0xBD, 0x10, 0xC0, // C000: LDA $C010,X
+		0x85, 0x00, // C003: STA $00
+		0xBD, 0x12, 0xC0, // C005: LDA $C012,X
+		0x85, 0x01, // C008: STA $01
+		0x60, // C00A: RTS
+	)
+	copy(prg[0x10:], []byte{0x20, 0x24, 0xC0, 0xC0})
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	table, ok := d.Table(0x10)
+	assert.True(t, ok)
+	assert.Equal(t, LoBytesTable, table.Kind)
+	assert.Equal(t, []uint16{0xC020, 0xC024}, table.Targets)
+	table, ok = d.Table(0x12)
+	assert.True(t, ok)
+	assert.Equal(t, HiBytesTable, table.Kind)
+
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA ptrtbl_lo_C010,X\n"))
+	assert.True(t, strings.Contains(asm, "ptrtbl_hi_C012:\n    .hibytes dat_C020\n    .hibytes dat_C024\n"))
+	assert.True(t, strings.Contains(asm, "dat_C024:\n    .byte $FF"))
+}
+
+func TestWordPointerTable(t *testing.T) {
+	prg := testrom.Prg(
+		0xB9, 0x11, 0xC0, // C000: LDA $C011,Y
+		0x85, 0x01, // C003: STA $01
+		0xB9, 0x10, 0xC0, // C005: LDA $C010,Y
+		0x85, 0x00, // C008: STA $00
+		0x60, // C00A: RTS
+	)
+	copy(prg[0x10:], []byte{0x20, 0xC0, 0x30, 0xC0, 0x00, 0x00})
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	table, ok := d.Table(0x10)
+	assert.True(t, ok)
+	assert.Equal(t, WordTable, table.Kind)
+	assert.Equal(t, []uint16{0xC020, 0xC030}, table.Targets)
+	assert.True(t, strings.Contains(d.String(), "LDA ptrtbl_C010+1,Y\n"))
+}
@@ -0,0 +1,23 @@
+package nes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadChrRom(t *testing.T) {
+	rom := make([]byte, 16+16384+8192)
+	copy(rom, "NES\x1A\x01\x01")
+	rom[16+16384] = 0x42
+	chr, err := ReadChrRom(rom)
+	assert.NoError(t, err)
+	assert.Len(t, chr, 8192)
+	assert.Equal(t, byte(0x42), chr[0])
+	_, err = ReadChrRom(rom[:16+16384])
+	assert.Error(t, err)
+	rom[5] = 0
+	chr, err = ReadChrRom(rom[:16+16384])
+	assert.NoError(t, err)
+	assert.Empty(t, chr)
+}
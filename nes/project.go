@@ -0,0 +1,210 @@
+package nes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProjectVersion is the version of the project file format.
+const ProjectVersion = 1
+
+// Project gathers what the user knows about a ROM, so that it is
+// not lost between runs. It is stored as JSON; maps are written
+// with sorted keys so that the file diffs well.
+// Locations are written as by Disassembly.Location: "$C012" in
+// fixed banks and RAM, "$02:8012" in switchable banks.
+//  {
+//    "version": 1,
+//    "labels": {"$0086": "player_x", "$C012": "init"},
+//    "comments": {"$C012": "Clears the nametables"},
+//    "data": {"$C100": {"type": "text", "size": 12}},
+//    "entryPoints": ["$02:8000"]
+//  }
+type Project struct {
+	Version     int                 `json:"version"`
+	BankSize    int                 `json:"bankSize,omitempty"` // Splits the PRG ROM into banks of this size
+	Banks       []BankHint          `json:"banks,omitempty"`
+	Labels      map[string]string   `json:"labels,omitempty"` // RAM locations name variables
+	Comments    map[string]string   `json:"comments,omitempty"`
+	Data        map[string]DataHint `json:"data,omitempty"`
+	EntryPoints []string            `json:"entryPoints,omitempty"`
+}
+
+// BankHint tells where a PRG ROM bank is mapped.
+type BankHint struct {
+	Bank  int    `json:"bank"`
+	Base  string `json:"base"` // CPU address, e.g. "$C000"
+	Fixed bool   `json:"fixed,omitempty"`
+}
+
+// DataHint tells what a PRG ROM range holds.
+type DataHint struct {
+	Type string `json:"type"`           // "bytes", "words", "text" or "code"
+	Size int    `json:"size,omitempty"` // In bytes, unused for code
+}
+
+// NewProject returns an empty project.
+func NewProject() *Project {
+	return &Project{
+		Version:  ProjectVersion,
+		Labels:   map[string]string{},
+		Comments: map[string]string{},
+		Data:     map[string]DataHint{},
+	}
+}
+
+// ReadProject reads a project file. Unknown fields are errors,
+// to catch typos.
+func ReadProject(r io.Reader) (*Project, error) {
+	project := NewProject()
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(project); err != nil {
+		return nil, err
+	}
+	if project.Version < 1 || project.Version > ProjectVersion {
+		return nil, fmt.Errorf("unsupported project version %d", project.Version)
+	}
+	return project, nil
+}
+
+// Write writes the project as indented JSON.
+func (project *Project) Write(w io.Writer) error {
+	sort.Strings(project.EntryPoints)
+	sort.Slice(project.Banks, func(i, j int) bool {
+		return project.Banks[i].Bank < project.Banks[j].Bank
+	})
+	content, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+// SetLabel names a location; an empty name removes the label.
+func (project *Project) SetLabel(location, name string) {
+	if name == "" {
+		delete(project.Labels, location)
+		return
+	}
+	project.Labels[location] = name
+}
+
+// SetComment comments a location; an empty comment removes it.
+func (project *Project) SetComment(location, comment string) {
+	if comment == "" {
+		delete(project.Comments, location)
+		return
+	}
+	project.Comments[location] = comment
+}
+
+// parseHexAddress parses "$C012".
+func parseHexAddress(s string) (uint16, bool) {
+	if !strings.HasPrefix(s, "$") {
+		return 0, false
+	}
+	address, err := strconv.ParseUint(s[1:], 16, 16)
+	return uint16(address), err == nil
+}
+
+// ParseLocation returns the PRG ROM offset of a location
+// written as by Location: "$C012" or "$02:8012".
+func (d *Disassembly) ParseLocation(location string) (int, bool) {
+	if i := strings.Index(location, ":"); i >= 0 {
+		bank, err := strconv.ParseUint(strings.TrimPrefix(location[:i], "$"), 16, 8)
+		address, ok := parseHexAddress("$" + location[i+1:])
+		if err != nil || !ok || int(bank) >= len(d.Banks) || !d.Banks[bank].Contains(address) {
+			return 0, false
+		}
+		return d.Banks[bank].OffsetOf(address), true
+	}
+	address, ok := parseHexAddress(location)
+	if !ok {
+		return 0, false
+	}
+	return d.Resolve(-1, address)
+}
+
+// Apply applies the project to a disassembly, before it is analyzed.
+func (project *Project) Apply(d *Disassembly) error {
+	if project.BankSize > 0 {
+		d.Banks = SplitBanks(len(d.prg), project.BankSize)
+	}
+	for _, hint := range project.Banks {
+		base, ok := parseHexAddress(hint.Base)
+		if hint.Bank < 0 || hint.Bank >= len(d.Banks) || !ok {
+			return fmt.Errorf("invalid bank hint %d at '%s'", hint.Bank, hint.Base)
+		}
+		d.Banks[hint.Bank].Base = base
+		d.Banks[hint.Bank].Fixed = hint.Fixed
+	}
+
+	locate := func(what, location string) (int, error) {
+		offset, ok := d.ParseLocation(location)
+		if !ok {
+			return 0, fmt.Errorf("%s: unknown location '%s'", what, location)
+		}
+		return offset, nil
+	}
+	for location, name := range project.Labels {
+		if address, ok := parseHexAddress(location); ok && d.isRAM(address) {
+			d.SetVariableName(address, name)
+			continue
+		}
+		offset, err := locate("label "+name, location)
+		if err != nil {
+			return err
+		}
+		d.SetLabel(offset, name)
+	}
+	for location, comment := range project.Comments {
+		offset, err := locate("comment", location)
+		if err != nil {
+			return err
+		}
+		d.AddComment(offset, comment)
+	}
+	locations := make([]string, 0, len(project.Data))
+	for location := range project.Data {
+		locations = append(locations, location)
+	}
+	sort.Strings(locations)
+	for _, location := range locations {
+		hint := project.Data[location]
+		offset, err := locate(hint.Type, location)
+		if err != nil {
+			return err
+		}
+		switch hint.Type {
+		case "bytes":
+			d.MarkData(offset, hint.Size)
+		case "words":
+			table := &Table{Offset: offset, Kind: WordTable}
+			for i := 0; i+1 < hint.Size && offset+i+1 < len(d.prg); i += 2 {
+				table.Targets = append(table.Targets, uint16(d.prg[offset+i])|uint16(d.prg[offset+i+1])<<8)
+			}
+			d.AddTable(table, "wordtbl", false)
+		case "text":
+			d.MarkText(offset, hint.Size)
+		case "code":
+			d.AddEntryPoint(offset, "")
+		default:
+			return fmt.Errorf("unknown data type '%s' at '%s'", hint.Type, location)
+		}
+	}
+	for _, location := range project.EntryPoints {
+		offset, err := locate("entry point", location)
+		if err != nil {
+			return err
+		}
+		d.AddEntryPoint(offset, "")
+		d.subroutines[offset] = true
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package nes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestProject(t *testing.T) {
+	prg := testrom.Prg(
+		0xA5, 0x86, // C000: LDA $86
+		0x60,       // C002: RTS
+		0xA9, 0x00, // C003: LDA #$00
+		0x60, // C005: RTS
+	)
+	copy(prg[0x10:], "GAME OVER\x00")
+	copy(prg[0x20:], []byte{0x03, 0xC0, 0x10, 0xC0})
+	project, err := ReadProject(strings.NewReader(`{
+  "version": 1,
+  "labels": {"$0086": "player_x", "$C003": "clear"},
+  "comments": {"$C000": "Reads the position"},
+  "data": {
+    "$C010": {"type": "text", "size": 10},
+    "$C020": {"type": "words", "size": 4}
+  },
+  "entryPoints": ["$C003"]
+}`))
+	assert.NoError(t, err)
+	d := NewDisassembly(prg)
+	assert.NoError(t, project.Apply(d))
+	d.AddVectors()
+	d.Analyze()
+
+	assert.True(t, d.IsSubroutine(0x03))
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA player_x                 ; Reads the position\n"))
+	assert.True(t, strings.Contains(asm, "clear:\n    LDA #$00\n"))
+	assert.True(t, strings.Contains(asm, "    .byte \"GAME OVER\",$00\n"))
+	assert.True(t, strings.Contains(asm, "wordtbl_C020:\n    .word clear\n    .word dat_C010\n"))
+
+	var out bytes.Buffer
+	project.SetLabel("$C003", "")
+	project.SetComment("$02:8000", "Switchable")
+	assert.NoError(t, project.Write(&out))
+	assert.Equal(t, `{
+  "version": 1,
+  "labels": {
+    "$0086": "player_x"
+  },
+  "comments": {
+    "$02:8000": "Switchable",
+    "$C000": "Reads the position"
+  },
+  "data": {
+    "$C010": {
+      "type": "text",
+      "size": 10
+    },
+    "$C020": {
+      "type": "words",
+      "size": 4
+    }
+  },
+  "entryPoints": [
+    "$C003"
+  ]
+}
+`, out.String())
+}
+
+func TestProjectErrors(t *testing.T) {
+	_, err := ReadProject(strings.NewReader(`{"version": 2}`))
+	assert.EqualError(t, err, "unsupported project version 2")
+	_, err = ReadProject(strings.NewReader(`{"version": 1, "label": {}}`))
+	assert.Error(t, err)
+
+	project, err := ReadProject(strings.NewReader(`{"version": 1, "comments": {"$02:8000": "Bank 2"}}`))
+	assert.NoError(t, err)
+	assert.EqualError(t, project.Apply(NewDisassembly(testrom.Prg())), "comment: unknown location '$02:8000'")
+}
+
+func TestProjectBanks(t *testing.T) {
+	project := NewProject()
+	project.BankSize = 0x2000
+	project.Banks = []BankHint{{Bank: 6, Base: "$C000", Fixed: true}}
+	d := NewDisassembly(make([]byte, 0x10000))
+	assert.NoError(t, project.Apply(d))
+	assert.Len(t, d.Banks, 8)
+	assert.Equal(t, uint16(0xE000), d.Banks[7].Base)
+	offset, ok := d.ParseLocation("$C012")
+	assert.True(t, ok)
+	assert.Equal(t, 0xC012, offset)
+	offset, ok = d.ParseLocation("$02:8012")
+	assert.True(t, ok)
+	assert.Equal(t, 0x4012, offset)
+	assert.Equal(t, "$02:8012", d.Location(offset))
+}
@@ -0,0 +1,77 @@
+package nes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minRelativeLength is the minimum length of a word searched by
+// RelativeSearch; shorter words match almost anywhere.
+const minRelativeLength = 3
+
+// RelativeMatch is a sequence of bytes whose differences are
+// those between the letters of a word.
+type RelativeMatch struct {
+	Offset int
+	Base   byte // Value of 'A', or of 'a' for a lowercase word
+}
+
+// RelativeSearch finds a word in data encoded with an unknown
+// table, assuming its letters are stored in alphabetical order:
+// "SWORD" matches $1C,$20,$18,$1B,$0D with 'A' = $0A.
+func RelativeSearch(data []byte, word string) ([]RelativeMatch, error) {
+	first, err := relativeFirstLetter(word)
+	if err != nil {
+		return nil, err
+	}
+	var matches []RelativeMatch
+	for offset := 0; offset+len(word) <= len(data); offset++ {
+		base := int(data[offset]) - int(word[0]-first)
+		if base < 0 || base+25 > 0xFF {
+			continue
+		}
+		matched := true
+		for i := 1; i < len(word) && matched; i++ {
+			matched = int(data[offset+i]) == base+int(word[i]-first)
+		}
+		if matched {
+			matches = append(matches, RelativeMatch{Offset: offset, Base: byte(base)})
+		}
+	}
+	return matches, nil
+}
+
+// relativeFirstLetter returns 'A' or 'a', depending on the case
+// of the word, which must only hold letters of the same case.
+func relativeFirstLetter(word string) (byte, error) {
+	if len(word) < minRelativeLength {
+		return 0, fmt.Errorf("'%s' is too short, at least %d letters are needed", word, minRelativeLength)
+	}
+	first := byte('A')
+	if word[0] >= 'a' && word[0] <= 'z' {
+		first = 'a'
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < first || word[i] > first+25 {
+			return 0, fmt.Errorf("'%s' must only hold letters of the same case", word)
+		}
+	}
+	return first, nil
+}
+
+// RelativeTable writes a draft table file mapping the letters
+// from `base`, to be completed by hand and read by ParseTextTable.
+//  ; Draft table, 'A' = $0A
+//  0A=A
+//  0B=B
+func RelativeTable(base byte, lowercase bool) string {
+	first := byte('A')
+	if lowercase {
+		first = 'a'
+	}
+	lines := []string{fmt.Sprintf("; Draft table, '%c' = $%s", first, ByteToHexString(base))}
+	for i := 0; i < 26 && int(base)+i <= 0xFF; i++ {
+		lines = append(lines, fmt.Sprintf("%s=%c", ByteToHexString(base+byte(i)), first+byte(i)))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,30 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativeSearch(t *testing.T) {
+	data := []byte{0x00, 0x1C, 0x20, 0x18, 0x1B, 0x0D, 0x00, 0x73, 0x77, 0x6F, 0x72, 0x64}
+	matches, err := RelativeSearch(data, "SWORD")
+	assert.NoError(t, err)
+	assert.Equal(t, []RelativeMatch{{Offset: 1, Base: 0x0A}, {Offset: 7, Base: 0x61}}, matches)
+	matches, err = RelativeSearch(data[:10], "sword")
+	assert.NoError(t, err)
+	assert.Equal(t, []RelativeMatch{{Offset: 1, Base: 0x0A}}, matches)
+
+	_, err = RelativeSearch(data, "AX")
+	assert.EqualError(t, err, "'AX' is too short, at least 3 letters are needed")
+	_, err = RelativeSearch(data, "Sword")
+	assert.EqualError(t, err, "'Sword' must only hold letters of the same case")
+
+	table, err := ParseTextTable(strings.NewReader(RelativeTable(0x0A, false)))
+	assert.NoError(t, err)
+	text, n := table.Decode(data[1:])
+	assert.Equal(t, "SWORD", text)
+	assert.Equal(t, 5, n)
+	assert.True(t, strings.HasPrefix(RelativeTable(0xF0, true), "; Draft table, 'a' = $F0\nF0=a\n"))
+}
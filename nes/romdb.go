@@ -0,0 +1,215 @@
+package nes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xmlDatabase is either a No-Intro (Logiqx) DAT file, whose games
+// list the hashes of headerless ROMs, or a NesCartDB XML file, whose
+// games list cartridges with their board.
+type xmlDatabase struct {
+	Games []struct {
+		Name   string `xml:"name,attr"`
+		Region string `xml:"region,attr"`
+		Roms   []struct {
+			Crc  string `xml:"crc,attr"`
+			Sha1 string `xml:"sha1,attr"`
+		} `xml:"rom"`
+		Cartridges []struct {
+			System string `xml:"system,attr"`
+			Crc    string `xml:"crc,attr"`
+			Sha1   string `xml:"sha1,attr"`
+			Board  struct {
+				Type   string   `xml:"type,attr"`
+				Mapper string   `xml:"mapper,attr"`
+				Wram   []xmlRam `xml:"wram"`
+				Vram   []xmlRam `xml:"vram"`
+				Pad    *struct {
+					H int `xml:"h,attr"`
+					V int `xml:"v,attr"`
+				} `xml:"pad"`
+			} `xml:"board"`
+		} `xml:"cartridge"`
+	} `xml:"game"`
+}
+
+// xmlRam is a RAM chip of a NesCartDB board.
+type xmlRam struct {
+	Size    string `xml:"size,attr"`
+	Battery int    `xml:"battery,attr"`
+}
+
+// DbEntry is a known dump.
+type DbEntry struct {
+	Title  string
+	Region string  // e.g. "USA"
+	Header *Header // The correct NES 2.0 header, nil if unknown
+	Crc32  uint32  // Of the PRG ROM followed by the CHR ROM
+	Sha1   string  // Lowercase hexadecimal
+}
+
+// RomDb is a database of known dumps, indexed by hash.
+type RomDb struct {
+	Entries []*DbEntry
+	bySha1  map[string]*DbEntry
+	byCrc32 map[uint32]*DbEntry
+}
+
+// ReadRomDb parses a No-Intro DAT file or a NesCartDB XML file.
+// See https://datomatic.no-intro.org and https://nescartdb.com
+func ReadRomDb(reader io.Reader) (*RomDb, error) {
+	var database xmlDatabase
+	if err := xml.NewDecoder(reader).Decode(&database); err != nil {
+		return nil, err
+	}
+	db := &RomDb{bySha1: map[string]*DbEntry{}, byCrc32: map[uint32]*DbEntry{}}
+	for _, game := range database.Games {
+		title, region := game.Name, game.Region
+		// No-Intro names end with the region, e.g. "Tetris (USA)"
+		if i := strings.Index(title, " ("); i > 0 && region == "" {
+			region = strings.SplitN(title[i+2:], ")", 2)[0]
+			title = title[:i]
+		}
+		for _, rom := range game.Roms {
+			if err := db.add(&DbEntry{Title: title, Region: region}, rom.Crc, rom.Sha1); err != nil {
+				return nil, fmt.Errorf("%s: %s", game.Name, err)
+			}
+		}
+		for _, cartridge := range game.Cartridges {
+			board := cartridge.Board
+			header := &Header{Format: Nes2, Board: board.Type, Mirroring: MapperMirroring}
+			header.Mapper, _ = strconv.Atoi(board.Mapper)
+			if board.Mapper == "" {
+				header.Mapper = -1
+			}
+			if board.Pad != nil && board.Pad.H != 0 {
+				header.Mirroring = Horizontal
+			} else if board.Pad != nil && board.Pad.V != 0 {
+				header.Mirroring = Vertical
+			}
+			for _, ram := range board.Wram {
+				size := parseDbSize(ram.Size)
+				if ram.Battery != 0 {
+					header.Battery = true
+					header.PrgNvramSize += size
+				} else {
+					header.PrgRamSize += size
+				}
+			}
+			for _, ram := range board.Vram {
+				header.ChrRamSize += parseDbSize(ram.Size)
+			}
+			switch system := strings.ToUpper(cartridge.System); {
+			case strings.HasPrefix(system, "NES-PAL"):
+				header.Region = PAL
+			case system == "DENDY":
+				header.Region = Dendy
+			}
+			entry := &DbEntry{Title: title, Region: region, Header: header}
+			if err := db.add(entry, cartridge.Crc, cartridge.Sha1); err != nil {
+				return nil, fmt.Errorf("%s: %s", game.Name, err)
+			}
+		}
+	}
+	return db, nil
+}
+
+// parseDbSize parses a NesCartDB size, e.g. "8k".
+func parseDbSize(s string) int {
+	s = strings.ToLower(s)
+	if strings.HasSuffix(s, "k") {
+		size, _ := strconv.Atoi(strings.TrimSuffix(s, "k"))
+		return size * 1024
+	}
+	size, _ := strconv.Atoi(s)
+	return size
+}
+
+// add indexes an entry by its hexadecimal CRC32 and SHA-1.
+func (db *RomDb) add(entry *DbEntry, crc, sha string) error {
+	value, err := strconv.ParseUint(crc, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid CRC32 '%s'", crc)
+	}
+	entry.Crc32 = uint32(value)
+	entry.Sha1 = strings.ToLower(sha)
+	db.Entries = append(db.Entries, entry)
+	if entry.Sha1 != "" {
+		db.bySha1[entry.Sha1] = entry
+	}
+	db.byCrc32[entry.Crc32] = entry
+	return nil
+}
+
+// Lookup returns the entry matching the PRG and CHR ROM,
+// by SHA-1 or else by CRC32, whatever the header is.
+func (db *RomDb) Lookup(rom *Rom) (*DbEntry, bool) {
+	data := append(append([]byte{}, rom.Prg...), rom.Chr...)
+	sum := sha1.Sum(data)
+	if entry, ok := db.bySha1[hex.EncodeToString(sum[:])]; ok {
+		return entry, true
+	}
+	entry, ok := db.byCrc32[crc32.ChecksumIEEE(data)]
+	if ok && entry.Sha1 != "" {
+		return nil, false // CRC32 collision
+	}
+	return entry, ok
+}
+
+// Mismatch is a header field which differs from the database.
+type Mismatch struct {
+	Field    string
+	Actual   string
+	Expected string
+}
+
+func (mismatch Mismatch) String() string {
+	return fmt.Sprintf("%s is %s, expected %s", mismatch.Field, mismatch.Actual, mismatch.Expected)
+}
+
+// Check returns the header fields of a ROM which differ from the
+// database. Only NES 2.0 headers give the RAM sizes and the region.
+func (entry *DbEntry) Check(rom *Rom) []Mismatch {
+	if entry.Header == nil {
+		return nil
+	}
+	var mismatches []Mismatch
+	compare := func(field string, actual, expected interface{}) {
+		if actual != expected {
+			mismatches = append(mismatches, Mismatch{field, fmt.Sprint(actual), fmt.Sprint(expected)})
+		}
+	}
+	expected := entry.Header
+	if expected.Mapper >= 0 {
+		compare("mapper", rom.Mapper, expected.Mapper)
+	}
+	if expected.Mirroring != MapperMirroring && rom.Mirroring != MapperMirroring {
+		compare("mirroring", rom.Mirroring, expected.Mirroring)
+	}
+	compare("battery", rom.Battery, expected.Battery)
+	if rom.Format == Nes2 {
+		compare("PRG RAM", rom.PrgRamSize, expected.PrgRamSize)
+		compare("PRG NVRAM", rom.PrgNvramSize, expected.PrgNvramSize)
+		compare("CHR RAM", rom.ChrRamSize, expected.ChrRamSize)
+		compare("region", rom.Region, expected.Region)
+	}
+	return mismatches
+}
+
+// Fix returns the ROM with the header of the database,
+// keeping its trainer.
+func (entry *DbEntry) Fix(rom *Rom) *Rom {
+	if entry.Header == nil {
+		return rom
+	}
+	header := *entry.Header
+	header.Trainer = rom.Trainer
+	return &Rom{Header: header, Prg: rom.Prg, Chr: rom.Chr}
+}
@@ -0,0 +1,87 @@
+package nes
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDbRom() *Rom {
+	prg := make([]byte, 16384)
+	prg[0] = 0x78
+	return &Rom{
+		Header: Header{Format: INes, Mapper: 4, Mirroring: Vertical, PrgRamSize: 8192},
+		Prg:    prg,
+		Chr:    make([]byte, 8192),
+	}
+}
+
+func TestReadRomDb(t *testing.T) {
+	rom := newTestDbRom()
+	data := append(append([]byte{}, rom.Prg...), rom.Chr...)
+	sum := sha1.Sum(data)
+	crc := fmt.Sprintf("%08X", crc32.ChecksumIEEE(data))
+	sha := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	cartDb := `<database>
+<game name="Test" region="Europe">
+  <cartridge system="NES-PAL-B" crc="` + crc + `" sha1="` + sha + `">
+    <board type="NES-SNROM" mapper="1">
+      <prg size="16k"/>
+      <chr size="8k"/>
+      <wram size="8k" battery="1"/>
+      <pad h="1" v="0"/>
+    </board>
+  </cartridge>
+</game>
+<game name="Other"><cartridge crc="00000000"><board mapper="0"/></cartridge></game>
+</database>`
+	db, err := ReadRomDb(strings.NewReader(cartDb))
+	assert.NoError(t, err)
+	assert.Len(t, db.Entries, 2)
+	entry, ok := db.Lookup(rom)
+	assert.True(t, ok)
+	assert.Equal(t, "Test", entry.Title)
+	assert.Equal(t, "Europe", entry.Region)
+	assert.Equal(t, &Header{
+		Format: Nes2, Mapper: 1, Board: "NES-SNROM", Mirroring: Horizontal,
+		Battery: true, PrgNvramSize: 8192, Region: PAL,
+	}, entry.Header)
+	assert.Equal(t, []Mismatch{
+		{"mapper", "4", "1"},
+		{"mirroring", "vertical", "horizontal"},
+		{"battery", "false", "true"},
+	}, entry.Check(rom))
+
+	fixed := entry.Fix(rom)
+	assert.Empty(t, entry.Check(fixed))
+	assert.Equal(t, rom.Prg, fixed.Prg)
+
+	noIntro := `<?xml version="1.0"?>
+<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/dtds/datafile.dtd">
+<datafile>
+  <game name="Test (USA) (Rev 1)">
+    <rom name="Test (USA) (Rev 1).nes" size="24576" crc="` + crc + `" sha1="` + sha + `"/>
+  </game>
+</datafile>`
+	db, err = ReadRomDb(strings.NewReader(noIntro))
+	assert.NoError(t, err)
+	entry, ok = db.Lookup(rom)
+	assert.True(t, ok)
+	assert.Equal(t, "Test", entry.Title)
+	assert.Equal(t, "USA", entry.Region)
+	assert.Nil(t, entry.Header)
+	assert.Empty(t, entry.Check(rom))
+
+	rom.Prg[1] = 0xFF
+	_, ok = db.Lookup(rom)
+	assert.False(t, ok)
+
+	_, err = ReadRomDb(strings.NewReader(`<datafile><game name="X"><rom crc="XYZ"/></game></datafile>`))
+	assert.EqualError(t, err, "X: invalid CRC32 'XYZ'")
+}
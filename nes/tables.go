@@ -0,0 +1,241 @@
+package nes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TableKind tells how the entries of a table are stored.
+type TableKind int
+
+const (
+	WordTable    TableKind = iota // Little endian words (.word)
+	LoBytesTable                  // Low bytes of split tables (.lobytes)
+	HiBytesTable                  // High bytes of split tables (.hibytes)
+)
+
+// maxTableEntries bounds the length of detected tables.
+const maxTableEntries = 128
+
+// Table represents a table of pointers in the PRG ROM.
+type Table struct {
+	Offset  int // PRG ROM offset of the first entry
+	Kind    TableKind
+	Targets []uint16 // Addresses the entries point to
+	Adjust  uint16   // Difference between a target and its stored value, e.g. 1 for RTS tables
+}
+
+// Size returns the number of bytes of the table.
+func (table *Table) Size() int {
+	if table.Kind == WordTable {
+		return 2 * len(table.Targets)
+	}
+	return len(table.Targets)
+}
+
+// entrySize returns the number of bytes of an entry.
+func (table *Table) entrySize() int {
+	if table.Kind == WordTable {
+		return 2
+	}
+	return 1
+}
+
+// Table returns the table starting at a PRG ROM offset.
+func (d *Disassembly) Table(offset int) (*Table, bool) {
+	table, ok := d.tables[offset]
+	return table, ok
+}
+
+// Tables returns every table, sorted by PRG ROM offset.
+func (d *Disassembly) Tables() []*Table {
+	tables := make([]*Table, 0, len(d.tables))
+	for _, table := range d.tables {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Offset < tables[j].Offset
+	})
+	return tables
+}
+
+// AddTable registers a table: its bytes are marked as data,
+// it is labeled with `prefix` and its targets are labeled
+// too. If `code` is true, targets are added as entry points.
+// It returns false if the table is empty or overlaps code.
+func (d *Disassembly) AddTable(table *Table, prefix string, code bool) bool {
+	if len(table.Targets) == 0 {
+		return false
+	}
+	for i := table.Offset; i < table.Offset+table.Size(); i++ {
+		if d.kinds[i] != UnknownByte && d.kinds[i] != DataByte {
+			return false
+		}
+	}
+	d.MarkData(table.Offset, table.Size())
+	d.tables[table.Offset] = table
+	d.addAutoLabel(table.Offset, prefix)
+	bank := d.BankAt(table.Offset)
+	for _, target := range table.Targets {
+		offset, ok := d.Resolve(bank.Index, target)
+		if !ok {
+			continue
+		}
+		if code {
+			d.addAutoLabel(offset, "loc")
+			d.AddEntryPoint(offset, "")
+			d.subroutines[offset] = true
+		} else if d.kinds[offset] != OperandByte {
+			d.addAutoLabel(offset, "dat")
+		}
+	}
+	return true
+}
+
+// readPointer returns the pointer stored by the entry `i` of a table
+// whose low bytes start at `lo` and high bytes at `hi`.
+func (d *Disassembly) readPointer(lo, hi, step, i int) (uint16, bool) {
+	offsetLo, offsetHi := lo+i*step, hi+i*step
+	if offsetLo >= len(d.prg) || offsetHi >= len(d.prg) {
+		return 0, false
+	}
+	return uint16(d.prg[offsetLo]) | uint16(d.prg[offsetHi])<<8, true
+}
+
+// readTargets reads table entries as long as they look like pointers:
+// the table bytes must not be classified yet and the targets must
+// lie in the PRG ROM; code targets must also decode. The table ends
+// where the nearest of its targets starts. The high bytes of word tables
+// are at lo+1 and `step` is 2; for split tables `step` is 1.
+func (d *Disassembly) readTargets(lo, hi, step int, adjust uint16, code bool) []uint16 {
+	bank := d.BankAt(lo)
+	if bank.Index < 0 || !bank.ContainsOffset(hi) {
+		return nil
+	}
+	var targets []uint16
+	targetOffsets := map[int]bool{}
+	nearest := -1
+	for i := 0; i < maxTableEntries; i++ {
+		offsetLo, offsetHi := lo+i*step, hi+i*step
+		if !bank.ContainsOffset(offsetLo) || !bank.ContainsOffset(offsetHi) {
+			break
+		}
+		if step == 1 && ((lo < hi && offsetLo >= hi) || (hi < lo && offsetHi >= lo)) {
+			break
+		}
+		if (nearest > lo && offsetLo >= nearest) || targetOffsets[offsetHi] {
+			// Tables are usually followed by what they point to
+			break
+		}
+		if !d.isFree(offsetLo, i) || !d.isFree(offsetHi, i) {
+			break
+		}
+		pointer, _ := d.readPointer(lo, hi, step, i)
+		target := pointer + adjust
+		offset, ok := d.Resolve(bank.Index, target)
+		if !ok || d.kinds[offset] == OperandByte {
+			break
+		}
+		if (offset >= lo && offset <= offsetLo) || (offset >= hi && offset <= offsetHi) {
+			// Points inside the table itself
+			break
+		}
+		if code {
+			if d.kinds[offset] == DataByte {
+				break
+			}
+			if _, ok := DecodeInstruction(d.prg, offset, target); !ok {
+				break
+			}
+		}
+		targetOffsets[offset] = true
+		if offset > lo && (nearest < 0 || offset < nearest) {
+			nearest = offset
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// isFree returns true if a table can use the byte at `offset`:
+// it must not be classified, nor labeled unless it is the first entry.
+func (d *Disassembly) isFree(offset, entry int) bool {
+	if d.kinds[offset] != UnknownByte {
+		return false
+	}
+	_, labeled := d.labels[offset]
+	return entry == 0 || !labeled
+}
+
+// addIndexedTables decodes the table(s) read by two indexed loads of
+// the low and high bytes of pointers. Adjacent loads (table,X and
+// table+1,X) read a word table, other ones read split tables.
+// It returns the word table or the table of low bytes.
+func (d *Disassembly) addIndexedTables(bank Bank, lo, hi Instruction, adjust uint16, code bool, prefix string) (*Table, bool) {
+	if lo.Mode != hi.Mode || (lo.Mode != AbsoluteX && lo.Mode != AbsoluteY) {
+		return nil, false
+	}
+	offsetLo, okLo := d.Resolve(bank.Index, lo.Operand)
+	offsetHi, okHi := d.Resolve(bank.Index, hi.Operand)
+	if !okLo || !okHi {
+		return nil, false
+	}
+	if hi.Operand == lo.Operand+1 {
+		targets := d.readTargets(offsetLo, offsetHi, 2, adjust, code)
+		table := &Table{Offset: offsetLo, Kind: WordTable, Targets: targets, Adjust: adjust}
+		return table, d.AddTable(table, prefix, code)
+	}
+	targets := d.readTargets(offsetLo, offsetHi, 1, adjust, code)
+	table := &Table{Offset: offsetLo, Kind: LoBytesTable, Targets: targets, Adjust: adjust}
+	if !d.AddTable(table, prefix+"_lo", code) {
+		return nil, false
+	}
+	d.AddTable(&Table{Offset: offsetHi, Kind: HiBytesTable, Targets: targets, Adjust: adjust}, prefix+"_hi", code)
+	return table, true
+}
+
+// tableAt returns the table containing a PRG ROM offset.
+func (d *Disassembly) tableAt(offset int) (*Table, bool) {
+	for _, table := range d.tables {
+		if offset >= table.Offset && offset < table.Offset+table.Size() {
+			return table, true
+		}
+	}
+	return nil, false
+}
+
+// tableLines returns the listing lines of a table, one entry per line.
+func (d *Disassembly) tableLines(table *Table) []Line {
+	bank := d.BankAt(table.Offset)
+	var directive string
+	switch table.Kind {
+	case WordTable:
+		directive = ".word"
+	case LoBytesTable:
+		directive = ".lobytes"
+	case HiBytesTable:
+		directive = ".hibytes"
+	}
+	var lines []Line
+	for i, target := range table.Targets {
+		offset := table.Offset + i*table.entrySize()
+		value := WordToAddress(target - table.Adjust)
+		if targetOffset, ok := d.Resolve(bank.Index, target); ok && d.kinds[targetOffset] != OperandByte {
+			if label, ok := d.labels[targetOffset]; ok {
+				value = label
+				if table.Adjust != 0 {
+					value = fmt.Sprintf("%s-%d", label, table.Adjust)
+				}
+			}
+		}
+		lines = append(lines, Line{
+			Offset:       offset,
+			Address:      bank.AddressOf(offset),
+			Label:        d.labels[offset],
+			Text:         fmt.Sprintf("%s %s", directive, value),
+			Comment:      joinComments(d.comments[offset]),
+			LabelComment: d.xrefComment(offset),
+		})
+	}
+	return lines
+}
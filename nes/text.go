@@ -0,0 +1,302 @@
+package nes
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// TextTable maps byte sequences to text, as the table files
+// (*.tbl) used for ROM hacking:
+//  41=A              One byte
+//  8081=the          Several bytes
+//  /FF=[END]         End of string
+//  *FE               Line break
+//  F0=[wait]         Control codes are plain entries
+// Blank lines, @table lines and ';' comments are ignored.
+type TextTable struct {
+	entries   map[string]textToken // By byte sequence
+	maxLength int
+}
+
+// textToken is the text of a table entry.
+type textToken struct {
+	text    string
+	end     bool // Ends a string
+	newline bool
+}
+
+// minStringLength is the minimum number of characters of a string
+// found by FindStrings.
+const minStringLength = 4
+
+// maxTextBytes is the number of bytes of a text line in the listing.
+const maxTextBytes = 16
+
+// ASCIITextTable returns a table of the printable ASCII characters.
+func ASCIITextTable() *TextTable {
+	table := &TextTable{entries: map[string]textToken{}, maxLength: 1}
+	for b := 0x20; b < 0x7F; b++ {
+		table.entries[string([]byte{byte(b)})] = textToken{text: string(rune(b))}
+	}
+	return table
+}
+
+// ParseTextTable reads a table file.
+func ParseTextTable(r io.Reader) (*TextTable, error) {
+	table := &TextTable{entries: map[string]textToken{}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || text[0] == ';' || text[0] == '@' {
+			continue
+		}
+		var token textToken
+		switch text[0] {
+		case '/':
+			token.end = true
+			text = text[1:]
+		case '*':
+			token.newline = true
+			token.text = "\n"
+			text = text[1:]
+		}
+		code, value := text, ""
+		if i := strings.Index(text, "="); i >= 0 {
+			code, value = text[:i], text[i+1:]
+		} else if !token.end && !token.newline {
+			return nil, fmt.Errorf("line %d: expected 'XX=text', got '%s'", line, text)
+		}
+		bytes, err := hex.DecodeString(strings.TrimSpace(code))
+		if err != nil || len(bytes) == 0 {
+			return nil, fmt.Errorf("line %d: invalid hex sequence '%s'", line, code)
+		}
+		if value != "" {
+			token.text = value
+		}
+		table.entries[string(bytes)] = token
+		if len(bytes) > table.maxLength {
+			table.maxLength = len(bytes)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(table.entries) == 0 {
+		return nil, fmt.Errorf("empty text table")
+	}
+	return table, nil
+}
+
+// token returns the longest entry matching the start of `data`
+// and its length in bytes.
+func (table *TextTable) token(data []byte) (textToken, int, bool) {
+	n := table.maxLength
+	if len(data) < n {
+		n = len(data)
+	}
+	for ; n > 0; n-- {
+		if token, ok := table.entries[string(data[:n])]; ok {
+			return token, n, true
+		}
+	}
+	return textToken{}, 0, false
+}
+
+// Decode decodes `data` up to an end of string, included, or up to
+// the first byte without entry. It returns the text and the number
+// of bytes decoded.
+func (table *TextTable) Decode(data []byte) (string, int) {
+	var builder strings.Builder
+	n := 0
+	for n < len(data) {
+		token, size, ok := table.token(data[n:])
+		if !ok {
+			break
+		}
+		builder.WriteString(token.text)
+		n += size
+		if token.end {
+			break
+		}
+	}
+	return builder.String(), n
+}
+
+// isWord returns true for the entries holding letters or digits,
+// as opposed to punctuation and control codes such as [wait].
+func (token textToken) isWord() bool {
+	if token.end || token.newline || strings.HasPrefix(token.text, "[") || strings.HasPrefix(token.text, "{") || strings.HasPrefix(token.text, "<") {
+		return false
+	}
+	return strings.IndexFunc(token.text, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// TextString is a string found in the PRG ROM.
+type TextString struct {
+	Offset int // PRG ROM offset
+	Length int // In bytes
+	Text   string
+}
+
+// SetTextTable sets the table decoding strings. Strings are
+// detected during the analysis once a table is set.
+func (d *Disassembly) SetTextTable(table *TextTable) {
+	d.textTable = table
+}
+
+// FindStrings returns the strings stored in the bytes which
+// are not code: runs of at least `minLength` characters, mostly
+// letters and digits, ending with an end of string or before a
+// byte without entry, a label or code. Without text table,
+// strings are searched in ASCII.
+func (d *Disassembly) FindStrings(minLength int) []TextString {
+	table := d.textTable
+	if table == nil {
+		table = ASCIITextTable()
+	}
+	var found []TextString
+	for offset := 0; offset < len(d.prg); {
+		length, words, characters := 0, 0, 0
+		var text strings.Builder
+		for i := offset; i < len(d.prg) && d.isTextByte(i, i == offset); {
+			token, size, ok := table.token(d.prg[i:])
+			if !ok || !d.isTextRange(i, size) {
+				break
+			}
+			text.WriteString(token.text)
+			length += size
+			characters++
+			if token.isWord() {
+				words++
+			}
+			i += size
+			if token.end {
+				break
+			}
+		}
+		if words >= minLength && 2*words >= characters {
+			found = append(found, TextString{Offset: offset, Length: length, Text: text.String()})
+			offset += length
+			continue
+		}
+		offset++
+	}
+	return found
+}
+
+// isTextByte returns true if a byte may be part of a string
+// starting at or before it.
+func (d *Disassembly) isTextByte(offset int, first bool) bool {
+	if d.kinds[offset] == CodeByte || d.kinds[offset] == OperandByte {
+		return false
+	}
+	if _, ok := d.tableAt(offset); ok {
+		return false
+	}
+	if first {
+		return true
+	}
+	_, labeled := d.labels[offset]
+	return !labeled && d.BankAt(offset) == d.BankAt(offset-1)
+}
+
+// isTextRange returns true if every byte of an entry may be text.
+func (d *Disassembly) isTextRange(offset, size int) bool {
+	for i := 1; i < size; i++ {
+		if offset+i >= len(d.prg) || !d.isTextByte(offset+i, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// detectStrings marks the strings found with the text table
+// in the bytes left unknown by the analysis.
+func (d *Disassembly) detectStrings() {
+	for _, s := range d.FindStrings(minStringLength) {
+		unknown := true
+		for i := s.Offset; i < s.Offset+s.Length; i++ {
+			unknown = unknown && d.kinds[i] == UnknownByte
+		}
+		if _, ok := d.texts[s.Offset]; !ok && unknown {
+			d.MarkText(s.Offset, s.Length)
+			d.addAutoLabel(s.Offset, "str")
+		}
+	}
+}
+
+// textLines writes a string as .byte directives of up to
+// maxTextBytes bytes. Without text table, printable ASCII
+// characters are quoted; otherwise, the decoded text is
+// written in comment, lines ending between entries.
+//  .byte "GAME OVER",$00
+//  .byte $10,$0A,$16,$0E    ; "GAME"
+func (d *Disassembly) textLines(first Line, length int) []Line {
+	var lines []Line
+	line := first
+	for start, end := first.Offset, first.Offset+length; start < end; {
+		size := 0
+		var text strings.Builder
+		for start+size < end && size < maxTextBytes {
+			n := 1
+			if d.textTable != nil {
+				token, tokenSize, ok := d.textTable.token(d.prg[start+size : end])
+				if ok && size+tokenSize > maxTextBytes && size > 0 {
+					break
+				}
+				if ok {
+					text.WriteString(token.text)
+					n = tokenSize
+				}
+			}
+			size += n
+		}
+		data := d.prg[start : start+size]
+		if d.textTable == nil {
+			line.Text = formatASCII(data)
+		} else {
+			values := make([]string, len(data))
+			for i, b := range data {
+				values[i] = fmt.Sprintf("$%s", ByteToHexString(b))
+			}
+			line.Text = fmt.Sprintf(".byte %s", strings.Join(values, ","))
+			line.Comment = joinComments(append(d.comments[start], fmt.Sprintf("%q", text.String())))
+		}
+		lines = append(lines, line)
+		start += size
+		line = Line{Offset: start, Address: first.Address + uint16(start-first.Offset)}
+	}
+	return lines
+}
+
+// formatASCII writes bytes as a .byte directive,
+// quoting the printable ASCII characters.
+func formatASCII(data []byte) string {
+	var values []string
+	quoted := false
+	for _, b := range data {
+		if b >= 0x20 && b < 0x7F && b != '"' {
+			if !quoted {
+				values = append(values, `"`)
+				quoted = true
+			}
+			values[len(values)-1] += string(rune(b))
+			continue
+		}
+		if quoted {
+			values[len(values)-1] += `"`
+			quoted = false
+		}
+		values = append(values, fmt.Sprintf("$%s", ByteToHexString(b)))
+	}
+	if quoted {
+		values[len(values)-1] += `"`
+	}
+	return fmt.Sprintf(".byte %s", strings.Join(values, ","))
+}
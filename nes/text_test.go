@@ -0,0 +1,67 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+const testTextTable = `; Super Mario Bros.
+@table
+0A=A
+0E=E
+10=G
+16=M
+18=O
+1B=R
+1F=V
+24= 
+2829=the
+/FF=[END]
+*FE
+`
+
+func TestParseTextTable(t *testing.T) {
+	table, err := ParseTextTable(strings.NewReader(testTextTable))
+	assert.NoError(t, err)
+	text, n := table.Decode([]byte{0x2B, 0x28, 0x29, 0x24, 0x10, 0x0A, 0x16, 0x0E, 0xFE, 0xFF, 0x0A})
+	assert.Equal(t, "", text)
+	assert.Equal(t, 0, n)
+	text, n = table.Decode([]byte{0x28, 0x29, 0x24, 0x10, 0x0A, 0x16, 0x0E, 0xFE, 0xFF, 0x0A})
+	assert.Equal(t, "the GAME\n[END]", text)
+	assert.Equal(t, 9, n)
+
+	_, err = ParseTextTable(strings.NewReader("0A=A\n0B\n"))
+	assert.EqualError(t, err, "line 2: expected 'XX=text', got '0B'")
+	_, err = ParseTextTable(strings.NewReader("0G=A\n"))
+	assert.EqualError(t, err, "line 1: invalid hex sequence '0G'")
+	_, err = ParseTextTable(strings.NewReader("; Empty\n"))
+	assert.EqualError(t, err, "empty text table")
+}
+
+func TestStrings(t *testing.T) {
+	prg := testrom.Prg(
+		0xAD, 0x10, 0xC0, // C000: LDA $C010
+		0x60, // C003: RTS
+	)
+	copy(prg[0x10:], []byte{0x10, 0x0A, 0x16, 0x0E, 0x24, 0x18, 0x1F, 0x0E, 0x1B, 0xFF})
+	copy(prg[0x30:], "PRESS START\x00")
+
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+	assert.Equal(t, []TextString{{Offset: 0x30, Length: 11, Text: "PRESS START"}}, d.FindStrings(4))
+
+	table, err := ParseTextTable(strings.NewReader(testTextTable))
+	assert.NoError(t, err)
+	d = NewDisassembly(prg)
+	d.SetTextTable(table)
+	d.AddVectors()
+	d.Analyze()
+	assert.Equal(t, []TextString{{Offset: 0x10, Length: 10, Text: "GAME OVER[END]"}}, d.FindStrings(4))
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA str_C010\n"))
+	assert.True(t, strings.Contains(asm, "str_C010:\n    .byte $10,$0A,$16,$0E,$24,$18,$1F,$0E,$1B,$FF ; \"GAME OVER[END]\"\n"))
+}
@@ -0,0 +1,241 @@
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValueSet is a set of byte values.
+type ValueSet [4]uint64
+
+// Add adds a value to the set.
+func (set *ValueSet) Add(b byte) {
+	set[b>>6] |= 1 << (b & 63)
+}
+
+// Contains returns true if `b` is in the set.
+func (set ValueSet) Contains(b byte) bool {
+	return set[b>>6]&(1<<(b&63)) != 0
+}
+
+// Len returns the number of values in the set.
+func (set ValueSet) Len() int {
+	return bits.OnesCount64(set[0]) + bits.OnesCount64(set[1]) +
+		bits.OnesCount64(set[2]) + bits.OnesCount64(set[3])
+}
+
+// Values returns the values of the set in ascending order.
+func (set ValueSet) Values() []byte {
+	var values []byte
+	for i := 0; i < 256; i++ {
+		if set.Contains(byte(i)) {
+			values = append(values, byte(i))
+		}
+	}
+	return values
+}
+
+// String returns the values of the set, or their range
+// if there are too many of them.
+//  "$00,$01,$02" or "$00-$7F (96 values)"
+func (set ValueSet) String() string {
+	values := set.Values()
+	if len(values) > 4 {
+		return fmt.Sprintf("$%s-$%s (%d values)",
+			ByteToHexString(values[0]), ByteToHexString(values[len(values)-1]), len(values))
+	}
+	hexValues := make([]string, len(values))
+	for i, value := range values {
+		hexValues[i] = fmt.Sprintf("$%s", ByteToHexString(value))
+	}
+	return strings.Join(hexValues, ",")
+}
+
+// TracePoint identifies an executed instruction.
+// Bank is -1 when the trace log doesn't tell it.
+type TracePoint struct {
+	Bank    int
+	Address uint16
+}
+
+// TraceStats gathers what has been observed
+// when executing an instruction.
+type TraceStats struct {
+	Count           int
+	Bytes           []byte
+	A, X, Y         ValueSet
+	IndirectTargets []TracePoint // Destinations of JMP (indirect)
+}
+
+// Trace represents an emulator trace log.
+type Trace struct {
+	Points map[TracePoint]*TraceStats
+}
+
+var (
+	// FCEUX: "$C012: A9 10     LDA #$10   A:00 X:00 Y:00 S:FD P:nvUbdIzc"
+	// FCEUX 2.2+: "A:00 X:00 Y:00 S:FD P:nvUbdIzc  $07:C012:A9 10  LDA #$10"
+	// Mesen: "C012  A9 10     LDA #$10   A:00 X:00 Y:00 P:24 SP:FD"
+	traceLineRegexp = regexp.MustCompile(`(?:^\s*\$?|\s\$)(?:([0-9A-Fa-f]{2}):)?([0-9A-Fa-f]{4})(?::\s*|\s+)((?:[0-9A-Fa-f]{2}(?:\s+|$)){1,3})`)
+	traceRegsRegexp = regexp.MustCompile(`\b([AXY]):\$?([0-9A-Fa-f]{2})\b`)
+)
+
+// ParseTrace reads a FCEUX or Mesen trace log.
+// Lines that are not instructions (frame markers,
+// comments, ...) are ignored.
+func ParseTrace(r io.Reader) (*Trace, error) {
+	trace := &Trace{Points: map[TracePoint]*TraceStats{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var previous *TraceStats
+	for scanner.Scan() {
+		line := scanner.Text()
+		match := traceLineRegexp.FindStringSubmatch(line)
+		location := traceLineRegexp.FindStringIndex(line)
+		if match == nil {
+			continue
+		}
+		point := TracePoint{Bank: -1}
+		if match[1] != "" {
+			bank, _ := strconv.ParseUint(match[1], 16, 8)
+			point.Bank = int(bank)
+		}
+		address, _ := strconv.ParseUint(match[2], 16, 16)
+		point.Address = uint16(address)
+		var bytes []byte
+		for _, field := range strings.Fields(match[3]) {
+			b, _ := strconv.ParseUint(field, 16, 8)
+			bytes = append(bytes, byte(b))
+		}
+		if op, ok := LookupOpcode(bytes[0]); ok && len(bytes) > 1+op.Mode.OperandSize() {
+			bytes = bytes[:1+op.Mode.OperandSize()]
+		}
+
+		if previous != nil && previous.Bytes[0] == JmpIndirect {
+			previous.addIndirectTarget(point)
+		}
+
+		stats, ok := trace.Points[point]
+		if !ok {
+			stats = &TraceStats{Bytes: bytes}
+			trace.Points[point] = stats
+		}
+		stats.Count++
+		for _, reg := range traceRegsRegexp.FindAllStringSubmatch(line[:location[0]]+" "+line[location[1]:], -1) {
+			value, _ := strconv.ParseUint(reg[2], 16, 8)
+			switch reg[1] {
+			case "A":
+				stats.A.Add(byte(value))
+			case "X":
+				stats.X.Add(byte(value))
+			case "Y":
+				stats.Y.Add(byte(value))
+			}
+		}
+		previous = stats
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+func (stats *TraceStats) addIndirectTarget(target TracePoint) {
+	for _, known := range stats.IndirectTargets {
+		if known == target {
+			return
+		}
+	}
+	stats.IndirectTargets = append(stats.IndirectTargets, target)
+}
+
+// comment summarizes the stats, e.g.
+//  "trace: 12x A=$10 X=$00,$01"
+func (stats *TraceStats) comment() string {
+	parts := []string{fmt.Sprintf("trace: %dx", stats.Count)}
+	for _, reg := range []struct {
+		name   string
+		values ValueSet
+	}{{"A", stats.A}, {"X", stats.X}, {"Y", stats.Y}} {
+		if reg.values.Len() > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%s", reg.name, reg.values))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// locate returns the PRG ROM offset of a trace point. Without bank
+// information, the bank is guessed by comparing the logged bytes.
+func (d *Disassembly) locate(point TracePoint, bytes []byte) (int, bool) {
+	if point.Bank >= 0 && point.Bank < len(d.Banks) && d.Banks[point.Bank].Contains(point.Address) {
+		return d.Banks[point.Bank].OffsetOf(point.Address), true
+	}
+	if offset, ok := d.Resolve(-1, point.Address); ok {
+		return offset, true
+	}
+	found := -1
+	for _, bank := range d.Banks {
+		if !bank.Contains(point.Address) {
+			continue
+		}
+		offset := bank.OffsetOf(point.Address)
+		if offset+len(bytes) > len(d.prg) || string(d.prg[offset:offset+len(bytes)]) != string(bytes) {
+			continue
+		}
+		if found >= 0 {
+			// Ambiguous
+			return 0, false
+		}
+		found = offset
+	}
+	return found, found >= 0
+}
+
+// ApplyTrace adds the executed instructions of a trace as entry
+// points, comments them with the observed register values and
+// labels the destinations of indirect jumps.
+func (d *Disassembly) ApplyTrace(trace *Trace) {
+	points := make([]TracePoint, 0, len(trace.Points))
+	for point := range trace.Points {
+		points = append(points, point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Address != points[j].Address {
+			return points[i].Address < points[j].Address
+		}
+		return points[i].Bank < points[j].Bank
+	})
+	for _, point := range points {
+		stats := trace.Points[point]
+		offset, ok := d.locate(point, stats.Bytes)
+		if !ok {
+			continue
+		}
+		d.AddEntryPoint(offset, "")
+		d.AddComment(offset, stats.comment())
+		var targets []string
+		for _, target := range stats.IndirectTargets {
+			var targetBytes []byte
+			if targetStats, ok := trace.Points[target]; ok {
+				targetBytes = targetStats.Bytes
+			}
+			targetOffset, ok := d.locate(target, targetBytes)
+			if !ok {
+				targets = append(targets, WordToAddress(target.Address))
+				continue
+			}
+			d.addAutoLabel(targetOffset, "loc")
+			d.AddEntryPoint(targetOffset, "")
+			targets = append(targets, d.labels[targetOffset])
+		}
+		if len(targets) > 0 {
+			d.AddComment(offset, fmt.Sprintf("jumps to %s", strings.Join(targets, ", ")))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+const testTrace = `Log Start
+$C000: A2 00     LDX #$00                   A:00 X:00 Y:00 S:FD P:nvUbdIzc
+$C002: 6C 00 02  JMP ($0200) = $C010        A:00 X:00 Y:00 S:FD P:nvUbdIZc
+A:00 X:00 Y:00 S:FD P:nvUbdIZc  $C010:E8        INX
+C000  A2 00     LDX #$00                   A:00 X:01 Y:00 P:24 SP:FD
+`
+
+func TestParseTrace(t *testing.T) {
+	trace, err := ParseTrace(strings.NewReader(testTrace))
+	assert.Nil(t, err)
+	assert.Len(t, trace.Points, 3)
+
+	stats := trace.Points[TracePoint{Bank: -1, Address: 0xC000}]
+	assert.Equal(t, 2, stats.Count)
+	assert.Equal(t, []byte{0xA2, 0x00}, stats.Bytes)
+	assert.Equal(t, []byte{0x00, 0x01}, stats.X.Values())
+
+	stats = trace.Points[TracePoint{Bank: -1, Address: 0xC002}]
+	assert.Equal(t, []TracePoint{{Bank: -1, Address: 0xC010}}, stats.IndirectTargets)
+}
+
+func TestApplyTrace(t *testing.T) {
+	prg := testrom.Prg(0xA2, 0x00, 0x6C, 0x00, 0x02)
+	prg[0x10] = 0xE8
+	trace, _ := ParseTrace(strings.NewReader(testTrace))
+	d := NewDisassembly(prg)
+	d.ApplyTrace(trace)
+	d.Analyze()
+
+	assert.Equal(t, CodeByte, d.Kind(0x10))
+	label, _ := d.Label(0x10)
+	assert.Equal(t, "loc_C010", label)
+	assert.Equal(t, []string{"trace: 2x A=$00 X=$00,$01 Y=$00"}, d.Comments(0x00))
+	assert.Equal(t, []string{"trace: 1x A=$00 X=$00 Y=$00", "jumps to loc_C010"}, d.Comments(0x02))
+}
+
+func TestValueSet(t *testing.T) {
+	var set ValueSet
+	set.Add(0x10)
+	set.Add(0xFF)
+	set.Add(0x10)
+	assert.Equal(t, 2, set.Len())
+	assert.Equal(t, "$10,$FF", set.String())
+	for i := 0; i < 10; i++ {
+		set.Add(byte(i))
+	}
+	assert.Equal(t, "$00-$FF (12 values)", set.String())
+}
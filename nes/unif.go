@@ -0,0 +1,109 @@
+package nes
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const unifHeaderSize = 32
+
+// unifMappers are the iNES mappers of the common UNIF boards,
+// without their "NES-" or "HVC-" prefix.
+var unifMappers = map[string]int{
+	"NROM": 0, "NROM-128": 0, "NROM-256": 0, "RROM": 0, "RROM-128": 0,
+	"SAROM": 1, "SBROM": 1, "SCROM": 1, "SEROM": 1, "SGROM": 1, "SKROM": 1,
+	"SLROM": 1, "SL1ROM": 1, "SNROM": 1, "SOROM": 1, "SUROM": 1, "SXROM": 1,
+	"UNROM": 2, "UOROM": 2,
+	"CNROM": 3,
+	"TBROM": 4, "TEROM": 4, "TFROM": 4, "TGROM": 4, "TKROM": 4, "TLROM": 4,
+	"TL1ROM": 4, "TR1ROM": 4, "TSROM": 4, "TVROM": 4, "HKROM": 4,
+	"EKROM": 5, "ELROM": 5, "ETROM": 5, "EWROM": 5,
+	"AMROM": 7, "ANROM": 7, "AOROM": 7,
+	"PNROM": 9,
+	"CPROM": 13,
+	"BNROM": 34,
+	"GNROM": 66, "MHROM": 66,
+}
+
+// UnifMapper returns the iNES mapper of a UNIF board name.
+//  UnifMapper("NES-SNROM") == 1, true
+func UnifMapper(board string) (int, bool) {
+	name := strings.TrimPrefix(strings.TrimPrefix(board, "NES-"), "HVC-")
+	mapper, ok := unifMappers[name]
+	return mapper, ok
+}
+
+// IsUnifFile returns true if `data` is a UNIF ROM,
+// i.e. it starts with "UNIF".
+//  IsUnifFile([]byte("UNIF")) == true
+func IsUnifFile(data []byte) bool {
+	return len(data) >= 4 && string(data[0:4]) == "UNIF"
+}
+
+// readUnif parses the chunks of a UNIF ROM, following its
+// 32-byte header: a 4-character ID, a 32-bit length, then the
+// data. PRG0-PRGF and CHR0-CHRF are concatenated in order.
+// See https://wiki.nesdev.com/w/index.php/UNIF
+func readUnif(data []byte) (*Rom, error) {
+	if len(data) < unifHeaderSize {
+		return nil, errors.New("truncated UNIF header")
+	}
+	rom := &Rom{Header: Header{Format: Unif, Mapper: -1, Mirroring: MapperMirroring}}
+	var prg, chr [16][]byte
+	for offset := unifHeaderSize; offset < len(data); {
+		if offset+8 > len(data) {
+			return nil, errors.New("truncated chunk header")
+		}
+		id := string(data[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		offset += 8
+		if length < 0 || offset+length > len(data) {
+			return nil, fmt.Errorf("truncated %s chunk", id)
+		}
+		chunk := data[offset : offset+length]
+		offset += length
+		switch {
+		case id == "MAPR":
+			rom.Board = cString(chunk)
+		case id == "NAME":
+			rom.Name = cString(chunk)
+		case id == "MIRR" && length > 0 && chunk[0] <= byte(MapperMirroring):
+			rom.Mirroring = Mirroring(chunk[0])
+		case id == "BATR":
+			rom.Battery = true
+		case id == "TVCI" && length > 0 && chunk[0] <= 2:
+			rom.Region = []Region{NTSC, PAL, MultiRegion}[chunk[0]]
+		case strings.HasPrefix(id, "PRG") || strings.HasPrefix(id, "CHR"):
+			var index int
+			if _, err := fmt.Sscanf(id[3:], "%X", &index); err != nil || index > 0xF {
+				continue
+			}
+			if id[0] == 'P' {
+				prg[index] = chunk
+			} else {
+				chr[index] = chunk
+			}
+		}
+	}
+	for i := range prg {
+		rom.Prg = append(rom.Prg, prg[i]...)
+		rom.Chr = append(rom.Chr, chr[i]...)
+	}
+	if len(rom.Prg) == 0 {
+		return nil, errors.New("no PRG ROM")
+	}
+	if mapper, ok := UnifMapper(rom.Board); ok {
+		rom.Mapper = mapper
+	}
+	// As for iNES, assume 8 KiB of RAM
+	rom.PrgRamSize = 8192
+	if rom.Battery {
+		rom.PrgRamSize, rom.PrgNvramSize = 0, rom.PrgRamSize
+	}
+	if len(rom.Chr) == 0 {
+		rom.ChrRamSize = 8192
+	}
+	return rom, nil
+}
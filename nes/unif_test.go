@@ -0,0 +1,71 @@
+package nes
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unifChunk returns a UNIF chunk.
+func unifChunk(id string, data []byte) []byte {
+	chunk := make([]byte, 8, 8+len(data))
+	copy(chunk, id)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	return append(chunk, data...)
+}
+
+func newTestUnif(chunks ...[]byte) []byte {
+	data := make([]byte, unifHeaderSize)
+	copy(data, "UNIF")
+	data[4] = 7
+	for _, chunk := range chunks {
+		data = append(data, chunk...)
+	}
+	return data
+}
+
+func TestReadUnif(t *testing.T) {
+	data := newTestUnif(
+		unifChunk("MAPR", []byte("NES-SNROM\x00")),
+		unifChunk("NAME", []byte("Test\x00")),
+		unifChunk("PRG1", []byte{0x02}),
+		unifChunk("PRG0", []byte{0x01}),
+		unifChunk("MIRR", []byte{1}),
+		unifChunk("BATR", []byte{1}),
+		unifChunk("TVCI", []byte{1}),
+		unifChunk("READ", []byte("Comments")),
+	)
+	assert.True(t, IsUnifFile(data))
+	assert.False(t, IsUnifFile([]byte("NES\x1A")))
+
+	rom, err := ReadRom(data)
+	assert.NoError(t, err)
+	assert.Equal(t, Unif, rom.Format)
+	assert.Equal(t, "NES-SNROM", rom.Board)
+	assert.Equal(t, 1, rom.Mapper)
+	assert.Equal(t, "Test", rom.Name)
+	assert.Equal(t, []byte{0x01, 0x02}, rom.Prg)
+	assert.Empty(t, rom.Chr)
+	assert.Equal(t, 8192, rom.ChrRamSize)
+	assert.Equal(t, Vertical, rom.Mirroring)
+	assert.True(t, rom.Battery)
+	assert.Equal(t, 8192, rom.PrgNvramSize)
+	assert.Equal(t, PAL, rom.Region)
+	assert.Equal(t, "UNIF, NES-SNROM, 0 KiB PRG ROM, 0 KiB CHR ROM, vertical mirroring, battery", rom.String())
+
+	_, err = ReadRom(newTestUnif(unifChunk("MAPR", []byte("UNL-XYZ\x00"))))
+	assert.EqualError(t, err, "no PRG ROM")
+
+	_, err = ReadRom(append(newTestUnif(), "PRG0\x10\x00\x00\x00"...))
+	assert.EqualError(t, err, "truncated PRG0 chunk")
+}
+
+func TestUnifMapper(t *testing.T) {
+	mapper, ok := UnifMapper("HVC-TLROM")
+	assert.True(t, ok)
+	assert.Equal(t, 4, mapper)
+
+	_, ok = UnifMapper("UNL-XYZ")
+	assert.False(t, ok)
+}
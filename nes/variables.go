@@ -0,0 +1,246 @@
+package nes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variable is a RAM location referenced by the code,
+// in internal RAM ($0000-$07FF) or in WRAM ($6000-$7FFF).
+type Variable struct {
+	Address uint16
+	Size    int  // 1, or 2 for words and pointers
+	Pointer bool // Read through (zp),Y, (zp,X), JMP (indirect) or set up as a pointer
+	Name    string
+}
+
+// ramSegments are the listing segments holding the variables.
+var ramSegments = []struct {
+	name  string
+	start uint16
+	end   uint16
+}{
+	{"ZEROPAGE", 0x0000, 0x00FF},
+	{"BSS", 0x0100, 0x07FF},
+	{"WRAM", 0x6000, 0x7FFF},
+}
+
+// IsRAM returns true for internal RAM and WRAM addresses.
+func IsRAM(address uint16) bool {
+	return address < 0x0800 || (address >= 0x6000 && address < 0x8000)
+}
+
+// isRAM returns true for the RAM addresses where no bank of
+// the disassembly is mapped, e.g. FDS programs loaded in WRAM.
+func (d *Disassembly) isRAM(address uint16) bool {
+	if !IsRAM(address) {
+		return false
+	}
+	for _, bank := range d.Banks {
+		if bank.Contains(address) {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultName returns the generated name of a variable.
+//  "zp_20", "ptr_10", "ram_07A7", "wram_6000"
+func (v *Variable) defaultName() string {
+	switch {
+	case v.Pointer && v.Address < 0x100:
+		return fmt.Sprintf("ptr_%02X", v.Address)
+	case v.Pointer:
+		return fmt.Sprintf("ptr_%04X", v.Address)
+	case v.Address < 0x100:
+		return fmt.Sprintf("zp_%02X", v.Address)
+	case v.Address >= 0x6000:
+		return fmt.Sprintf("wram_%04X", v.Address)
+	}
+	return fmt.Sprintf("ram_%04X", v.Address)
+}
+
+// detectVariables enumerates the RAM referenced by the decoded code.
+// Pointers and the words of 16-bit idioms take two bytes, the second
+// one being written name+1.
+func (d *Disassembly) detectVariables() {
+	words := map[uint16]bool{}
+	pointers := map[uint16]bool{}
+	for _, idiom := range d.idioms {
+		operands := []Operand16{idiom.Dest}
+		switch idiom.Kind {
+		case Add16, Sub16:
+			operands = append(operands, idiom.Left, idiom.Right)
+		case Compare16:
+			operands = []Operand16{idiom.Left, idiom.Right}
+		case PointerSetup:
+			pointers[idiom.Dest.Value] = true
+		}
+		for _, op := range operands {
+			if !op.Immediate && !op.Byte {
+				words[op.Value] = true
+			}
+		}
+	}
+	for address, xrefs := range d.xrefs {
+		for _, xref := range xrefs {
+			if xref.Kind == IndirectAccess {
+				pointers[address] = true
+			}
+		}
+	}
+	addresses := d.XrefTargets()
+	for address := range d.varNames {
+		addresses = append(addresses, address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i] < addresses[j]
+	})
+
+	d.variables = map[uint16]*Variable{}
+	var previous *Variable
+	for _, address := range addresses {
+		if !d.isRAM(address) || d.variables[address] != nil {
+			continue
+		}
+		if previous != nil && previous.Size == 2 && previous.Address+1 == address {
+			if _, named := d.varNames[address]; !named {
+				// High byte of a word
+				d.variables[address] = previous
+				continue
+			}
+			previous.Size = 1
+		}
+		v := &Variable{Address: address, Size: 1, Pointer: pointers[address]}
+		if (v.Pointer || words[address]) && d.isRAM(address+1) {
+			v.Size = 2
+		}
+		d.variables[address] = v
+		previous = v
+	}
+}
+
+// Variables returns the RAM variables, sorted by address.
+func (d *Disassembly) Variables() []*Variable {
+	var variables []*Variable
+	for address, v := range d.variables {
+		if v.Address == address {
+			variables = append(variables, d.namedVariable(v))
+		}
+	}
+	sort.Slice(variables, func(i, j int) bool {
+		return variables[i].Address < variables[j].Address
+	})
+	return variables
+}
+
+// namedVariable returns a variable with its user or generated name.
+func (d *Disassembly) namedVariable(v *Variable) *Variable {
+	named := *v
+	named.Name = v.defaultName()
+	if name, ok := d.varNames[v.Address]; ok {
+		named.Name = name
+	}
+	return &named
+}
+
+// Variable returns the variable holding a RAM address.
+func (d *Disassembly) Variable(address uint16) (*Variable, bool) {
+	v, ok := d.variables[address]
+	if !ok {
+		return nil, false
+	}
+	return d.namedVariable(v), true
+}
+
+// SetVariableName names the RAM variable at an address, replacing
+// its generated name. Naming the high byte of a word splits it.
+func (d *Disassembly) SetVariableName(address uint16, name string) {
+	d.varNames[address] = name
+	if d.variables != nil {
+		d.detectVariables()
+	}
+}
+
+// variableOperand returns the name of the variable
+// an operand address belongs to, e.g. "ptr_10+1".
+func (d *Disassembly) variableOperand(address uint16) string {
+	v, ok := d.Variable(address)
+	if !ok {
+		return ""
+	}
+	if address != v.Address {
+		return fmt.Sprintf("%s+%d", v.Name, address-v.Address)
+	}
+	return v.Name
+}
+
+// variableLines returns the RAM layout: a segment for each RAM
+// area holding variables, with .res directives for the variables
+// and the gaps between them.
+func (d *Disassembly) variableLines() []Line {
+	variables := d.Variables()
+	var lines []Line
+	for _, segment := range ramSegments {
+		next := segment.start
+		for _, v := range variables {
+			if v.Address < segment.start || v.Address > segment.end {
+				continue
+			}
+			if next == segment.start {
+				lines = append(lines, Line{Offset: -1, Address: next, Text: fmt.Sprintf(".segment \"%s\"", segment.name)})
+			}
+			if v.Address > next {
+				lines = append(lines, Line{Offset: -1, Address: next, Text: fmt.Sprintf(".res %d", v.Address-next)})
+			}
+			lines = append(lines, Line{
+				Offset:  -1,
+				Address: v.Address,
+				Label:   v.Name,
+				Text:    fmt.Sprintf(".res %d", v.Size),
+				Comment: WordToAddress(v.Address),
+			})
+			next = v.Address + uint16(v.Size)
+		}
+	}
+	if len(lines) > 0 {
+		lines = append(lines, Line{Offset: -1, Text: ".segment \"CODE\""})
+	}
+	return lines
+}
+
+// variableNameRegexp matches "name = $0010" lines.
+var variableNameRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*\$([0-9A-Fa-f]{1,4})$`)
+
+// ParseVariableNames reads RAM variable names, one "name = $address"
+// assignment per line. Empty lines and ';' comments are ignored.
+//  player_x = $0086
+func ParseVariableNames(r io.Reader) (map[uint16]string, error) {
+	names := map[uint16]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, ";"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		match := variableNameRegexp.FindStringSubmatch(text)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected 'name = $address', got '%s'", line, text)
+		}
+		address, _ := strconv.ParseUint(match[2], 16, 16)
+		if !IsRAM(uint16(address)) {
+			return nil, fmt.Errorf("line %d: %s is not a RAM address", line, WordToAddress(uint16(address)))
+		}
+		names[uint16(address)] = match[1]
+	}
+	return names, scanner.Err()
+}
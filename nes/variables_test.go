@@ -0,0 +1,58 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestVariables(t *testing.T) {
+	prg := testrom.Prg(
+		0xB5, 0x20, // C000: LDA $20,X
+		0xB1, 0x10, // C002: LDA ($10),Y
+		0x8D, 0xA7, 0x07, // C004: STA $07A7
+		0xE6, 0x11, // C007: INC $11
+		0x8D, 0x00, 0x60, // C009: STA $6000
+		0xAD, 0x02, 0x20, // C00C: LDA $2002
+		0x60, // C00F: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	variables := d.Variables()
+	assert.Len(t, variables, 4)
+	assert.Equal(t, Variable{Address: 0x10, Size: 2, Pointer: true, Name: "ptr_10"}, *variables[0])
+	assert.Equal(t, "zp_20", variables[1].Name)
+	assert.Equal(t, "ram_07A7", variables[2].Name)
+	assert.Equal(t, "wram_6000", variables[3].Name)
+
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "LDA zp_20,X\n"))
+	assert.True(t, strings.Contains(asm, "LDA (ptr_10),Y\n"))
+	assert.True(t, strings.Contains(asm, "INC ptr_10+1\n"))
+	assert.True(t, strings.Contains(asm, "LDA $2002\n"))
+	assert.True(t, strings.Contains(asm, ".segment \"ZEROPAGE\"\n    .res 16"))
+	assert.True(t, strings.Contains(asm, "ptr_10:\n    .res 2                       ; $0010\n    .res 14\nzp_20:\n"))
+	assert.True(t, strings.Contains(asm, ".segment \"BSS\"\n    .res 1703\nram_07A7:\n"))
+
+	d.SetVariableName(0x11, "pointer_hi")
+	d.SetVariableName(0x20, "player_x")
+	variables = d.Variables()
+	assert.Equal(t, 1, variables[0].Size)
+	assert.Equal(t, "pointer_hi", variables[1].Name)
+	assert.True(t, strings.Contains(d.String(), "LDA player_x,X\n"))
+}
+
+func TestParseVariableNames(t *testing.T) {
+	names, err := ParseVariableNames(strings.NewReader("; Player\nplayer_x = $0086\n\nscore=$07DE ; BCD\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint16]string{0x86: "player_x", 0x07DE: "score"}, names)
+
+	_, err = ParseVariableNames(strings.NewReader("player_x = $0086\nbank = $8000\n"))
+	assert.EqualError(t, err, "line 2: $8000 is not a RAM address")
+	_, err = ParseVariableNames(strings.NewReader("player_x $0086\n"))
+	assert.EqualError(t, err, "line 1: expected 'name = $address', got 'player_x $0086'")
+}
@@ -0,0 +1,143 @@
+package nes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AccessKind tells how an instruction uses the address it references.
+// Read-modify-write instructions (INC, ASL...) both read and write.
+type AccessKind byte
+
+const (
+	ReadAccess     AccessKind = 1 << iota
+	WriteAccess               // STA, STX, STY and read-modify-write instructions
+	ExecuteAccess             // Branch or JMP target
+	CallAccess                // JSR target
+	IndirectAccess            // Pointer read by JMP ($xxxx), ($xx,X) or ($xx),Y
+)
+
+func (kind AccessKind) String() string {
+	var names []string
+	for i, name := range []string{"read", "write", "execute", "call", "indirect"} {
+		if kind&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+// Xref is a reference from an instruction to an address:
+// code, ROM data, RAM or a hardware register.
+type Xref struct {
+	From   Instruction
+	Kind   AccessKind
+	Target uint16 // Referenced CPU address
+	Offset int    // PRG ROM offset of Target, -1 outside PRG ROM or in an unknown bank
+}
+
+// maxXrefComments is the number of references listed above a label.
+const maxXrefComments = 8
+
+// storeMnemonics and modifyMnemonics write their memory operand.
+var (
+	storeMnemonics  = map[string]bool{"STA": true, "STX": true, "STY": true}
+	modifyMnemonics = map[string]bool{"ASL": true, "LSR": true, "ROL": true, "ROR": true, "INC": true, "DEC": true}
+)
+
+// accessKind returns how an instruction uses its operand address.
+func accessKind(inst Instruction) AccessKind {
+	switch {
+	case inst.IsCall():
+		return CallAccess
+	case inst.IsBranch(), inst.Code == JmpAbsolute:
+		return ExecuteAccess
+	case inst.Mode == Indirect, inst.Mode == IndirectX, inst.Mode == IndirectY:
+		return IndirectAccess
+	case storeMnemonics[inst.Mnemonic]:
+		return WriteAccess
+	case modifyMnemonics[inst.Mnemonic]:
+		return ReadAccess | WriteAccess
+	}
+	return ReadAccess
+}
+
+// buildXrefs indexes the references of every decoded instruction
+// by referenced address.
+func (d *Disassembly) buildXrefs() {
+	d.xrefs = map[uint16][]Xref{}
+	for _, inst := range d.Instructions() {
+		address, ok := inst.OperandAddress()
+		if !ok {
+			continue
+		}
+		xref := Xref{From: inst, Kind: accessKind(inst), Target: address, Offset: -1}
+		if offset, ok := d.Resolve(d.BankAt(inst.Offset).Index, address); ok {
+			xref.Offset = offset
+		}
+		d.xrefs[address] = append(d.xrefs[address], xref)
+	}
+}
+
+// XrefsTo returns the references to a CPU address, from any bank,
+// sorted by PRG ROM offset of the referencing instruction.
+//  d.XrefsTo(0x0300) // Who reads or writes $0300?
+func (d *Disassembly) XrefsTo(address uint16) []Xref {
+	return d.xrefs[address]
+}
+
+// XrefsToOffset returns the references to a PRG ROM offset.
+func (d *Disassembly) XrefsToOffset(offset int) []Xref {
+	var xrefs []Xref
+	for _, xref := range d.xrefs[d.AddressOf(offset)] {
+		if xref.Offset == offset {
+			xrefs = append(xrefs, xref)
+		}
+	}
+	return xrefs
+}
+
+// XrefTargets returns the referenced CPU addresses, sorted.
+func (d *Disassembly) XrefTargets() []uint16 {
+	targets := make([]uint16, 0, len(d.xrefs))
+	for address := range d.xrefs {
+		targets = append(targets, address)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i] < targets[j]
+	})
+	return targets
+}
+
+// Location returns the CPU address of a PRG ROM offset, prefixed
+// by the bank number for switchable banks.
+//  "$C012", "$02:8012"
+func (d *Disassembly) Location(offset int) string {
+	bank := d.BankAt(offset)
+	if !bank.Fixed {
+		return fmt.Sprintf("$%02X:%04X", bank.Index, bank.AddressOf(offset))
+	}
+	return WordToAddress(bank.AddressOf(offset))
+}
+
+// xrefComment returns the comment written above a label,
+// listing the instructions referencing it.
+func (d *Disassembly) xrefComment(offset int) string {
+	if _, ok := d.labels[offset]; !ok {
+		return ""
+	}
+	xrefs := d.XrefsToOffset(offset)
+	if len(xrefs) == 0 {
+		return ""
+	}
+	var refs []string
+	for i, xref := range xrefs {
+		if i == maxXrefComments {
+			refs = append(refs, fmt.Sprintf("%d more", len(xrefs)-i))
+			break
+		}
+		refs = append(refs, fmt.Sprintf("%s (%s)", d.Location(xref.From.Offset), xref.Kind))
+	}
+	return "xrefs: " + strings.Join(refs, ", ")
+}
@@ -0,0 +1,54 @@
+package nes
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+)
+
+func TestXrefs(t *testing.T) {
+	prg := testrom.Prg(
+		0xAD, 0x02, 0x20, // C000: LDA $2002
+		0x9D, 0x00, 0x03, // C003: STA $0300,X
+		0xEE, 0x00, 0x03, // C006: INC $0300
+		0x20, 0x13, 0xC0, // C009: JSR $C013
+		0xB1, 0x10, // C00C: LDA ($10),Y
+		0xD0, 0xF0, // C00E: BNE $C000
+		0x6C, 0x10, 0x00, // C010: JMP ($0010)
+		0x60, // C013: RTS
+	)
+	d := NewDisassembly(prg)
+	d.AddVectors()
+	d.Analyze()
+
+	xrefs := d.XrefsTo(0x0300)
+	assert.Len(t, xrefs, 2)
+	assert.Equal(t, uint16(0xC003), xrefs[0].From.Address)
+	assert.Equal(t, WriteAccess, xrefs[0].Kind)
+	assert.Equal(t, ReadAccess|WriteAccess, xrefs[1].Kind)
+	assert.Equal(t, "read/write", xrefs[1].Kind.String())
+	assert.Equal(t, -1, xrefs[1].Offset)
+
+	assert.Equal(t, ReadAccess, d.XrefsTo(0x2002)[0].Kind)
+	xrefs = d.XrefsTo(0x0010)
+	assert.Len(t, xrefs, 2)
+	assert.Equal(t, IndirectAccess, xrefs[0].Kind)
+	assert.Equal(t, IndirectAccess, xrefs[1].Kind)
+
+	xrefs = d.XrefsToOffset(0x13)
+	assert.Len(t, xrefs, 1)
+	assert.Equal(t, CallAccess, xrefs[0].Kind)
+	assert.Equal(t, ExecuteAccess, d.XrefsToOffset(0)[0].Kind)
+
+	asm := d.String()
+	assert.True(t, strings.Contains(asm, "; xrefs: $C009 (call)\nsub_C013:\n"))
+	assert.True(t, strings.Contains(asm, "; xrefs: $C00E (execute)\nnmi:\n"))
+}
+
+func TestXrefLocation(t *testing.T) {
+	d := NewDisassembly(make([]byte, 0x20000))
+	assert.Equal(t, "$02:8012", d.Location(0x8012))
+	assert.Equal(t, "$C012", d.Location(0x1C012))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["nsf"] = command{
+		run:   runNsf,
+		usage: "nsf [-o XXX.txt] XXX.nsf: show the header of an NSF or NSFe file",
+	}
+}
+
+// readNsf returns the NSF or NSFe file at `path`.
+func readNsf(path string) (*nes.Nsf, error) {
+	data, err := loadFile(path, *entryName)
+	if err != nil {
+		return nil, err
+	}
+	if !nes.IsNsfFile(data) && !nes.IsNsfeFile(data) {
+		return nil, fmt.Errorf("'%s' is not an NSF file", path)
+	}
+	nsf, err := nes.ReadNsf(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %s", path, err)
+	}
+	return nsf, nil
+}
+
+// nsfInfo returns the header of an NSF file, one field per line.
+func nsfInfo(nsf *nes.Nsf) []string {
+	lines := []string{
+		fmt.Sprintf("Title: %s", nsf.Title),
+		fmt.Sprintf("Artist: %s", nsf.Artist),
+		fmt.Sprintf("Copyright: %s", nsf.Copyright),
+	}
+	if nsf.Ripper != "" {
+		lines = append(lines, fmt.Sprintf("Ripper: %s", nsf.Ripper))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Songs: %d, starting with %d", nsf.Songs, nsf.StartSong),
+		fmt.Sprintf("Load: $%04X, init: $%04X, play: $%04X", nsf.Load, nsf.Init, nsf.Play))
+	if nsf.Bankswitched() {
+		banks := make([]string, len(nsf.Banks))
+		for i, bank := range nsf.Banks {
+			banks[i] = fmt.Sprintf("$%02X", bank)
+		}
+		lines = append(lines, fmt.Sprintf("Banks: %s", strings.Join(banks, " ")))
+	}
+	for i, label := range nsf.TrackLabels {
+		lines = append(lines, fmt.Sprintf("Track %d: %s", i+1, label))
+	}
+	return lines
+}
+
+// writeNsf disassembles the driver of an NSF file.
+func writeNsf(nsf *nes.Nsf) error {
+	disassembly := nsf.Disassembly()
+	if err := setVariableNames(disassembly); err != nil {
+		return err
+	}
+	disassembly.Analyze()
+	if *showCycles {
+		cfg.Build(disassembly).AnnotateCycles()
+	}
+	header := "; " + strings.Join(nsfInfo(nsf), "\n; ")
+	return writeOutput(*outputFile, header+"\n"+disassembly.String())
+}
+
+func runNsf(args []string) error {
+	flags := flag.NewFlagSet("nsf", flag.ExitOnError)
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: nsf [-o XXX.txt] XXX.nsf")
+	}
+
+	nsf, err := readNsf(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return writeOutput(*output, strings.Join(nsfInfo(nsf), "\n"))
+}
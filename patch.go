@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+	"github.com/vpenando/nes-rom-decompiler/patch"
+)
+
+func init() {
+	commands["patch"] = command{
+		run:   runPatch,
+		usage: "patch (apply [-o XXX.nes] [-force] XXX.nes PATCH | create [-f ips|bps|ups] [-o PATCH] XXX.nes YYY.nes): apply or create an IPS, BPS or UPS patch",
+	}
+}
+
+// withExtension replaces the extension of a file name.
+func withExtension(path, extension string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + extension
+}
+
+func runPatch(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "apply":
+			return runPatchApply(args[1:])
+		case "create":
+			return runPatchCreate(args[1:])
+		}
+	}
+	return errors.New("usage: patch (apply | create) ...")
+}
+
+func runPatchApply(args []string) error {
+	flags := flag.NewFlagSet("patch apply", flag.ExitOnError)
+	output := flags.String("o", "", "Patched ROM, PATCH.nes by default")
+	force := flags.Bool("force", false, "Write the patched ROM even if its header is invalid")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: patch apply [-o XXX.nes] [-force] XXX.nes PATCH")
+	}
+
+	source, err := loadFile(flags.Arg(0), *entryName)
+	if err != nil {
+		return err
+	}
+	content, err := ioutil.ReadFile(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	patched, err := patch.Apply(source, content)
+	if err != nil {
+		return fmt.Errorf("failed to apply '%s': %s", flags.Arg(1), err)
+	}
+	if _, err := nes.ReadRom(patched); err != nil && !*force {
+		return fmt.Errorf("invalid patched ROM (use -force to write it anyway): %s", err)
+	}
+	if *output == "" {
+		*output = withExtension(flags.Arg(1), ".nes")
+	}
+	return ioutil.WriteFile(*output, patched, 0644)
+}
+
+func runPatchCreate(args []string) error {
+	flags := flag.NewFlagSet("patch create", flag.ExitOnError)
+	format := flags.String("f", "", "Patch format: ips, bps or ups, from the output file name by default")
+	output := flags.String("o", "", "Output file, YYY.ips by default")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: patch create [-f ips|bps|ups] [-o PATCH] XXX.nes YYY.nes")
+	}
+
+	patchFormat := patch.IPS
+	var err error
+	if *format != "" {
+		patchFormat, err = patch.ParseFormat(*format)
+	} else if *output != "" {
+		patchFormat, err = patch.ParseFormat(*output)
+	}
+	if err != nil {
+		return err
+	}
+	source, err := loadFile(flags.Arg(0), *entryName)
+	if err != nil {
+		return err
+	}
+	target, err := loadFile(flags.Arg(1), *entryName)
+	if err != nil {
+		return err
+	}
+	if _, err := nes.ReadRom(target); err != nil {
+		return fmt.Errorf("invalid ROM '%s': %s", flags.Arg(1), err)
+	}
+	content, err := patch.Create(patchFormat, source, target)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = withExtension(flags.Arg(1), "."+patchFormat.String())
+	}
+	return ioutil.WriteFile(*output, content, 0644)
+}
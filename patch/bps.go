@@ -0,0 +1,145 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const bpsMagic = "BPS1"
+
+// BPS actions, stored in the low 2 bits of their length.
+const (
+	bpsSourceRead = iota // Copies the source at the output offset
+	bpsTargetRead        // Copies bytes from the patch
+	bpsSourceCopy        // Copies the source from a relative offset
+	bpsTargetCopy        // Copies the output from a relative offset
+)
+
+// bpsMinSourceRead is the minimum number of unchanged bytes worth
+// a source read when creating a patch.
+const bpsMinSourceRead = 4
+
+// ApplyBPS applies a BPS patch, checking the CRC32 of the
+// patch, of the source and of the output.
+func ApplyBPS(source, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic) || string(patch[:len(bpsMagic)]) != bpsMagic {
+		return nil, errors.New("not a BPS patch")
+	}
+	body, sourceCRC, targetCRC, err := checksums(patch, bpsMagic)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{data: body}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if metadataSize > uint64(len(body)-r.offset) || targetSize > 1<<31 {
+		return nil, errTruncated
+	}
+	r.offset += int(metadataSize)
+	if sourceSize != uint64(len(source)) {
+		return nil, fmt.Errorf("source size mismatch: expected %d, got %d", sourceSize, len(source))
+	}
+	if actual := crc32.ChecksumIEEE(source); actual != sourceCRC {
+		return nil, fmt.Errorf("source CRC32 mismatch: expected %08X, got %08X", sourceCRC, actual)
+	}
+
+	output := make([]byte, targetSize)
+	offset, sourceOffset, targetOffset := 0, 0, 0
+	for r.offset < len(body) {
+		data, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		action, length := data&3, int(data>>2)+1
+		if offset+length > len(output) {
+			return nil, errors.New("action beyond the target size")
+		}
+		switch action {
+		case bpsSourceRead:
+			if offset+length > len(source) {
+				return nil, errors.New("source read beyond the source size")
+			}
+			copy(output[offset:], source[offset:offset+length])
+		case bpsTargetRead:
+			if r.offset+length > len(body) {
+				return nil, errTruncated
+			}
+			copy(output[offset:], body[r.offset:r.offset+length])
+			r.offset += length
+		case bpsSourceCopy, bpsTargetCopy:
+			relative, err := r.number()
+			if err != nil {
+				return nil, err
+			}
+			delta := int(relative >> 1)
+			if relative&1 != 0 {
+				delta = -delta
+			}
+			if action == bpsSourceCopy {
+				sourceOffset += delta
+				if sourceOffset < 0 || sourceOffset+length > len(source) {
+					return nil, errors.New("source copy beyond the source size")
+				}
+				copy(output[offset:], source[sourceOffset:sourceOffset+length])
+				sourceOffset += length
+				break
+			}
+			targetOffset += delta
+			if targetOffset < 0 || targetOffset >= offset {
+				return nil, errors.New("target copy beyond the output")
+			}
+			for i := 0; i < length; i++ { // May overlap the bytes written
+				output[offset+i] = output[targetOffset+i]
+			}
+			targetOffset += length
+		}
+		offset += length
+	}
+	if actual := crc32.ChecksumIEEE(output); actual != targetCRC {
+		return nil, fmt.Errorf("target CRC32 mismatch: expected %08X, got %08X", targetCRC, actual)
+	}
+	return output, nil
+}
+
+// CreateBPS returns a linear BPS patch turning `source` into
+// `target`: unchanged bytes are read from the source, the
+// others from the patch.
+func CreateBPS(source, target []byte) []byte {
+	patch := []byte(bpsMagic)
+	patch = writeNumber(patch, uint64(len(source)))
+	patch = writeNumber(patch, uint64(len(target)))
+	patch = writeNumber(patch, 0) // No metadata
+	unchanged := func(i int) int {
+		n := 0
+		for i+n < len(target) && i+n < len(source) && source[i+n] == target[i+n] {
+			n++
+		}
+		return n
+	}
+	for i := 0; i < len(target); {
+		if n := unchanged(i); n >= bpsMinSourceRead || n > 0 && i+n == len(target) {
+			patch = writeNumber(patch, uint64(n-1)<<2|bpsSourceRead)
+			i += n
+			continue
+		}
+		end := i + 1
+		for end < len(target) && unchanged(end) < bpsMinSourceRead {
+			end++
+		}
+		patch = writeNumber(patch, uint64(end-i-1)<<2|bpsTargetRead)
+		patch = append(patch, target[i:end]...)
+		i = end
+	}
+	return appendChecksums(patch, source, target)
+}
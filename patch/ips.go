@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	ipsMagic     = "PATCH"
+	ipsEOF       = 0x454F46 // "EOF", which cannot start a record
+	ipsMaxOffset = 0xFFFFFF
+	ipsMaxSize   = 0xFFFF
+	ipsMinRLE    = 8 // Shorter runs are cheaper as plain records
+)
+
+// ApplyIPS applies an IPS patch. Records are written at a 24-bit
+// offset, either as plain bytes or as a run of the same byte
+// (RLE) when their size is 0. The output grows as needed; an
+// optional 24-bit size after "EOF" truncates it.
+func ApplyIPS(source, patch []byte) ([]byte, error) {
+	if len(patch) < len(ipsMagic) || string(patch[:len(ipsMagic)]) != ipsMagic {
+		return nil, errors.New("not an IPS patch")
+	}
+	output := append([]byte{}, source...)
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(output) {
+			output = append(output, make([]byte, end-len(output))...)
+		}
+		copy(output[offset:], data)
+	}
+	for i := len(ipsMagic); ; {
+		if i+3 > len(patch) {
+			return nil, errTruncated
+		}
+		offset := int(patch[i])<<16 | int(patch[i+1])<<8 | int(patch[i+2])
+		i += 3
+		if offset == ipsEOF {
+			switch len(patch) - i {
+			case 0:
+				return output, nil
+			case 3:
+				size := int(patch[i])<<16 | int(patch[i+1])<<8 | int(patch[i+2])
+				if size < len(output) {
+					output = output[:size]
+				}
+				return output, nil
+			}
+			return nil, fmt.Errorf("%d unexpected bytes after EOF", len(patch)-i)
+		}
+		if i+2 > len(patch) {
+			return nil, errTruncated
+		}
+		size := int(patch[i])<<8 | int(patch[i+1])
+		i += 2
+		if size == 0 {
+			if i+3 > len(patch) {
+				return nil, errTruncated
+			}
+			count := int(patch[i])<<8 | int(patch[i+1])
+			data := make([]byte, count)
+			for j := range data {
+				data[j] = patch[i+2]
+			}
+			write(offset, data)
+			i += 3
+			continue
+		}
+		if i+size > len(patch) {
+			return nil, errTruncated
+		}
+		write(offset, patch[i:i+size])
+		i += size
+	}
+}
+
+// CreateIPS returns an IPS patch turning `source` into `target`,
+// using RLE records for runs of the same byte and the truncation
+// extension if `target` is shorter.
+func CreateIPS(source, target []byte) ([]byte, error) {
+	if len(target) > ipsMaxOffset+1 {
+		return nil, errors.New("IPS patches are limited to 16 MiB")
+	}
+	patch := []byte(ipsMagic)
+	changed := func(i int) bool {
+		return i >= len(source) || source[i] != target[i]
+	}
+	for i := 0; i < len(target); {
+		if !changed(i) {
+			i++
+			continue
+		}
+		start := i
+		if start == ipsEOF {
+			start-- // Rewrites the previous byte instead
+		}
+		if run := repeated(target, start); run >= ipsMinRLE {
+			patch = append(patch, byte(start>>16), byte(start>>8), byte(start), 0, 0, byte(run>>8), byte(run), target[start])
+			i = start + run
+			continue
+		}
+		end := i + 1
+		for end < len(target) && end-start < ipsMaxSize && changed(end) && repeated(target, end) < ipsMinRLE {
+			end++
+		}
+		patch = append(patch, byte(start>>16), byte(start>>8), byte(start), byte((end-start)>>8), byte(end-start))
+		patch = append(patch, target[start:end]...)
+		i = end
+	}
+	patch = append(patch, "EOF"...)
+	if len(target) < len(source) {
+		patch = append(patch, byte(len(target)>>16), byte(len(target)>>8), byte(len(target)))
+	}
+	return patch, nil
+}
+
+// repeated returns the number of bytes equal to data[start]
+// from `start`, up to the size of a record.
+func repeated(data []byte, start int) int {
+	n := 1
+	for start+n < len(data) && n < ipsMaxSize && data[start+n] == data[start] {
+		n++
+	}
+	return n
+}
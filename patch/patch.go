@@ -0,0 +1,156 @@
+// Package patch applies and creates IPS, BPS and UPS patches,
+// the formats used to distribute ROM hacks.
+package patch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"strings"
+)
+
+// Format is a patch file format.
+type Format int
+
+const (
+	IPS Format = iota // International Patching System
+	BPS               // Beat patches, checked with CRC32
+	UPS               // Universal Patching System, checked with CRC32
+)
+
+var formatNames = map[Format]string{IPS: "ips", BPS: "bps", UPS: "ups"}
+
+func (format Format) String() string {
+	return formatNames[format]
+}
+
+// ParseFormat parses "ips", "bps" or "ups", or the extension
+// of a file name such as "hack.bps".
+func ParseFormat(s string) (Format, error) {
+	name := strings.ToLower(strings.TrimPrefix(filepath.Ext(s), "."))
+	if name == "" {
+		name = strings.ToLower(s)
+	}
+	for format, formatName := range formatNames {
+		if formatName == name {
+			return format, nil
+		}
+	}
+	return IPS, fmt.Errorf("unknown patch format '%s'", s)
+}
+
+// Detect returns the format of a patch from its magic number.
+func Detect(patch []byte) (Format, bool) {
+	switch {
+	case len(patch) >= 5 && string(patch[:5]) == ipsMagic:
+		return IPS, true
+	case len(patch) >= 4 && string(patch[:4]) == bpsMagic:
+		return BPS, true
+	case len(patch) >= 4 && string(patch[:4]) == upsMagic:
+		return UPS, true
+	}
+	return IPS, false
+}
+
+// Apply applies a patch of any format to `source`
+// and returns the patched data.
+func Apply(source, patch []byte) ([]byte, error) {
+	format, ok := Detect(patch)
+	if !ok {
+		return nil, errors.New("unknown patch format")
+	}
+	switch format {
+	case BPS:
+		return ApplyBPS(source, patch)
+	case UPS:
+		return ApplyUPS(source, patch)
+	}
+	return ApplyIPS(source, patch)
+}
+
+// Create returns a patch turning `source` into `target`.
+func Create(format Format, source, target []byte) ([]byte, error) {
+	switch format {
+	case BPS:
+		return CreateBPS(source, target), nil
+	case UPS:
+		return CreateUPS(source, target), nil
+	}
+	return CreateIPS(source, target)
+}
+
+// writeNumber appends a variable-length number, as written by BPS
+// and UPS: 7 bits per byte, the last byte having its high bit set.
+func writeNumber(data []byte, n uint64) []byte {
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(data, 0x80|x)
+		}
+		data = append(data, x)
+		n--
+	}
+}
+
+// reader reads the body of a BPS or UPS patch.
+type reader struct {
+	data   []byte
+	offset int
+}
+
+var errTruncated = errors.New("truncated patch")
+
+func (r *reader) byte() (byte, error) {
+	if r.offset >= len(r.data) {
+		return 0, errTruncated
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b, nil
+}
+
+// number reads a number written by writeNumber.
+func (r *reader) number() (uint64, error) {
+	var n uint64
+	shift := uint64(1)
+	for {
+		x, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		n += uint64(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return n, nil
+		}
+		if shift > 1<<56 {
+			return 0, errors.New("invalid number")
+		}
+		shift <<= 7
+		n += shift
+	}
+}
+
+// checksums splits a BPS or UPS patch into its body and the
+// CRC32 of the source and the target, after checking the
+// CRC32 of the patch itself.
+func checksums(patch []byte, magic string) ([]byte, uint32, uint32, error) {
+	if len(patch) < len(magic)+12 {
+		return nil, 0, 0, errTruncated
+	}
+	footer := patch[len(patch)-12:]
+	if expected, actual := binary.LittleEndian.Uint32(footer[8:]), crc32.ChecksumIEEE(patch[:len(patch)-4]); expected != actual {
+		return nil, 0, 0, fmt.Errorf("patch CRC32 mismatch: expected %08X, got %08X", expected, actual)
+	}
+	return patch[len(magic) : len(patch)-12], binary.LittleEndian.Uint32(footer), binary.LittleEndian.Uint32(footer[4:]), nil
+}
+
+// appendChecksums appends the CRC32 of the source, the target
+// and the patch.
+func appendChecksums(patch, source, target []byte) []byte {
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
@@ -0,0 +1,103 @@
+package patch
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestFiles returns a source and a target with changed bytes,
+// a run of the same byte, and a different size.
+func newTestFiles(targetSize int) ([]byte, []byte) {
+	random := rand.New(rand.NewSource(1))
+	source := make([]byte, 0x1000)
+	random.Read(source)
+	target := make([]byte, targetSize)
+	copy(target, source)
+	for i := 0; i < 40; i++ {
+		target[random.Intn(0x800)] ^= byte(random.Intn(255) + 1)
+	}
+	for i := 0x900; i < 0x980; i++ {
+		target[i] = 0xEA
+	}
+	return source, target
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{IPS, BPS, UPS} {
+		for _, size := range []int{0x1000, 0x1800, 0xC00} {
+			source, target := newTestFiles(size)
+			patch, err := Create(format, source, target)
+			assert.NoError(t, err)
+			detected, ok := Detect(patch)
+			assert.True(t, ok)
+			assert.Equal(t, format, detected)
+			output, err := Apply(source, patch)
+			assert.NoError(t, err, "%s, %d bytes", format, size)
+			assert.Equal(t, target, output, "%s, %d bytes", format, size)
+		}
+	}
+}
+
+func TestIPS(t *testing.T) {
+	source := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	target := append([]byte{0, 0xFF}, source[2:8]...)
+	target = append(target, 9, 9, 9, 9, 9, 9, 9, 9, 9, 9)
+	patch, err := CreateIPS(source, target)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("PATCH"+
+		"\x00\x00\x01\x00\x01\xFF"+
+		"\x00\x00\x08\x00\x00\x00\x0A\x09"+
+		"EOF"), patch)
+	output, err := ApplyIPS(source, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, target, output)
+
+	output, err = ApplyIPS(source, []byte("PATCH\x00\x00\x10\x00\x01\xAAEOF\x00\x00\x02"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1}, output)
+	_, err = ApplyIPS(source, []byte("PATCH\x00\x00\x10\x00\x02\xAA"))
+	assert.EqualError(t, err, "truncated patch")
+}
+
+func TestIPSEOFOffset(t *testing.T) {
+	source := make([]byte, ipsEOF+0x10)
+	target := append([]byte{}, source...)
+	target[ipsEOF] = 1
+	patch, err := CreateIPS(source, target)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("PATCH\x45\x4F\x45\x00\x02\x00\x01EOF"), patch)
+	output, err := ApplyIPS(source, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, target, output)
+}
+
+func TestChecksums(t *testing.T) {
+	source, target := newTestFiles(0x1000)
+	bps := CreateBPS(source, target)
+	_, err := ApplyBPS(target, bps)
+	assert.EqualError(t, err, "source CRC32 mismatch: expected B817310A, got 6B666BFB")
+	bps[10] ^= 1
+	_, err = ApplyBPS(source, bps)
+	assert.Error(t, err)
+
+	// UPS patches work both ways
+	ups := CreateUPS(source, target)
+	output, err := ApplyUPS(target, ups)
+	assert.NoError(t, err)
+	assert.Equal(t, source, output)
+	_, err = ApplyUPS(source[1:], ups)
+	assert.Error(t, err)
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("hack.BPS")
+	assert.NoError(t, err)
+	assert.Equal(t, BPS, format)
+	format, err = ParseFormat("ups")
+	assert.NoError(t, err)
+	assert.Equal(t, UPS, format)
+	_, err = ParseFormat("hack.xdelta")
+	assert.EqualError(t, err, "unknown patch format 'hack.xdelta'")
+}
@@ -0,0 +1,105 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const upsMagic = "UPS1"
+
+// ApplyUPS applies a UPS patch, checking the CRC32 of the patch,
+// of the input and of the output. UPS patches store the XOR of
+// both files, so they also turn the target back into the source.
+func ApplyUPS(input, patch []byte) ([]byte, error) {
+	if len(patch) < len(upsMagic) || string(patch[:len(upsMagic)]) != upsMagic {
+		return nil, errors.New("not a UPS patch")
+	}
+	body, sourceCRC, targetCRC, err := checksums(patch, upsMagic)
+	if err != nil {
+		return nil, err
+	}
+	r := &reader{data: body}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	inputCRC := crc32.ChecksumIEEE(input)
+	outputSize, outputCRC := targetSize, targetCRC
+	switch {
+	case uint64(len(input)) == sourceSize && inputCRC == sourceCRC:
+	case uint64(len(input)) == targetSize && inputCRC == targetCRC:
+		outputSize, outputCRC = sourceSize, sourceCRC
+	default:
+		return nil, fmt.Errorf("source CRC32 mismatch: expected %08X, got %08X", sourceCRC, inputCRC)
+	}
+	if outputSize > 1<<31 {
+		return nil, errors.New("invalid target size")
+	}
+
+	output := make([]byte, outputSize)
+	copy(output, input)
+	for offset := 0; r.offset < len(body); offset++ {
+		skip, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		if skip > uint64(len(output)) {
+			return nil, errors.New("hunk beyond the target size")
+		}
+		for offset += int(skip); ; offset++ {
+			x, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if x == 0 {
+				break
+			}
+			if offset < len(output) {
+				output[offset] ^= x
+			}
+		}
+	}
+	if actual := crc32.ChecksumIEEE(output); actual != outputCRC {
+		return nil, fmt.Errorf("target CRC32 mismatch: expected %08X, got %08X", outputCRC, actual)
+	}
+	return output, nil
+}
+
+// CreateUPS returns a UPS patch turning `source` into `target`:
+// hunks of XORed bytes, each one after the number of unchanged
+// bytes since the previous one and ending with a 0.
+func CreateUPS(source, target []byte) []byte {
+	patch := []byte(upsMagic)
+	patch = writeNumber(patch, uint64(len(source)))
+	patch = writeNumber(patch, uint64(len(target)))
+	size := len(source)
+	if len(target) > size {
+		size = len(target)
+	}
+	at := func(data []byte, i int) byte {
+		if i < len(data) {
+			return data[i]
+		}
+		return 0
+	}
+	skip := 0
+	for i := 0; i < size; i++ {
+		x := at(source, i) ^ at(target, i)
+		if x == 0 {
+			skip++
+			continue
+		}
+		patch = writeNumber(patch, uint64(skip))
+		for ; i < size && at(source, i)^at(target, i) != 0; i++ {
+			patch = append(patch, at(source, i)^at(target, i))
+		}
+		patch = append(patch, 0) // Stands for the byte at i, unchanged
+		skip = 0
+	}
+	return appendChecksums(patch, source, target)
+}
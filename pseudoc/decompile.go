@@ -0,0 +1,396 @@
+// Package pseudoc decompiles subroutines to structured C-like pseudocode:
+// instructions are lifted to the ir package, simplified by removing dead
+// flag computations and propagating values, then structured into
+// if/else, loops and switches rendered as C.
+package pseudoc
+
+import (
+	"fmt"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/ir"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// exitRegisters are assumed to be read by the caller of a subroutine,
+// and by the subroutines it calls or jumps to. Flags are not: results
+// returned in the carry are lost, but most comparisons simplify.
+var exitRegisters = []ir.Register{ir.A, ir.X, ir.Y}
+
+// block is a basic block lifted to IR statements.
+type block struct {
+	cfg      *cfg.Block
+	index    int
+	stmts    []ir.Stmt
+	succs    []*block
+	preds    []*block
+	next     *block     // Fallthrough or only successor
+	taken    *block     // Successor when the branch is taken
+	cond     ir.Expr    // Branch condition, once structured
+	dispatch *nes.Table // Jump table ending the block
+	unknown  bool       // The code after the block was not decoded
+	liveOut  map[ir.Register]bool
+}
+
+// decompiler holds the state of the decompilation of a subroutine.
+type decompiler struct {
+	d        *nes.Disassembly
+	function *cfg.Function
+	bank     int
+	blocks   []*block
+	byOffset map[int]*block
+}
+
+// Decompile returns the pseudo-C code of a subroutine of `graph`.
+//  void sub_C020(void) {
+//      X = 8;
+//      do {
+//          X--;
+//      } while (X != 0);
+//  }
+func Decompile(graph *cfg.Graph, function *cfg.Function) string {
+	dc := &decompiler{
+		d:        graph.Disassembly,
+		function: function,
+		bank:     graph.Disassembly.BankAt(function.Offset).Index,
+		byOffset: map[int]*block{},
+	}
+	dc.lift()
+	dc.simplify()
+	s := newStructurer(dc)
+	return newRenderer(dc).function(s, s.structure())
+}
+
+// lift translates the blocks of the function into IR statements.
+func (dc *decompiler) lift() {
+	lifter := &ir.Lifter{}
+	idioms, skipped, dropped := dc.idioms()
+	for i, cfgBlock := range dc.function.Blocks {
+		b := &block{cfg: cfgBlock, index: i}
+		for _, inst := range cfgBlock.Instructions {
+			if idiom, ok := idioms[inst.Offset]; ok {
+				b.stmts = append(b.stmts, lifter.LiftIdiom(idiom)...)
+			} else if !skipped[inst.Offset] {
+				b.stmts = append(b.stmts, lifter.Lift(inst)...)
+			}
+		}
+		last := cfgBlock.Last()
+		if table, ok := dc.d.Dispatch(last.Offset); ok {
+			b.dispatch = table
+			b.stmts = b.stmts[:len(b.stmts)-1]
+			if last.Code == nes.RtsImplied {
+				// The pushed address is the dispatch itself
+				b.stmts = removePushes(b.stmts, 2)
+			}
+		}
+		switch {
+		case cfgBlock.Exit == cfg.TailCallExit && last.Code == nes.JmpAbsolute:
+			b.stmts[len(b.stmts)-1] = ir.Call{Target: last.Operand}
+			b.stmts = append(b.stmts, ir.Return{})
+		case last.Code == nes.JmpAbsolute:
+			b.stmts = b.stmts[:len(b.stmts)-1]
+		case cfgBlock.Exit == cfg.UnknownExit && b.dispatch == nil:
+			b.unknown = true
+		}
+		dc.blocks = append(dc.blocks, b)
+		dc.byOffset[cfgBlock.Offset] = b
+	}
+	for _, b := range dc.blocks {
+		if dropped[b.cfg.Offset] {
+			continue
+		}
+		for _, edge := range b.cfg.Successors {
+			if dropped[edge.To.Offset] {
+				continue
+			}
+			to := dc.byOffset[edge.To.Offset]
+			if edge.Kind == cfg.Branch && !skipped[b.cfg.Last().Offset] {
+				b.taken = to
+			} else {
+				b.next = to
+			}
+			b.succs = append(b.succs, to)
+			to.preds = append(to.preds, b)
+		}
+	}
+}
+
+// idioms returns the 16-bit idioms lifted as a whole, by the offset
+// of their first instruction, and the offsets of their other
+// instructions. An increment or a decrement spans several blocks:
+// the one updating the high byte gets dropped.
+func (dc *decompiler) idioms() (map[int]*nes.Idiom, map[int]bool, map[int]bool) {
+	idioms, skipped, dropped := map[int]*nes.Idiom{}, map[int]bool{}, map[int]bool{}
+	blocks := map[int]*cfg.Block{}
+	for _, b := range dc.function.Blocks {
+		blocks[b.Offset] = b
+	}
+	for _, b := range dc.function.Blocks {
+		for i, inst := range b.Instructions {
+			idiom, ok := dc.d.Idiom(inst.Offset)
+			if !ok || skipped[inst.Offset] || !collapsible(idiom, b, i, blocks) {
+				continue
+			}
+			idioms[inst.Offset] = idiom
+			for _, other := range idiom.Instructions[1:] {
+				skipped[other.Offset] = true
+			}
+			if idiom.Kind == nes.Increment16 || idiom.Kind == nes.Decrement16 {
+				dropped[idiom.Instructions[2].Offset] = true
+			}
+		}
+	}
+	return idioms, skipped, dropped
+}
+
+// collapsible returns true if the instructions of an idiom starting
+// at the i-th instruction of `b` can be replaced by its statements:
+//  INC lo; BNE skip | INC hi | skip: ...
+//  LDA lo; BNE skip | DEC hi | skip: DEC lo ...
+func collapsible(idiom *nes.Idiom, b *cfg.Block, i int, blocks map[int]*cfg.Block) bool {
+	n := len(idiom.Instructions)
+	switch idiom.Kind {
+	case nes.Increment16, nes.Decrement16:
+		if i != len(b.Instructions)-2 {
+			return false
+		}
+		high := blocks[idiom.Instructions[2].Offset]
+		if high == nil || len(high.Instructions) != 1 || len(high.Predecessors) != 1 {
+			return false
+		}
+		if idiom.Kind == nes.Increment16 {
+			return true
+		}
+		skip := blocks[idiom.Instructions[3].Offset]
+		return skip != nil && len(skip.Predecessors) == 2
+	}
+	return i+n <= len(b.Instructions) && b.Instructions[i+n-1].Offset == idiom.Instructions[n-1].Offset
+}
+
+// removePushes removes the last `n` pushes of `stmts`.
+func removePushes(stmts []ir.Stmt, n int) []ir.Stmt {
+	for i := len(stmts) - 1; i >= 0 && n > 0; i-- {
+		if _, ok := stmts[i].(ir.Push); ok {
+			stmts = append(stmts[:i], stmts[i+1:]...)
+			n--
+		}
+	}
+	return stmts
+}
+
+// simplify removes dead assignments and propagates values
+// until nothing changes.
+func (dc *decompiler) simplify() {
+	for changed := true; changed; {
+		dc.liveness()
+		changed = false
+		for _, b := range dc.blocks {
+			if dc.eliminate(b) {
+				changed = true
+			}
+		}
+		if changed {
+			continue
+		}
+		for _, b := range dc.blocks {
+			if dc.propagate(b) {
+				changed = true
+			}
+		}
+	}
+}
+
+// implicitUses returns the registers a statement reads
+// besides the ones appearing in its expressions.
+func implicitUses(s ir.Stmt) []ir.Register {
+	switch s := s.(type) {
+	case ir.Call, ir.JumpIndirect:
+		return exitRegisters
+	case ir.Return:
+		if !s.Interrupt {
+			return exitRegisters
+		}
+	}
+	return nil
+}
+
+// uses returns the registers read by a statement.
+func uses(s ir.Stmt) []ir.Register {
+	return append(ir.StmtUses(s), implicitUses(s)...)
+}
+
+// liveAtEnd returns the registers live after the last statement of a block.
+func (dc *decompiler) liveAtEnd(b *block, liveIn map[*block]map[ir.Register]bool) map[ir.Register]bool {
+	live := map[ir.Register]bool{}
+	if b.dispatch != nil {
+		for _, r := range exitRegisters {
+			live[r] = true
+		}
+	}
+	if b.unknown {
+		for _, r := range ir.Registers {
+			live[r] = true
+		}
+	}
+	for _, succ := range b.succs {
+		for r := range liveIn[succ] {
+			live[r] = true
+		}
+	}
+	return live
+}
+
+// liveness computes the registers live at the end of every block.
+func (dc *decompiler) liveness() {
+	liveIn := map[*block]map[ir.Register]bool{}
+	for changed := true; changed; {
+		changed = false
+		for i := len(dc.blocks) - 1; i >= 0; i-- {
+			b := dc.blocks[i]
+			b.liveOut = dc.liveAtEnd(b, liveIn)
+			live := copyLive(b.liveOut)
+			for j := len(b.stmts) - 1; j >= 0; j-- {
+				if assign, ok := b.stmts[j].(ir.Assign); ok {
+					delete(live, assign.Register)
+				}
+				for _, r := range uses(b.stmts[j]) {
+					live[r] = true
+				}
+			}
+			if len(live) != len(liveIn[b]) {
+				changed = true
+			}
+			liveIn[b] = live
+		}
+	}
+}
+
+func copyLive(live map[ir.Register]bool) map[ir.Register]bool {
+	result := make(map[ir.Register]bool, len(live))
+	for r := range live {
+		result[r] = true
+	}
+	return result
+}
+
+// eliminate removes the assignments of a block whose value is never
+// read. Values having side effects are still evaluated.
+func (dc *decompiler) eliminate(b *block) bool {
+	changed := false
+	live := copyLive(b.liveOut)
+	for i := len(b.stmts) - 1; i >= 0; i-- {
+		if assign, ok := b.stmts[i].(ir.Assign); ok {
+			if !live[assign.Register] {
+				changed = true
+				if ir.HasSideEffects(assign.Value) {
+					b.stmts[i] = ir.Eval{Value: assign.Value}
+				} else {
+					b.stmts = append(b.stmts[:i], b.stmts[i+1:]...)
+					continue
+				}
+			} else {
+				delete(live, assign.Register)
+			}
+		}
+		for _, r := range uses(b.stmts[i]) {
+			live[r] = true
+		}
+	}
+	return changed
+}
+
+// propagate replaces the reads of registers assigned in a block by
+// their value, when the value is a constant or read only once.
+func (dc *decompiler) propagate(b *block) bool {
+	changed := false
+	for i := 0; i < len(b.stmts); i++ {
+		assign, ok := b.stmts[i].(ir.Assign)
+		if !ok {
+			continue
+		}
+		if dc.propagateAssign(b, i, assign) {
+			b.stmts = append(b.stmts[:i], b.stmts[i+1:]...)
+			i--
+			changed = true
+		}
+	}
+	return changed
+}
+
+// propagateAssign propagates the assignment at index `i` and
+// returns true if it can be removed.
+func (dc *decompiler) propagateAssign(b *block, i int, assign ir.Assign) bool {
+	r, value := assign.Register, assign.Value
+	_, constant := value.(ir.Const)
+	var users []int
+	count := 0
+	redefined := false
+	movable := true
+	for j := i + 1; j < len(b.stmts) && !redefined; j++ {
+		s := b.stmts[j]
+		n := countUses(ir.StmtUses(s), r)
+		if countUses(implicitUses(s), r) > 0 || (n > 0 && !movable) {
+			return false
+		}
+		if n > 0 {
+			users = append(users, j)
+			count += n
+		}
+		if next, ok := s.(ir.Assign); ok && next.Register == r {
+			redefined = true
+		}
+		movable = movable && canMove(value, s)
+	}
+	if !redefined && b.liveOut[r] {
+		return false
+	}
+	if len(users) == 0 || (!constant && count > 1) {
+		return false
+	}
+	for _, j := range users {
+		b.stmts[j] = ir.SubstituteStmt(b.stmts[j], r, value)
+	}
+	return true
+}
+
+func countUses(registers []ir.Register, r ir.Register) int {
+	n := 0
+	for _, used := range registers {
+		if used == r {
+			n++
+		}
+	}
+	return n
+}
+
+// canMove returns true if `value` can be evaluated after `s`:
+// `s` does not assign the registers it reads and, if it reads
+// memory, does not write memory.
+func canMove(value ir.Expr, s ir.Stmt) bool {
+	memory := ir.ReadsMemory(value)
+	switch s := s.(type) {
+	case ir.Assign:
+		for _, r := range ir.Uses(value) {
+			if r == s.Register {
+				return false
+			}
+		}
+		if memory && ir.HasSideEffects(s.Value) {
+			return false
+		}
+		return !ir.HasSideEffects(value) || !ir.ReadsMemory(s.Value)
+	case ir.Store, ir.Store16, ir.Push, ir.Call:
+		return !memory
+	case ir.Eval:
+		return !memory || !ir.HasSideEffects(s.Value)
+	}
+	return true
+}
+
+// label returns the label of a block, e.g. "loc_C012".
+func (dc *decompiler) label(b *block) string {
+	if label, ok := dc.d.Label(b.cfg.Offset); ok {
+		return label
+	}
+	return fmt.Sprintf("loc_%04X", b.cfg.Address())
+}
@@ -0,0 +1,175 @@
+package pseudoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// decompile analyzes a 16 KiB PRG ROM mapped at $C000 with `code`
+// at $C000 and returns the pseudo-C code of the subroutine at $C000.
+func decompile(code ...byte) string {
+	d := nes.NewDisassembly(testrom.Prg(code...))
+	d.AddVectors()
+	d.Analyze()
+	graph := cfg.Build(d)
+	function, _ := graph.Function(0)
+	return Decompile(graph, function)
+}
+
+func TestDecompileDoWhile(t *testing.T) {
+	code := decompile(
+		0xA2, 0x08, // C000: LDX #$08
+		0xCA,       // C002: DEX
+		0xD0, 0xFD, // C003: BNE $C002
+		0x60, // C005: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    X = 8;
+    do {
+        X--;
+    } while (X != 0);
+}
+`, code)
+}
+
+func TestDecompileIfElse(t *testing.T) {
+	code := decompile(
+		0xA5, 0x10, // C000: LDA $10
+		0xC9, 0x05, // C002: CMP #$05
+		0x90, 0x05, // C004: BCC $C00B
+		0xA9, 0x01, // C006: LDA #$01
+		0x4C, 0x0D, 0xC0, // C008: JMP $C00D
+		0xA9, 0x02, // C00B: LDA #$02
+		0x85, 0x11, // C00D: STA $11
+		0x60, // C00F: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    if (mem[0x10] < 5) {
+        A = 2;
+    } else {
+        A = 1;
+    }
+    mem[0x11] = A;
+}
+`, code)
+}
+
+func TestDecompileBreak(t *testing.T) {
+	code := decompile(
+		0xA0, 0x00, // C000: LDY #$00
+		0xB9, 0x00, 0xC1, // C002: LDA $C100,Y
+		0xF0, 0x06, // C005: BEQ $C00D
+		0x99, 0x00, 0x03, // C007: STA $0300,Y
+		0xC8,       // C00A: INY
+		0xD0, 0xF5, // C00B: BNE $C002
+		0x60, // C00D: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    Y = 0;
+    do {
+        A = mem[0xC100 + Y];
+        if (A == 0) {
+            break;
+        }
+        mem[0x0300 + Y] = A;
+        Y++;
+    } while (Y != 0);
+}
+`, code)
+}
+
+func TestDecompileWhile(t *testing.T) {
+	code := decompile(
+		0x2C, 0x02, 0x20, // C000: BIT $2002
+		0x10, 0xFB, // C003: BPL $C000
+		0xE6, 0x20, // C005: INC $20
+		0x60, // C007: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    while ((s8)mem[0x2002] >= 0);
+    mem[0x20]++;
+}
+`, code)
+}
+
+func TestDecompileSwitch(t *testing.T) {
+	code := decompile(
+		0x0A,             // C000: ASL A
+		0xAA,             // C001: TAX
+		0xBD, 0x0D, 0xC0, // C002: LDA $C00D,X
+		0x48,             // C005: PHA
+		0xBD, 0x0C, 0xC0, // C006: LDA $C00C,X
+		0x48,       // C009: PHA
+		0x60,       // C00A: RTS
+		0xEA,       // C00B: NOP
+		0x0F, 0xC0, // C00C: .word $C00F
+		0x11, 0xC0, // C00E: .word $C011
+		0x60, 0x60, 0x60,
+	)
+	assert.Equal(t, `void nmi(void) {
+    X = A << 1;
+    A = jmptbl_C00C[X];
+    switch (X >> 1) {
+    case 0: loc_C010(); return;
+    case 1: loc_C012(); return;
+    }
+}
+`, code)
+}
+
+func TestDecompile16BitIdioms(t *testing.T) {
+	code := decompile(
+		0xA5, 0x10, // C000: LDA $10
+		0xC9, 0x00, // C002: CMP #$00
+		0xA5, 0x11, // C004: LDA $11
+		0xE9, 0x03, // C006: SBC #$03
+		0xB0, 0x1B, // C008: BCS $C025
+		0x18,       // C00A: CLC
+		0xA5, 0x10, // C00B: LDA $10
+		0x69, 0x20, // C00D: ADC #$20
+		0x85, 0x10, // C00F: STA $10
+		0xA5, 0x11, // C011: LDA $11
+		0x69, 0x01, // C013: ADC #$01
+		0x85, 0x11, // C015: STA $11
+		0xE6, 0x12, // C017: INC $12
+		0xD0, 0x02, // C019: BNE $C01D
+		0xE6, 0x13, // C01B: INC $13
+		0xA9, 0x40, // C01D: LDA #$40
+		0x85, 0x00, // C01F: STA $00
+		0xA9, 0xC0, // C021: LDA #$C0
+		0x85, 0x01, // C023: STA $01
+		0xA9, 0x00, // C025: LDA #$00
+		0x60, // C027: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    if (word(0x10) < 0x0300) {
+        word(0x10) += 0x0120;
+        word(0x12)++;
+        word(0) = &dat_C040;
+    }
+    A = 0;
+}
+`, code)
+}
+
+func TestDecompileZeroPageIndex(t *testing.T) {
+	code := decompile(
+		0xB5, 0xF0, // C000: LDA $F0,X
+		0x85, 0x11, // C002: STA $11
+		0x96, 0x10, // C004: STX $10,Y
+		0xA1, 0x20, // C006: LDA ($20,X)
+		0x9D, 0x00, 0x03, // C008: STA $0300,X
+		0x60, // C00B: RTS
+	)
+	assert.Equal(t, `void nmi(void) {
+    mem[0x11] = mem[(u8)(0xF0 + X)];
+    mem[(u8)(0x10 + Y)] = X;
+    A = mem[word((u8)(0x20 + X))];
+    mem[0x0300 + X] = A;
+}
+`, code)
+}
@@ -0,0 +1,371 @@
+package pseudoc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/ir"
+)
+
+const indentation = "    "
+
+// Operator precedences, from the loosest to the tightest.
+var precedences = map[ir.Op]int{
+	ir.Or:  1,
+	ir.Xor: 2,
+	ir.And: 3,
+	ir.Eq:  4, ir.Ne: 4,
+	ir.Lt: 5, ir.Le: 5, ir.Gt: 5, ir.Ge: 5,
+	ir.Shl: 6, ir.Shr: 6,
+	ir.Add: 7, ir.Sub: 7,
+}
+
+const unaryPrecedence = 8
+
+// compoundOps can be written as compound assignments, e.g. A += 2.
+var compoundOps = map[ir.Op]bool{
+	ir.Add: true, ir.Sub: true, ir.And: true, ir.Or: true, ir.Xor: true, ir.Shl: true, ir.Shr: true,
+}
+
+// renderer writes structured statements as C-like pseudocode.
+type renderer struct {
+	dc      *decompiler
+	builder strings.Builder
+	targets map[*block]bool
+}
+
+func newRenderer(dc *decompiler) *renderer {
+	return &renderer{dc: dc}
+}
+
+// function returns the code of the function.
+func (r *renderer) function(s *structurer, nodes []node) string {
+	r.targets = s.targets
+	name := r.dc.function.Name
+	if name == "" {
+		name = fmt.Sprintf("sub_%04X", r.dc.function.Entry.Address())
+	}
+	r.line(0, fmt.Sprintf("void %s(void) {", name))
+	r.nodes(1, nodes)
+	r.line(0, "}")
+	return r.builder.String()
+}
+
+func (r *renderer) line(depth int, text string) {
+	r.builder.WriteString(strings.Repeat(indentation, depth))
+	r.builder.WriteString(text)
+	r.builder.WriteString("\n")
+}
+
+func (r *renderer) nodes(depth int, nodes []node) {
+	for _, n := range nodes {
+		r.node(depth, n)
+	}
+}
+
+func (r *renderer) node(depth int, n node) {
+	switch n := n.(type) {
+	case stmtNode:
+		r.line(depth, r.stmt(n.stmt))
+	case labelNode:
+		if r.targets[n.block] {
+			r.line(depth-1, r.dc.label(n.block)+":")
+		}
+	case gotoNode:
+		r.line(depth, fmt.Sprintf("goto %s;", r.dc.label(n.block)))
+	case breakNode:
+		r.line(depth, "break;")
+	case continueNode:
+		r.line(depth, "continue;")
+	case ifNode:
+		r.ifNode(depth, n, "if")
+	case loopNode:
+		switch {
+		case n.kind == whileLoop && r.isEmpty(n.body):
+			r.line(depth, fmt.Sprintf("while (%s);", r.cond(n.cond)))
+			r.nodes(depth+1, n.body)
+			return
+		case n.kind == whileLoop:
+			r.line(depth, fmt.Sprintf("while (%s) {", r.cond(n.cond)))
+		case n.kind == doWhileLoop:
+			r.line(depth, "do {")
+		default:
+			r.line(depth, "for (;;) {")
+		}
+		r.nodes(depth+1, n.body)
+		if n.kind == doWhileLoop {
+			r.line(depth, fmt.Sprintf("} while (%s);", r.cond(n.cond)))
+		} else {
+			r.line(depth, "}")
+		}
+	case switchNode:
+		r.line(depth, fmt.Sprintf("switch (%s) {", r.expr(n.index, 0)))
+		for i, target := range n.table.Targets {
+			r.line(depth, fmt.Sprintf("case %d: %s(); return;", i, r.codeLabel(target)))
+		}
+		r.line(depth, "}")
+	}
+}
+
+// isEmpty returns true if `nodes` only holds labels never jumped to.
+func (r *renderer) isEmpty(nodes []node) bool {
+	for _, n := range nodes {
+		if label, ok := n.(labelNode); !ok || r.targets[label.block] {
+			return false
+		}
+	}
+	return true
+}
+
+// endsFlow returns true if the execution never continues after `nodes`.
+func endsFlow(nodes []node) bool {
+	if len(nodes) == 0 {
+		return false
+	}
+	switch n := nodes[len(nodes)-1].(type) {
+	case breakNode, continueNode, gotoNode, switchNode:
+		return true
+	case stmtNode:
+		switch n.stmt.(type) {
+		case ir.Return, ir.Jump, ir.JumpIndirect:
+			return true
+		}
+	case ifNode:
+		return endsFlow(n.then) && endsFlow(n.els)
+	}
+	return false
+}
+
+// ifNode writes an if statement, flattening the else branch when the
+// then branch never completes and chaining else if.
+func (r *renderer) ifNode(depth int, n ifNode, keyword string) {
+	then, els, cond := n.then, n.els, n.cond
+	if _, ok := cond.(ir.Not); r.isEmpty(then) || (ok && !r.isEmpty(els)) {
+		then, els, cond = els, then, ir.Negate(cond)
+	}
+	if r.isEmpty(then) {
+		r.nodes(depth, els)
+		return
+	}
+	r.line(depth, fmt.Sprintf("%s (%s) {", keyword, r.cond(cond)))
+	r.nodes(depth+1, then)
+	switch {
+	case r.isEmpty(els):
+		r.line(depth, "}")
+		r.nodes(depth, els)
+	case endsFlow(then) && keyword == "if":
+		r.line(depth, "}")
+		r.nodes(depth, els)
+	case len(els) == 1 && isIf(els[0]):
+		r.ifNode(depth, els[0].(ifNode), "} else if")
+	default:
+		r.line(depth, "} else {")
+		r.nodes(depth+1, els)
+		r.line(depth, "}")
+	}
+}
+
+func isIf(n node) bool {
+	_, ok := n.(ifNode)
+	return ok
+}
+
+// stmt returns the code of a statement.
+func (r *renderer) stmt(s ir.Stmt) string {
+	switch s := s.(type) {
+	case ir.Assign:
+		return r.assign(s.Register.String(), ir.Reg{Register: s.Register}, s.Value)
+	case ir.Store:
+		return r.assign(r.memory(s.Address), ir.Load{Address: s.Address}, s.Value)
+	case ir.Store16:
+		location := ir.Word{Address: s.Address}
+		if c, ok := s.Value.(ir.Const); ok {
+			if label, ok := r.romLabel(c.Value); ok {
+				return fmt.Sprintf("%s = &%s;", r.expr(location, 0), label)
+			}
+		}
+		return r.assign(r.expr(location, 0), location, s.Value)
+	case ir.Eval:
+		return r.expr(s.Value, 0) + ";"
+	case ir.Push:
+		return fmt.Sprintf("push(%s);", r.expr(s.Value, 0))
+	case ir.Call:
+		return fmt.Sprintf("%s();", r.codeLabel(s.Target))
+	case ir.Return:
+		return "return;"
+	case ir.Jump:
+		return fmt.Sprintf("goto %s;", r.codeLabel(s.Target))
+	case ir.JumpIndirect:
+		return fmt.Sprintf("goto *%s;", r.expr(s.Target, unaryPrecedence))
+	case ir.Break:
+		return "brk();"
+	}
+	return fmt.Sprintf("/* %v */", s)
+}
+
+// assign returns an assignment, using ++, -- and compound
+// operators when `value` reads the assigned location.
+func (r *renderer) assign(lvalue string, location, value ir.Expr) string {
+	value = stripMask(value)
+	if b, ok := value.(ir.Binary); ok && compoundOps[b.Op] && b.Left == location {
+		if c, ok := b.Right.(ir.Const); ok && c.Value == 1 {
+			switch b.Op {
+			case ir.Add:
+				return lvalue + "++;"
+			case ir.Sub:
+				return lvalue + "--;"
+			}
+		}
+		return fmt.Sprintf("%s %s= %s;", lvalue, b.Op, r.expr(b.Right, 0))
+	}
+	return fmt.Sprintf("%s = %s;", lvalue, r.expr(value, 0))
+}
+
+// stripMask removes the masks keeping values in 8 or 16 bits,
+// implicit in the pseudocode.
+func stripMask(e ir.Expr) ir.Expr {
+	if b, ok := e.(ir.Binary); ok && b.Op == ir.And {
+		if c, ok := b.Right.(ir.Const); ok && (c.Value == 0xFF || c.Value == 0xFFFF) {
+			return b.Left
+		}
+	}
+	return e
+}
+
+// cond returns a condition.
+func (r *renderer) cond(e ir.Expr) string {
+	return r.expr(e, 0)
+}
+
+// expr returns an expression, parenthesized if its
+// precedence is lower than `precedence`.
+func (r *renderer) expr(e ir.Expr, precedence int) string {
+	e = stripMask(e)
+	switch e := e.(type) {
+	case ir.Const:
+		return constant(e.Value)
+	case ir.Reg:
+		return e.Register.String()
+	case ir.Load:
+		return r.memory(e.Address)
+	case ir.Word:
+		if index, ok := zeroPageIndex(e.Address); ok {
+			return fmt.Sprintf("word((u8)(%s))", r.expr(index, 0))
+		}
+		return fmt.Sprintf("word(%s)", r.expr(e.Address, 0))
+	case ir.Pull:
+		return "pull()"
+	case ir.Not:
+		return "!" + r.expr(e.Value, unaryPrecedence)
+	case ir.Binary:
+		if sign, ok := r.signTest(e); ok {
+			return parenthesize(sign, precedences[ir.Lt], precedence)
+		}
+		p := precedences[e.Op]
+		text := fmt.Sprintf("%s %s %s", r.expr(e.Left, p), e.Op, r.expr(e.Right, p+1))
+		return parenthesize(text, p, precedence)
+	}
+	return fmt.Sprintf("%v", e)
+}
+
+// signTest writes the tests of bit 7 as signed comparisons:
+//  x >= 0x80 -> (s8)x < 0
+func (r *renderer) signTest(e ir.Binary) (string, bool) {
+	c, ok := e.Right.(ir.Const)
+	if !ok || c.Value != 0x80 || (e.Op != ir.Ge && e.Op != ir.Lt) {
+		return "", false
+	}
+	if max := ir.MaxValue(e.Left); max < 0 || max > 0xFF {
+		return "", false
+	}
+	op := "<"
+	if e.Op == ir.Lt {
+		op = ">="
+	}
+	return fmt.Sprintf("(s8)%s %s 0", r.expr(e.Left, unaryPrecedence), op), true
+}
+
+func parenthesize(text string, p, precedence int) string {
+	if p < precedence {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// constant returns small values in decimal, other ones in hexadecimal.
+func constant(value int) string {
+	switch {
+	case value < 0:
+		return "-" + constant(-value)
+	case value < 10:
+		return fmt.Sprintf("%d", value)
+	case value <= 0xFF:
+		return fmt.Sprintf("0x%02X", value)
+	}
+	return fmt.Sprintf("0x%04X", value)
+}
+
+// zeroPageIndex returns the indexed address of a zero page
+// operand, whose mask wrapping it to $00xx must be kept, e.g.
+// ($F0 + X) & $FF, rendered as (u8)(0xF0 + X).
+func zeroPageIndex(address ir.Expr) (ir.Expr, bool) {
+	if b, ok := address.(ir.Binary); ok && b.Op == ir.And {
+		if c, ok := b.Right.(ir.Const); ok && c.Value == 0xFF {
+			return b.Left, true
+		}
+	}
+	return nil, false
+}
+
+// memory returns the memory location at an address,
+// e.g. mem[0x0300 + X], mem[(u8)(0xF0 + X)] or table[X]
+// for labeled ROM data.
+func (r *renderer) memory(address ir.Expr) string {
+	if index, ok := zeroPageIndex(address); ok {
+		return fmt.Sprintf("mem[(u8)(%s)]", r.expr(index, 0))
+	}
+	address = stripMask(address)
+	switch a := address.(type) {
+	case ir.Const:
+		if label, ok := r.romLabel(a.Value); ok {
+			return label
+		}
+		return fmt.Sprintf("mem[%s]", r.address(a.Value))
+	case ir.Binary:
+		if base, ok := a.Left.(ir.Const); ok && a.Op == ir.Add {
+			if label, ok := r.romLabel(base.Value); ok {
+				return fmt.Sprintf("%s[%s]", label, r.expr(a.Right, 0))
+			}
+			return fmt.Sprintf("mem[%s + %s]", r.address(base.Value), r.expr(a.Right, precedences[ir.Add]+1))
+		}
+	}
+	return fmt.Sprintf("mem[%s]", r.expr(address, 0))
+}
+
+// address returns a zero page address on 2 digits, other ones on 4.
+func (r *renderer) address(value int) string {
+	if value <= 0xFF {
+		return fmt.Sprintf("0x%02X", value)
+	}
+	return fmt.Sprintf("0x%04X", value)
+}
+
+// romLabel returns the label of a PRG ROM address.
+func (r *renderer) romLabel(address int) (string, bool) {
+	if address < 0x8000 || address > 0xFFFF {
+		return "", false
+	}
+	offset, ok := r.dc.d.Resolve(r.dc.bank, uint16(address))
+	if !ok {
+		return "", false
+	}
+	return r.dc.d.Label(offset)
+}
+
+// codeLabel returns the label of a code address, e.g. "sub_C020".
+func (r *renderer) codeLabel(address uint16) string {
+	if label, ok := r.romLabel(int(address)); ok {
+		return label
+	}
+	return fmt.Sprintf("sub_%04X", address)
+}
@@ -0,0 +1,440 @@
+package pseudoc
+
+import (
+	"github.com/vpenando/nes-rom-decompiler/ir"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// node is a statement of the structured output.
+type node interface{}
+
+type stmtNode struct {
+	stmt ir.Stmt
+}
+
+// labelNode marks the start of a block, rendered if it is a goto target.
+type labelNode struct {
+	block *block
+}
+
+type gotoNode struct {
+	block *block
+}
+
+type ifNode struct {
+	cond ir.Expr
+	then []node
+	els  []node
+}
+
+type loopKind int
+
+const (
+	whileLoop loopKind = iota
+	doWhileLoop
+	foreverLoop
+)
+
+type loopNode struct {
+	kind loopKind
+	cond ir.Expr
+	body []node
+}
+
+type breakNode struct{}
+
+type continueNode struct{}
+
+type switchNode struct {
+	index ir.Expr
+	table *nes.Table
+}
+
+// loop is a natural loop of the control-flow graph.
+type loop struct {
+	header  *block
+	body    map[*block]bool
+	latches []*block // Blocks jumping back to the header
+	follow  *block   // Block executed after the loop, if any
+}
+
+// loopContext tells how to leave the loop being structured.
+type loopContext struct {
+	*loop
+	latch   *block // Block evaluating the condition of a do-while
+	entered bool   // The header was emitted
+}
+
+// structurer turns the control-flow graph into nested statements.
+type structurer struct {
+	dc      *decompiler
+	order   []*block // Reverse postorder
+	idom    map[*block]*block
+	ipdom   map[*block]*block
+	loops   map[*block]*loop
+	emitted map[*block]bool
+	targets map[*block]bool // Blocks reached by a goto
+}
+
+func newStructurer(dc *decompiler) *structurer {
+	s := &structurer{
+		dc:      dc,
+		loops:   map[*block]*loop{},
+		emitted: map[*block]bool{},
+		targets: map[*block]bool{},
+	}
+	for _, b := range dc.blocks {
+		if n := len(b.stmts); n > 0 {
+			if branch, ok := b.stmts[n-1].(ir.Branch); ok {
+				b.stmts = b.stmts[:n-1]
+				b.cond = branch.Cond
+			}
+		}
+	}
+	s.order = reversePostorder(dc.blocks[0], func(b *block) []*block { return b.succs })
+	s.idom = dominators(s.order, func(b *block) []*block { return b.preds })
+	s.postDominators()
+	s.findLoops()
+	return s
+}
+
+// reversePostorder returns the blocks reachable from `entry`
+// in reverse postorder.
+func reversePostorder(entry *block, succs func(*block) []*block) []*block {
+	visited := map[*block]bool{}
+	var order []*block
+	var visit func(b *block)
+	visit = func(b *block) {
+		visited[b] = true
+		for _, succ := range succs(b) {
+			if !visited[succ] {
+				visit(succ)
+			}
+		}
+		order = append(order, b)
+	}
+	visit(entry)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	return order
+}
+
+// dominators returns the immediate dominator of every block of `order`,
+// computed as described in "A Simple, Fast Dominance Algorithm"
+// (Cooper, Harvey and Kennedy). The entry is its own dominator.
+func dominators(order []*block, preds func(*block) []*block) map[*block]*block {
+	index := map[*block]int{}
+	for i, b := range order {
+		index[b] = i
+	}
+	idom := map[*block]*block{order[0]: order[0]}
+	intersect := func(a, b *block) *block {
+		for a != b {
+			for index[a] > index[b] {
+				a = idom[a]
+			}
+			for index[b] > index[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range order[1:] {
+			var dom *block
+			for _, pred := range preds(b) {
+				if _, ok := idom[pred]; !ok {
+					continue
+				}
+				if dom == nil {
+					dom = pred
+				} else {
+					dom = intersect(pred, dom)
+				}
+			}
+			if dom != nil && idom[b] != dom {
+				idom[b] = dom
+				changed = true
+			}
+		}
+	}
+	return idom
+}
+
+// dominates returns true if every path from the entry to `b` goes through `a`.
+func dominates(idom map[*block]*block, a, b *block) bool {
+	for {
+		if a == b {
+			return true
+		}
+		dom, ok := idom[b]
+		if !ok || dom == b {
+			return false
+		}
+		b = dom
+	}
+}
+
+// postDominators computes the immediate post-dominators, using
+// a virtual exit block following every block without successor.
+// Blocks which never reach an exit have no post-dominator.
+func (s *structurer) postDominators() {
+	exit := &block{index: -1}
+	for _, b := range s.order {
+		if len(b.succs) == 0 {
+			exit.preds = append(exit.preds, b)
+		}
+	}
+	succs := func(b *block) []*block {
+		if b == exit {
+			return nil
+		}
+		if len(b.succs) == 0 {
+			return []*block{exit}
+		}
+		return b.succs
+	}
+	order := reversePostorder(exit, func(b *block) []*block {
+		if b == exit {
+			return exit.preds
+		}
+		return b.preds
+	})
+	ipdom := dominators(order, succs)
+	s.ipdom = map[*block]*block{}
+	for b, dom := range ipdom {
+		if b != exit && dom != exit {
+			s.ipdom[b] = dom
+		}
+	}
+}
+
+// findLoops finds the natural loops: a back edge goes to a block
+// dominating its source, and the loop is made of the blocks
+// reaching the source without going through the header.
+func (s *structurer) findLoops() {
+	for _, b := range s.order {
+		for _, succ := range b.succs {
+			if !dominates(s.idom, succ, b) {
+				continue
+			}
+			l, ok := s.loops[succ]
+			if !ok {
+				l = &loop{header: succ, body: map[*block]bool{succ: true}}
+				s.loops[succ] = l
+			}
+			l.latches = append(l.latches, b)
+			stack := []*block{b}
+			for len(stack) > 0 {
+				current := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				if l.body[current] {
+					continue
+				}
+				l.body[current] = true
+				stack = append(stack, current.preds...)
+			}
+		}
+	}
+	for _, l := range s.loops {
+		l.follow = s.loopFollow(l)
+	}
+}
+
+// loopFollow returns the block executed after a loop: the exit of its
+// header or latch condition, or else its first exit in reverse postorder.
+func (s *structurer) loopFollow(l *loop) *block {
+	exitOf := func(b *block) *block {
+		if b.cond == nil {
+			return nil
+		}
+		for _, succ := range b.succs {
+			if !l.body[succ] {
+				return succ
+			}
+		}
+		return nil
+	}
+	if follow := exitOf(l.header); follow != nil {
+		return follow
+	}
+	if len(l.latches) == 1 {
+		if follow := exitOf(l.latches[0]); follow != nil {
+			return follow
+		}
+	}
+	for _, b := range s.order {
+		if l.body[b] {
+			continue
+		}
+		for _, pred := range b.preds {
+			if l.body[pred] {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// structure returns the structured statements of the function.
+// Blocks the structuring missed are appended behind labels.
+func (s *structurer) structure() []node {
+	nodes := s.region(s.order[0], nil, nil)
+	for _, b := range s.order {
+		if !s.emitted[b] {
+			s.targets[b] = true
+			nodes = append(nodes, s.region(b, nil, nil)...)
+		}
+	}
+	if n := len(nodes); n > 0 {
+		if stmt, ok := nodes[n-1].(stmtNode); ok {
+			if ret, ok := stmt.stmt.(ir.Return); ok && !ret.Interrupt {
+				nodes = nodes[:n-1]
+			}
+		}
+	}
+	return nodes
+}
+
+// region returns the statements executed from `b` until `stop`.
+func (s *structurer) region(b, stop *block, ctx *loopContext) []node {
+	var nodes []node
+	for b != nil && b != stop {
+		if ctx != nil {
+			if b == ctx.follow {
+				return append(nodes, breakNode{})
+			}
+			if b == ctx.header && ctx.entered {
+				return append(nodes, continueNode{})
+			}
+		}
+		if s.emitted[b] {
+			s.targets[b] = true
+			return append(nodes, gotoNode{b})
+		}
+		if l, ok := s.loops[b]; ok && (ctx == nil || ctx.header != b) {
+			nodes = append(nodes, s.loop(l)...)
+			b = l.follow
+			continue
+		}
+		s.emitted[b] = true
+		if ctx != nil && b == ctx.header {
+			ctx.entered = true
+		}
+		nodes = append(nodes, labelNode{b})
+		for _, stmt := range b.stmts {
+			nodes = append(nodes, stmtNode{stmt})
+		}
+		if ctx != nil && b == ctx.latch {
+			return nodes
+		}
+		switch {
+		case b.dispatch != nil:
+			return append(nodes, s.switchNode(b))
+		case b.cond == nil:
+			b = b.next
+			continue
+		}
+		if b.taken == b.next {
+			b = b.next
+			continue
+		}
+		if b.taken == nil {
+			// The branch leaves the decoded code
+			target, _ := b.cfg.Last().Target()
+			nodes = append(nodes, ifNode{cond: b.cond, then: []node{stmtNode{ir.Jump{Target: target}}}})
+			b = b.next
+			continue
+		}
+		follow := s.ipdom[b]
+		if ctx != nil && follow != nil && !ctx.body[follow] {
+			// Both branches leave the loop: each one breaks
+			follow = nil
+		}
+		then := s.region(b.taken, follow, ctx)
+		els := s.region(b.next, follow, ctx)
+		nodes = append(nodes, ifNode{b.cond, then, els})
+		b = follow
+	}
+	return nodes
+}
+
+// loop returns the statements of a loop.
+func (s *structurer) loop(l *loop) []node {
+	header := l.header
+	ctx := &loopContext{loop: l}
+	if len(header.stmts) == 0 && header.cond != nil && l.follow != nil &&
+		(header.taken == l.follow) != (header.next == l.follow) {
+		// while (cond) { ... }
+		s.emitted[header] = true
+		ctx.entered = true
+		cond, inside := header.cond, header.taken
+		if header.taken == l.follow {
+			cond, inside = ir.Negate(cond), header.next
+		}
+		body := s.region(inside, nil, ctx)
+		return []node{labelNode{header}, loopNode{whileLoop, cond, trimContinue(body)}}
+	}
+	if len(l.latches) == 1 {
+		latch := l.latches[0]
+		if latch.cond != nil && latch.taken != latch.next &&
+			(latch.taken == header || latch.next == header) &&
+			(latch.taken == l.follow || latch.next == l.follow) {
+			// do { ... } while (cond);
+			ctx.latch = latch
+			cond := latch.cond
+			if latch.next == header {
+				cond = ir.Negate(cond)
+			}
+			body := s.region(header, nil, ctx)
+			return []node{loopNode{doWhileLoop, cond, body}}
+		}
+	}
+	// for (;;) { ... }
+	return []node{loopNode{foreverLoop, nil, trimContinue(s.region(header, nil, ctx))}}
+}
+
+// trimContinue removes the continue statements ending a loop body.
+func trimContinue(nodes []node) []node {
+	n := len(nodes)
+	if n == 0 {
+		return nodes
+	}
+	switch last := nodes[n-1].(type) {
+	case continueNode:
+		return nodes[:n-1]
+	case ifNode:
+		last.then = trimContinue(last.then)
+		last.els = trimContinue(last.els)
+		nodes[n-1] = last
+	}
+	return nodes
+}
+
+// switchNode returns the switch dispatching through the jump table
+// ending a block. The index is A for jump engines (JSR followed by
+// the table); otherwise it is the register indexing the table,
+// halved for word tables.
+func (s *structurer) switchNode(b *block) node {
+	var index ir.Expr = ir.Reg{Register: ir.A}
+	instructions := b.cfg.Instructions
+	if last := b.cfg.Last(); !last.IsCall() {
+		for i := len(instructions) - 1; i >= 0; i-- {
+			inst := instructions[i]
+			if inst.Mnemonic != "LDA" || (inst.Mode != nes.AbsoluteX && inst.Mode != nes.AbsoluteY) {
+				continue
+			}
+			index = ir.Reg{Register: ir.X}
+			if inst.Mode == nes.AbsoluteY {
+				index = ir.Reg{Register: ir.Y}
+			}
+			if b.dispatch.Kind == nes.WordTable {
+				index = ir.Bin(ir.Shr, index, ir.Const{Value: 1})
+			}
+			break
+		}
+	}
+	return switchNode{index, b.dispatch}
+}
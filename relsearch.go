@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["relsearch"] = command{
+		run:   runRelsearch,
+		usage: "relsearch [-o XXX.tbl] XXX.nes WORD: find a word encoded with an unknown table in PRG and CHR ROM",
+	}
+}
+
+func runRelsearch(args []string) error {
+	flags := flag.NewFlagSet("relsearch", flag.ExitOnError)
+	output := flags.String("o", "", "Draft text table (*.tbl) for the most frequent base")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: relsearch [-o XXX.tbl] XXX.nes WORD")
+	}
+
+	word := flags.Arg(1)
+	rom, err := readRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	d := rom.PrgRomReader().Disassembly()
+	if err := applyProject(projectPath(flags.Arg(0)), false, d); err != nil {
+		return err
+	}
+	prgMatches, err := nes.RelativeSearch(d.Prg(), word)
+	if err != nil {
+		return err
+	}
+	chrMatches, _ := nes.RelativeSearch(rom.Chr, word)
+	if len(prgMatches)+len(chrMatches) == 0 {
+		return fmt.Errorf("no match for '%s'", word)
+	}
+
+	var lines []string
+	counts := map[byte]int{}
+	for _, match := range prgMatches {
+		lines = append(lines, fmt.Sprintf("PRG %06X %-9s base $%s", match.Offset, d.Location(match.Offset), nes.ByteToHexString(match.Base)))
+		counts[match.Base]++
+	}
+	for _, match := range chrMatches {
+		lines = append(lines, fmt.Sprintf("CHR %06X %-9s base $%s", match.Offset, "-", nes.ByteToHexString(match.Base)))
+		counts[match.Base]++
+	}
+	fmt.Println(strings.Join(lines, "\n"))
+	if *output == "" {
+		return nil
+	}
+	best := -1
+	for base, count := range counts {
+		if best < 0 || count > counts[byte(best)] || count == counts[byte(best)] && int(base) < best {
+			best = int(base)
+		}
+	}
+	return writeOutput(*output, nes.RelativeTable(byte(best), word[0] >= 'a'))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	commands["strings"] = command{
+		run:   runStrings,
+		usage: "strings [-tbl XXX.tbl] [-n 4] [-o XXX.txt] XXX.nes: list the strings of the PRG ROM",
+	}
+}
+
+func runStrings(args []string) error {
+	flags := flag.NewFlagSet("strings", flag.ExitOnError)
+	tbl := flags.String("tbl", "", "Text table (*.tbl), ASCII by default")
+	minLength := flags.Int("n", 4, "Minimum number of letters or digits")
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: strings [-tbl XXX.tbl] [-n 4] [-o XXX.txt] XXX.nes")
+	}
+
+	rom, err := readRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	d := rom.PrgRomReader().Disassembly()
+	if err := applyProject(projectPath(flags.Arg(0)), false, d); err != nil {
+		return err
+	}
+	if *tbl != "" {
+		table, err := readTextTable(*tbl)
+		if err != nil {
+			return err
+		}
+		d.SetTextTable(table)
+	}
+	d.AddVectors()
+	d.Analyze()
+	var lines []string
+	for _, s := range d.FindStrings(*minLength) {
+		lines = append(lines, fmt.Sprintf("%06X %-9s %q", s.Offset, d.Location(s.Offset), s.Text))
+	}
+	if len(lines) == 0 {
+		return errors.New("no string found")
+	}
+	return writeOutput(*output, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["timing"] = command{
+		run:   runTiming,
+		usage: "timing [-region ntsc|pal|dendy] [-o XXX.txt] XXX.nes: estimate the worst-case cycles of the NMI handler against vblank",
+	}
+}
+
+// formatLocations returns the locations of PRG ROM offsets.
+func formatLocations(d *nes.Disassembly, offsets []int) string {
+	locations := make([]string, len(offsets))
+	for i, offset := range offsets {
+		locations[i] = d.Location(offset)
+	}
+	return strings.Join(locations, ", ")
+}
+
+func runTiming(args []string) error {
+	flags := flag.NewFlagSet("timing", flag.ExitOnError)
+	regionName := flags.String("region", "", "ntsc, pal or dendy, from the NES 2.0 or UNIF header by default")
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: timing [-region ntsc|pal|dendy] [-o XXX.txt] XXX.nes")
+	}
+
+	rom, err := readRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	region := rom.Region
+	if *regionName != "" {
+		if region, err = nes.ParseRegion(*regionName); err != nil {
+			return err
+		}
+	}
+	d, err := analyzeRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	offset, ok := d.Vector(nes.NmiVector)
+	if !ok {
+		return errors.New("no NMI vector")
+	}
+	graph := cfg.Build(d)
+	nmi, ok := graph.Function(offset)
+	if !ok {
+		return errors.New("the NMI handler is not decoded")
+	}
+
+	timing := graph.WorstCase(nmi)
+	vblank := region.VblankCycles()
+	lines := []string{
+		fmt.Sprintf("NMI handler %s (%s), %s vblank: %d cycles", nmi.Name, d.Location(offset), region, vblank),
+		fmt.Sprintf("Whole handler: %d cycles", cfg.InterruptCycles+timing.Total),
+	}
+	if timing.PPUWrite < 0 {
+		lines = append(lines, "No PPU write")
+	} else {
+		cycles := cfg.InterruptCycles + timing.PPUWrite
+		lines = append(lines, fmt.Sprintf("Up to the last PPU write (%s): %d cycles, %d%% of vblank",
+			d.Location(timing.PPUWriteAt), cycles, 100*cycles/vblank))
+		if cycles > vblank {
+			lines = append(lines, fmt.Sprintf("Warning: the last PPU write may happen %d cycles after the end of vblank", cycles-vblank))
+		}
+	}
+	if timing.DMA {
+		lines = append(lines, "Note: the OAM DMA is counted as 514 cycles")
+	}
+	if len(timing.Loops) > 0 {
+		lines = append(lines, fmt.Sprintf("Note: the loops at %s are counted as a single iteration", formatLocations(d, timing.Loops)))
+	}
+	if len(timing.Indirect) > 0 {
+		lines = append(lines, fmt.Sprintf("Warning: the indirect jumps at %s are not followed", formatLocations(d, timing.Indirect)))
+	} else if !timing.IsBounded() {
+		lines = append(lines, "Warning: some calls could not be timed (recursion or undecoded subroutines)")
+	}
+	return writeOutput(*output, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+	"github.com/vpenando/nes-rom-decompiler/tui"
+)
+
+func init() {
+	commands["tui"] = command{
+		run:   runTui,
+		usage: "tui [-project XXX.json] XXX.nes: browse the disassembly interactively, saving names and comments to the project file",
+	}
+}
+
+// readProject returns the project file at `path`,
+// or a new project if there is none.
+func readProject(path string) (*nes.Project, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nes.NewProject(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return nes.ReadProject(file)
+}
+
+// writeProject writes a project file.
+func writeProject(path string, project *nes.Project) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return project.Write(file)
+}
+
+func runTui(args []string) error {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	project := flags.String("project", "", "Project file (*.json), XXX.json by default")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return errors.New("usage: tui [-project XXX.json] XXX.nes")
+	}
+
+	rom, err := readRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *project == "" {
+		*project = projectPath(flags.Arg(0))
+	}
+	p, err := readProject(*project)
+	if err != nil {
+		return err
+	}
+	view, err := tui.NewView(p, func(project *nes.Project) (*nes.Disassembly, error) {
+		disassembly := rom.PrgRomReader().Disassembly()
+		if err := project.Apply(disassembly); err != nil {
+			return nil, err
+		}
+		disassembly.AddVectors()
+		disassembly.Analyze()
+		return disassembly, nil
+	})
+	if err != nil {
+		return err
+	}
+	terminal := tui.NewTerminal(view, os.Stdin, os.Stdout)
+	terminal.Save = func(view *tui.View) error {
+		return writeProject(*project, view.Project)
+	}
+	return terminal.Run()
+}
@@ -0,0 +1,259 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Keys, as read from a terminal in raw mode.
+const (
+	keyUp       = "\x1b[A"
+	keyDown     = "\x1b[B"
+	keyPageUp   = "\x1b[5~"
+	keyPageDown = "\x1b[6~"
+	keyHome     = "\x1b[H"
+	keyEnd      = "\x1b[F"
+	keyEscape   = "\x1b"
+	keyEnter    = "\r"
+	keyBack     = "\x7f"
+	keyCtrlC    = "\x03"
+)
+
+const help = "g:goto enter:follow esc:back x:xrefs n:rename ;:comment q:quit"
+
+// stty runs stty on the terminal, e.g. stty("-g").
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+// Terminal draws a view on a terminal in raw mode
+// and handles the keys.
+type Terminal struct {
+	View   *View
+	Save   func(view *View) error // Called when the project changes
+	in     *bufio.Reader
+	out    io.Writer
+	width  int
+	height int
+	status string
+}
+
+// NewTerminal returns a terminal reading the keys from `in`,
+// and drawing on `out`.
+func NewTerminal(view *View, in io.Reader, out io.Writer) *Terminal {
+	return &Terminal{View: view, in: bufio.NewReader(in), out: out, width: 80, height: 24, status: help}
+}
+
+// Run sets the terminal in raw mode and handles
+// the keys until 'q' is pressed.
+func (term *Terminal) Run() error {
+	state, err := stty("-g")
+	if err != nil {
+		return fmt.Errorf("not a terminal: %s", err)
+	}
+	if size, err := stty("size"); err == nil {
+		fmt.Sscanf(size, "%d %d", &term.height, &term.width)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return err
+	}
+	defer func() {
+		stty(state)
+		fmt.Fprint(term.out, "\x1b[2J\x1b[H")
+	}()
+	for {
+		term.draw(nil, 0)
+		key, err := term.readKey()
+		if err != nil {
+			return err
+		}
+		if key == "q" || key == keyCtrlC {
+			return nil
+		}
+		term.status = help
+		if err := term.handle(key); err != nil {
+			term.status = "Error: " + err.Error()
+		}
+	}
+}
+
+// readKey returns a key, or an escape sequence.
+func (term *Terminal) readKey() (string, error) {
+	b, err := term.in.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != 0x1b || term.in.Buffered() == 0 {
+		return string(b), nil
+	}
+	key := []byte{b}
+	for term.in.Buffered() > 0 {
+		b, _ := term.in.ReadByte()
+		key = append(key, b)
+		if len(key) > 2 && (b >= 'A' && b <= 'Z' || b == '~') {
+			break
+		}
+	}
+	return string(key), nil
+}
+
+// handle runs the action of a key.
+func (term *Terminal) handle(key string) error {
+	view := term.View
+	page := term.height - 2
+	switch key {
+	case "j", keyDown:
+		view.Move(1)
+	case "k", keyUp:
+		view.Move(-1)
+	case " ", keyPageDown:
+		view.Move(page)
+	case "b", keyPageUp:
+		view.Move(-page)
+	case keyHome:
+		view.Move(-view.Len())
+	case keyEnd:
+		view.Move(view.Len())
+	case keyEnter:
+		return view.Follow()
+	case keyEscape, keyBack:
+		if !view.Back() {
+			term.status = "No previous location"
+		}
+	case "g":
+		if target, ok := term.prompt("Go to: "); ok && target != "" {
+			return view.Jump(target)
+		}
+	case "x":
+		return term.xrefs()
+	case "n":
+		if name, ok := term.prompt("Name (empty to remove): "); ok {
+			if err := view.Rename(name); err != nil {
+				return err
+			}
+			return term.Save(view)
+		}
+	case ";":
+		if comment, ok := term.prompt("Comment (empty to remove): "); ok {
+			if err := view.Comment(comment); err != nil {
+				return err
+			}
+			return term.Save(view)
+		}
+	}
+	return nil
+}
+
+// prompt reads a line on the status bar.
+// It returns false if escape is pressed.
+func (term *Terminal) prompt(label string) (string, bool) {
+	var line []byte
+	for {
+		term.status = label + string(line)
+		term.draw(nil, 0)
+		key, err := term.readKey()
+		if err != nil {
+			return "", false
+		}
+		switch {
+		case key == keyEnter:
+			return strings.TrimSpace(string(line)), true
+		case key == keyEscape || key == keyCtrlC:
+			return "", false
+		case key == keyBack:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case len(key) == 1 && key[0] >= ' ':
+			line = append(line, key[0])
+		}
+	}
+}
+
+// xrefs shows the references to the location under the cursor
+// in a popup, and jumps to the chosen one.
+func (term *Terminal) xrefs() error {
+	xrefs := term.View.Xrefs()
+	if len(xrefs) == 0 {
+		term.status = "No reference"
+		return nil
+	}
+	selected := 0
+	for {
+		term.status = strconv.Itoa(len(xrefs)) + " references, enter:go esc:close"
+		term.draw(xrefs, selected)
+		key, err := term.readKey()
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "j", keyDown:
+			if selected < len(xrefs)-1 {
+				selected++
+			}
+		case "k", keyUp:
+			if selected > 0 {
+				selected--
+			}
+		case keyEnter:
+			term.View.JumpToOffset(xrefs[selected].Offset)
+			term.status = help
+			return nil
+		case keyEscape, "q", "x":
+			term.status = help
+			return nil
+		}
+	}
+}
+
+// fit truncates or pads a line to the width of the terminal.
+func (term *Terminal) fit(line string, width int) string {
+	line = strings.Replace(line, "\t", "    ", -1)
+	if len(line) > width {
+		return line[:width]
+	}
+	return line + strings.Repeat(" ", width-len(line))
+}
+
+// draw draws the listing, the popup if any, and the status bar.
+func (term *Terminal) draw(popup []Xref, selected int) {
+	var builder strings.Builder
+	builder.WriteString("\x1b[H")
+	rows, cursor := term.View.Rows(term.height - 1)
+	for i := 0; i < term.height-1; i++ {
+		line := ""
+		if i < len(rows) {
+			line = rows[i]
+		}
+		line = term.fit(line, term.width)
+		if i == cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		builder.WriteString(line + "\r\n")
+	}
+	builder.WriteString("\x1b[1m" + term.fit(term.status, term.width-1) + "\x1b[0m")
+
+	// The popup is drawn over the listing, at the top right
+	width := term.width / 2
+	for i, xref := range popup {
+		if i >= term.height-2 {
+			break
+		}
+		line := term.fit(" "+xref.Text, width)
+		if i == selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		} else {
+			line = "\x1b[44m" + line + "\x1b[0m"
+		}
+		builder.WriteString(fmt.Sprintf("\x1b[%d;%dH%s", i+2, term.width-width, line))
+	}
+	fmt.Fprint(term.out, builder.String())
+}
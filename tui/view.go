@@ -0,0 +1,289 @@
+// Package tui is an interactive terminal view of a disassembly,
+// which renames labels and comments locations in a project file.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+// Analyzer returns the analyzed disassembly of the ROM,
+// with a project applied.
+type Analyzer func(project *nes.Project) (*nes.Disassembly, error)
+
+// row is a line of the screen.
+type row struct {
+	offset int // PRG ROM offset, -1 for the RAM layout and .org lines
+	text   string
+}
+
+// View is a scrollable listing with a cursor, which can follow
+// the operands of the instructions and go back.
+type View struct {
+	Disassembly *nes.Disassembly
+	Project     *nes.Project
+	Cursor      int // Row of the cursor
+	Top         int // First visible row
+	analyze     Analyzer
+	rows        []row
+	history     []location
+}
+
+// location is a previous position of the cursor.
+type location struct {
+	offset int // PRG ROM offset
+	row    int // Row in the line, e.g. 1 for the instruction below a label
+}
+
+// NewView returns the view of the disassembly of a project,
+// with the cursor on the first PRG ROM line.
+func NewView(project *nes.Project, analyze Analyzer) (*View, error) {
+	view := &View{Project: project, analyze: analyze}
+	if err := view.reload(); err != nil {
+		return nil, err
+	}
+	for i, row := range view.rows {
+		if row.offset >= 0 {
+			view.Cursor = i
+			break
+		}
+	}
+	return view, nil
+}
+
+// reload analyzes the ROM again, e.g. after a label was renamed.
+func (view *View) reload() error {
+	d, err := view.analyze(view.Project)
+	if err != nil {
+		return err
+	}
+	view.Disassembly = d
+	view.rows = view.rows[:0]
+	for _, line := range d.Listing() {
+		for _, text := range strings.Split(line.String(), "\n") {
+			view.rows = append(view.rows, row{line.Offset, text})
+		}
+	}
+	return nil
+}
+
+// Len returns the number of rows.
+func (view *View) Len() int {
+	return len(view.rows)
+}
+
+// Offset returns the PRG ROM offset of the cursor,
+// or false on the RAM layout.
+func (view *View) Offset() (int, bool) {
+	if view.Cursor >= len(view.rows) {
+		return 0, false
+	}
+	offset := view.rows[view.Cursor].offset
+	return offset, offset >= 0
+}
+
+// Move moves the cursor by `delta` rows.
+func (view *View) Move(delta int) {
+	view.Cursor += delta
+	if view.Cursor >= len(view.rows) {
+		view.Cursor = len(view.rows) - 1
+	}
+	if view.Cursor < 0 {
+		view.Cursor = 0
+	}
+}
+
+// firstRow returns the first row of the line
+// holding a PRG ROM offset.
+func (view *View) firstRow(offset int) (int, bool) {
+	first := -1
+	for i, row := range view.rows {
+		if row.offset >= 0 && row.offset <= offset && (first < 0 || row.offset > view.rows[first].offset) {
+			first = i
+		}
+	}
+	return first, first >= 0
+}
+
+// moveTo moves the cursor to the line holding a PRG ROM offset.
+func (view *View) moveTo(offset int) bool {
+	first, ok := view.firstRow(offset)
+	if ok {
+		view.Cursor = first
+	}
+	return ok
+}
+
+// jump moves the cursor to a PRG ROM offset,
+// remembering the current location.
+func (view *View) jump(offset int) bool {
+	target, ok := view.firstRow(offset)
+	if !ok {
+		return false
+	}
+	if current, ok := view.Offset(); ok {
+		first, _ := view.firstRow(current)
+		view.history = append(view.history, location{current, view.Cursor - first})
+	}
+	view.Cursor = target
+	return true
+}
+
+// Jump moves the cursor to a label or a location,
+// e.g. "reset", "$C012" or "$02:8012".
+func (view *View) Jump(target string) error {
+	d := view.Disassembly
+	offset, ok := d.ParseLocation(target)
+	if !ok {
+		for labelOffset, label := range d.Labels() {
+			if label == target {
+				offset, ok = labelOffset, true
+				break
+			}
+		}
+	}
+	if !ok || !view.jump(offset) {
+		return fmt.Errorf("unknown location '%s'", target)
+	}
+	return nil
+}
+
+// JumpToOffset moves the cursor to a PRG ROM offset.
+func (view *View) JumpToOffset(offset int) bool {
+	return view.jump(offset)
+}
+
+// Follow moves the cursor to the ROM location
+// referenced by the instruction under the cursor.
+func (view *View) Follow() error {
+	d := view.Disassembly
+	offset, ok := view.Offset()
+	if !ok {
+		return errors.New("no instruction")
+	}
+	inst, ok := d.Instruction(offset)
+	if !ok || d.Kind(offset) != nes.CodeByte {
+		return errors.New("no instruction")
+	}
+	address, ok := inst.OperandAddress()
+	if !ok {
+		return errors.New("no operand address")
+	}
+	target, ok := d.Resolve(d.BankAt(offset).Index, address)
+	if !ok || !view.jump(target) {
+		return fmt.Errorf("%s is not in PRG ROM", nes.WordToAddress(address))
+	}
+	return nil
+}
+
+// Back moves the cursor to the location before the last jump.
+func (view *View) Back() bool {
+	if len(view.history) == 0 {
+		return false
+	}
+	previous := view.history[len(view.history)-1]
+	view.history = view.history[:len(view.history)-1]
+	if !view.moveTo(previous.offset) {
+		return false
+	}
+	for i := 0; i < previous.row && view.Cursor+1 < len(view.rows) && view.rows[view.Cursor+1].offset == previous.offset; i++ {
+		view.Cursor++
+	}
+	return true
+}
+
+// Xref is a reference to the location under the cursor.
+type Xref struct {
+	Offset int // PRG ROM offset of the instruction
+	Text   string
+}
+
+// Xrefs returns the instructions referencing
+// the location under the cursor.
+//  "$C00F call  JSR sub_C020"
+func (view *View) Xrefs() []Xref {
+	d := view.Disassembly
+	offset, ok := view.Offset()
+	if !ok {
+		return nil
+	}
+	var xrefs []Xref
+	for _, xref := range d.XrefsToOffset(offset) {
+		xrefs = append(xrefs, Xref{xref.From.Offset, fmt.Sprintf("%s %-5s %s",
+			d.Location(xref.From.Offset), xref.Kind, d.FormatInstruction(xref.From))})
+	}
+	return xrefs
+}
+
+// labelRegexp matches the names accepted by the assemblers.
+var labelRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Rename names the location under the cursor,
+// or removes its name if `name` is empty.
+func (view *View) Rename(name string) error {
+	d := view.Disassembly
+	offset, ok := view.Offset()
+	if !ok {
+		return errors.New("no location")
+	}
+	if name != "" && !labelRegexp.MatchString(name) {
+		return fmt.Errorf("invalid name '%s'", name)
+	}
+	for labelOffset, label := range d.Labels() {
+		if name != "" && label == name && labelOffset != offset {
+			return fmt.Errorf("'%s' is already the name of %s", name, d.Location(labelOffset))
+		}
+	}
+	for _, variable := range d.Variables() {
+		if name != "" && variable.Name == name {
+			return fmt.Errorf("'%s' is already the name of %s", name, nes.WordToAddress(variable.Address))
+		}
+	}
+	view.Project.SetLabel(d.Location(offset), name)
+	return view.update(offset)
+}
+
+// Comment comments the location under the cursor,
+// or removes its comment if `comment` is empty.
+func (view *View) Comment(comment string) error {
+	offset, ok := view.Offset()
+	if !ok {
+		return errors.New("no location")
+	}
+	view.Project.SetComment(view.Disassembly.Location(offset), comment)
+	return view.update(offset)
+}
+
+// update reloads the listing after a change of the project,
+// keeping the cursor on the same location.
+func (view *View) update(offset int) error {
+	if err := view.reload(); err != nil {
+		return err
+	}
+	view.moveTo(offset)
+	return nil
+}
+
+// Rows returns the `height` rows around the cursor,
+// and the index of the cursor among them.
+func (view *View) Rows(height int) ([]string, int) {
+	if view.Cursor < view.Top {
+		view.Top = view.Cursor
+	}
+	if view.Cursor >= view.Top+height {
+		view.Top = view.Cursor - height + 1
+	}
+	end := view.Top + height
+	if end > len(view.rows) {
+		end = len(view.rows)
+	}
+	rows := make([]string, 0, end-view.Top)
+	for _, row := range view.rows[view.Top:end] {
+		rows = append(rows, row.text)
+	}
+	return rows, view.Cursor - view.Top
+}
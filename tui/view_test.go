@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vpenando/nes-rom-decompiler/internal/testrom"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func newTestView(t *testing.T) *View {
+	prg := testrom.Prg(
+		0x78,             // C000: SEI
+		0x20, 0x08, 0xC0, // C001: JSR $C008
+		0x4C, 0x01, 0xC0, // C004: JMP $C001
+		0xFF,
+		0xA9, 0x00, // C008: LDA #$00
+		0x60, // C00A: RTS
+	)
+	view, err := NewView(nes.NewProject(), func(project *nes.Project) (*nes.Disassembly, error) {
+		d := nes.NewPrgRomReader(prg).Disassembly()
+		if err := project.Apply(d); err != nil {
+			return nil, err
+		}
+		d.AddVectors()
+		d.Analyze()
+		return d, nil
+	})
+	assert.NoError(t, err)
+	return view
+}
+
+// line returns the row of the cursor.
+func line(view *View) string {
+	rows, cursor := view.Rows(1)
+	return strings.TrimSpace(rows[cursor])
+}
+
+func TestFollowAndBack(t *testing.T) {
+	view := newTestView(t)
+	offset, ok := view.Offset()
+	assert.True(t, ok)
+	assert.Equal(t, 0, offset)
+
+	assert.NoError(t, view.Jump("$C001"))
+	for !strings.HasPrefix(line(view), "JSR") {
+		view.Move(1)
+	}
+	assert.NoError(t, view.Follow())
+	offset, _ = view.Offset()
+	assert.Equal(t, 8, offset)
+
+	xrefs := view.Xrefs()
+	assert.Len(t, xrefs, 1)
+	assert.Equal(t, 1, xrefs[0].Offset)
+	assert.Equal(t, "$C001 call  JSR sub_C008", xrefs[0].Text)
+
+	assert.True(t, view.Back())
+	assert.Equal(t, "JSR sub_C008", line(view))
+	assert.True(t, view.Back())
+	offset, _ = view.Offset()
+	assert.Equal(t, 0, offset)
+	assert.False(t, view.Back())
+
+	assert.EqualError(t, view.Jump("nowhere"), "unknown location 'nowhere'")
+	assert.NoError(t, view.Jump("sub_C008"))
+	assert.EqualError(t, view.Follow(), "no operand address")
+}
+
+func TestRenameAndComment(t *testing.T) {
+	view := newTestView(t)
+	assert.NoError(t, view.Jump("$C008"))
+	assert.NoError(t, view.Rename("init"))
+	assert.NoError(t, view.Comment("Clears A"))
+	assert.Equal(t, map[string]string{"$C008": "init"}, view.Project.Labels)
+	assert.Equal(t, map[string]string{"$C008": "Clears A"}, view.Project.Comments)
+
+	offset, _ := view.Offset()
+	assert.Equal(t, 8, offset)
+	assert.True(t, strings.Contains(view.Disassembly.String(), "JSR init\n"))
+	assert.True(t, strings.Contains(view.Disassembly.String(), "; Clears A"))
+
+	assert.NoError(t, view.Rename(""))
+	assert.Empty(t, view.Project.Labels)
+	label, _ := view.Disassembly.Label(8)
+	assert.Equal(t, "sub_C008", label)
+}
+
+func TestRenameInvalid(t *testing.T) {
+	view := newTestView(t)
+	assert.NoError(t, view.Jump("$C008"))
+	assert.EqualError(t, view.Rename("2init"), "invalid name '2init'")
+	assert.EqualError(t, view.Rename("init loop"), "invalid name 'init loop'")
+	assert.EqualError(t, view.Rename("nmi"), "'nmi' is already the name of $C000")
+	assert.Empty(t, view.Project.Labels)
+
+	assert.NoError(t, view.Rename("sub_C008"))
+	assert.NoError(t, view.Rename("_init2"))
+	assert.Equal(t, map[string]string{"$C008": "_init2"}, view.Project.Labels)
+}
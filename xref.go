@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/vpenando/nes-rom-decompiler/cfg"
+	"github.com/vpenando/nes-rom-decompiler/nes"
+)
+
+func init() {
+	commands["xref"] = command{
+		run:   runXref,
+		usage: "xref [-o XXX.txt] XXX.nes (ADDRESS | LABEL | VARIABLE): list the instructions referencing an address",
+	}
+}
+
+// findXrefs returns the references to a label, to a RAM
+// variable or to a CPU address in any bank.
+func findXrefs(d *nes.Disassembly, target string) ([]nes.Xref, error) {
+	for offset, label := range d.Labels() {
+		if label == target {
+			return d.XrefsToOffset(offset), nil
+		}
+	}
+	for _, v := range d.Variables() {
+		if v.Name == target {
+			xrefs := d.XrefsTo(v.Address)
+			if v.Size == 2 {
+				xrefs = append(xrefs, d.XrefsTo(v.Address+1)...)
+			}
+			return xrefs, nil
+		}
+	}
+	address, err := parseAddress(target)
+	if err != nil {
+		return nil, fmt.Errorf("unknown address or label '%s'", target)
+	}
+	return d.XrefsTo(address), nil
+}
+
+// functionNames returns the name of the subroutine
+// holding each instruction, by PRG ROM offset.
+func functionNames(graph *cfg.Graph) map[int]string {
+	names := map[int]string{}
+	for _, function := range graph.Functions {
+		for _, block := range function.Blocks {
+			for _, inst := range block.Instructions {
+				if _, ok := names[inst.Offset]; !ok {
+					names[inst.Offset] = function.Name
+				}
+			}
+		}
+	}
+	return names
+}
+
+func runXref(args []string) error {
+	flags := flag.NewFlagSet("xref", flag.ExitOnError)
+	output := flags.String("o", "", "Output file (*.txt)")
+	addEntryFlag(flags)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		return errors.New("usage: xref [-o XXX.txt] XXX.nes (ADDRESS | LABEL | VARIABLE)")
+	}
+
+	d, err := analyzeRom(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	xrefs, err := findXrefs(d, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	if len(xrefs) == 0 {
+		return fmt.Errorf("no reference to '%s'", flags.Arg(1))
+	}
+	names := functionNames(cfg.Build(d))
+	lines := make([]string, len(xrefs))
+	for i, xref := range xrefs {
+		lines[i] = fmt.Sprintf("%-9s %-10s %-24s %s",
+			d.Location(xref.From.Offset), xref.Kind, d.FormatInstruction(xref.From), names[xref.From.Offset])
+	}
+	return writeOutput(*output, strings.Join(lines, "\n"))
+}